@@ -7,25 +7,21 @@ import (
 )
 
 type HttpClientConfig struct {
-	Host           string
-	RequestTimeout time.Duration
-	RetryTimeout   time.Duration
-	RetryTimes     int
-	DisableAuth    bool
+	Host           string        `env:"URL" default:"https://api.service.io"`
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" default:"15s"`
+	RetryTimeout   time.Duration `env:"RETRY_TIMEOUT" default:"5s"`
+	RetryTimes     int           `env:"RETRY_TIMES" default:"3"`
+	DisableAuth    bool          `env:"DISABLE_AUTH" default:"false"`
 
 	prefix string
 }
 
+// ClientConfig loads the client config under prefix (SERVICE_API by default). the request
+// timeout is read from REQUEST_TIMEOUT, it used to be read from RETRY_TIMEOUT
 func ClientConfig(prefix ...string) (conf *HttpClientConfig) {
 	env := utils.Env.Helper(prefix...).OrDefault("SERVICE_API")
 
-	conf = &HttpClientConfig{
-		Host:           env.GetString("URL", "https://api.service.io"),
-		RetryTimeout:   env.Get("RETRY_TIMEOUT", "5s").Duration(),
-		RequestTimeout: env.Get("RETRY_TIMEOUT", "15s").Duration(),
-		RetryTimes:     env.Get("RETRY_TIMES", "3").Int(),
-		DisableAuth:    env.Get("DISABLE_AUTH", "false").Bool(),
-		prefix:         env.Prefix(),
-	}
+	conf = &HttpClientConfig{prefix: env.Prefix()}
+	utils.Env.MustLoad(conf.prefix, conf)
 	return
 }
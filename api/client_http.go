@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/kod2ulz/gostart/discovery"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,8 +35,9 @@ type HttpClient struct {
 	retryTimeout   time.Duration
 	requestTimeout time.Duration
 
-	client  http.Client
-	session Session
+	client    http.Client
+	session   Session
+	discovery discovery.URLResolver
 
 	Logger *logrus.Entry
 }
@@ -54,6 +56,19 @@ func (c *HttpClient) Request(ctx context.Context) *httpClientRequest {
 	}
 }
 
+// WithDiscovery sets the resolver of consul://<service>/... urls. discovery.Default() is used otherwise
+func (c *HttpClient) WithDiscovery(resolver discovery.URLResolver) *HttpClient {
+	c.discovery = resolver
+	return c
+}
+
+func (c *HttpClient) resolver() discovery.URLResolver {
+	if c.discovery != nil {
+		return c.discovery
+	}
+	return discovery.Default()
+}
+
 func (c *HttpClient) WithTimeout(timeout time.Duration) *HttpClient {
 	c.requestTimeout = timeout
 	return c
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/kod2ulz/gostart/collections"
+	"github.com/kod2ulz/gostart/discovery"
 	"github.com/kod2ulz/gostart/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -66,6 +67,11 @@ func (r *httpClientRequest) doRequest(method, url string, headers Headers, param
 	r.req, r.err = params.Request(method, url, body)
 	if r.err != nil {
 		return utils.Error.Log(r.log, r.err, "failed to create request object")
+	} else if r.req.URL.Scheme == discovery.Scheme {
+		if r.req.URL, r.err = r.http.resolver().ResolveURL(r.ctx, r.req.URL); r.err != nil {
+			return utils.Error.Log(r.log, r.err, "failed to resolve %s", url)
+		}
+		r.req.Host = r.req.URL.Host
 	}
 	defer r.logRequest(params, method, r.req.URL.String())
 	headers.WithRequestID(r.ctx).WithAuthorization(r.http.session).Set(r.req)
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/pkg/errors"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatMsgPack = "msgpack"
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+
+	// FormatQueryParam selects the response format, taking precedence over the Accept header
+	FormatQueryParam = "format"
+)
+
+// Encoder writes response bodies in a format. tabular encoders are given the data of
+// responses instead of the Response envelope
+type Encoder interface {
+	ContentType() string
+	Tabular() bool
+	Encode(w io.Writer, v any) error
+}
+
+var encoderRegistry = struct {
+	mx         sync.RWMutex
+	formats    map[string]Encoder
+	mediaTypes map[string]string
+}{formats: make(map[string]Encoder), mediaTypes: make(map[string]string)}
+
+func init() {
+	RegisterEncoder(FormatJSON, jsonEncoder{})
+	RegisterEncoder(FormatMsgPack, &msgpackEncoder{handle: &codec.MsgpackHandle{WriteExt: true}}, "application/x-msgpack", "application/vnd.msgpack")
+	RegisterEncoder(FormatCSV, CSVEncoder(query.CSVEscapeFormulas()))
+	RegisterEncoder(FormatNDJSON, ndjsonEncoder{}, "application/jsonl")
+}
+
+// RegisterEncoder serves format to requests asking for it through the format query param
+// or for the content type of the encoder, or any of aliases, in their Accept header.
+// registering an existing format replaces its encoder e.g. to plug in protobuf
+func RegisterEncoder(format string, encoder Encoder, aliases ...string) {
+	encoderRegistry.mx.Lock()
+	defer encoderRegistry.mx.Unlock()
+	encoderRegistry.formats[format] = encoder
+	for _, mediaType := range append(aliases, encoder.ContentType()) {
+		if mediaType, _, err := mime.ParseMediaType(mediaType); err == nil {
+			encoderRegistry.mediaTypes[mediaType] = format
+		}
+	}
+}
+
+// LookupEncoder returns the encoder of format
+func LookupEncoder(format string) (enc Encoder, ok bool) {
+	encoderRegistry.mx.RLock()
+	defer encoderRegistry.mx.RUnlock()
+	enc, ok = encoderRegistry.formats[format]
+	return
+}
+
+// NegotiateFormat picks the format of the response from the format query param or the
+// Accept header of the request, json when neither names a registered format
+func NegotiateFormat(c router.Context) string {
+	encoderRegistry.mx.RLock()
+	defer encoderRegistry.mx.RUnlock()
+	if format := strings.ToLower(c.Query(FormatQueryParam)); format != "" {
+		if _, ok := encoderRegistry.formats[format]; ok {
+			return format
+		}
+	}
+	type accepted struct {
+		format string
+		q      float64
+	}
+	var matches []accepted
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if val, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(val, 64); err != nil {
+				q = 0
+			}
+		}
+		if format, ok := encoderRegistry.mediaTypes[mediaType]; ok && q > 0 {
+			matches = append(matches, accepted{format, q})
+		} else if (mediaType == "*/*" || mediaType == "application/*") && q > 0 {
+			matches = append(matches, accepted{FormatJSON, q})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].q > matches[j].q })
+	if len(matches) > 0 {
+		return matches[0].format
+	}
+	return FormatJSON
+}
+
+// WriteResponse writes res in the format negotiated with the client. tabular formats
+// get the Data of res, with its Metadata in the X-Total-Count header of list responses
+func WriteResponse[T any](c router.Context, code int, res Response[T]) {
+	format := NegotiateFormat(c)
+	encoder, ok := LookupEncoder(format)
+	if !ok || format == FormatJSON {
+		c.JSON(code, res)
+		return
+	}
+	var body any = res
+	if encoder.Tabular() {
+		if body = res.Data; res.Meta != nil && res.Meta.Total > 0 {
+			c.Header("X-Total-Count", strconv.FormatInt(res.Meta.Total, 10))
+		}
+	}
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, body); err != nil {
+		RenderError[T](c, GeneralError[T](errors.Wrapf(err, "failed to encode response as %s", format)).
+			WithErrorCode(ErrorCodeResponseProcessingError))
+		return
+	}
+	c.Data(code, encoder.ContentType(), buf.Bytes())
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json; charset=utf-8" }
+
+func (jsonEncoder) Tabular() bool { return false }
+
+func (jsonEncoder) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type msgpackEncoder struct {
+	handle *codec.MsgpackHandle
+}
+
+func (*msgpackEncoder) ContentType() string { return "application/msgpack" }
+
+func (*msgpackEncoder) Tabular() bool { return false }
+
+func (e *msgpackEncoder) Encode(w io.Writer, v any) error {
+	return codec.NewEncoder(w, e.handle).Encode(v)
+}
+
+// ndjsonEncoder writes each item of a list on its own line
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) ContentType() string { return "application/x-ndjson" }
+
+func (ndjsonEncoder) Tabular() bool { return true }
+
+func (ndjsonEncoder) Encode(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	rows := reflect.ValueOf(v)
+	if rows.Kind() != reflect.Slice && rows.Kind() != reflect.Array {
+		return enc.Encode(v)
+	}
+	for i := 0; i < rows.Len(); i++ {
+		if err := enc.Encode(rows.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvEncoder writes lists of structs with a header row named after the csv, else json,
+// tags of their fields. single items are written as a list of one. cells that would run
+// as spreadsheet formulas are escaped unless the encoder is registered with other options
+// e.g. RegisterEncoder(FormatCSV, CSVEncoder(query.CSVDelimiter(';')))
+type csvEncoder struct {
+	opts []query.CSVOption
+}
+
+// CSVEncoder writes csv with opts, see query.NewCSVWriter
+func CSVEncoder(opts ...query.CSVOption) Encoder {
+	return csvEncoder{opts: opts}
+}
+
+func (csvEncoder) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (csvEncoder) Tabular() bool { return true }
+
+func (e csvEncoder) Encode(w io.Writer, v any) (err error) {
+	rows := reflect.ValueOf(v)
+	if !rows.IsValid() {
+		return
+	} else if rows.Kind() != reflect.Slice && rows.Kind() != reflect.Array {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(v)), 0, 1), rows)
+	}
+	out := query.NewCSVWriter(w, rows.Type().Elem(), e.opts...)
+	if err = out.WriteHeader(); err != nil {
+		return
+	}
+	for i := 0; i < rows.Len(); i++ {
+		if err = out.Write(rows.Index(i).Interface()); err != nil {
+			return errors.Wrapf(err, "failed to encode row %d", i)
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,114 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ugorji/go/codec"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/services/auth"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+type bookRow struct {
+	Book
+	Rating *float64 `csv:"stars"`
+	Notes  []string `json:"notes"`
+	secret string
+}
+
+var _ = Describe("Response encoders", func() {
+
+	var recorder *httptest.ResponseRecorder
+	var user auth.UserData
+	headers := api.Headers{}
+	books := bookService()
+	userStore := auth.InMemoryUserStore()
+	sessionService := auth.SessionService(nil, userStore)
+	rating := 4.5
+	engine := utils.Test.GinRouter(func(e *gin.Engine) {
+		books.setRoutes(e.Group("/books"), api.WithUser[auth.VerifyTokenRequest](sessionService))
+		e.GET("/rows", api.BasicHandler(func(context.Context) ([]bookRow, api.Error) {
+			return []bookRow{
+				{Book: Book{Name: "A, the first", Pages: 300}, Rating: &rating, Notes: []string{"x"}},
+				{Book: Book{Name: "B", Pages: 210}},
+				{Book: Book{Name: "=cmd|' /C calc'!A0", Author: "-Ngugi", Pages: -1}},
+			}, nil
+		}))
+	})
+
+	BeforeEach(func(ctx context.Context) {
+		signupReq := createSignupRequest()
+		user = registerUser(ctx, signupReq, sessionService)
+		token := authenticateUser(ctx, signupReq, sessionService)
+		headers.WithBearerToken(token.AccessToken)
+		recorder = httptest.NewRecorder()
+		_, err := books.seed(3, &user)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() { books.clear() })
+
+	get := func(path, accept string) {
+		req := utils.Test.Request(http.MethodGet, path, nil, headers)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		engine.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	}
+
+	It("defaults to json", func() {
+		var res api.Response[[]Book]
+		get("/books", "text/html, */*;q=0.1")
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res.Success).To(BeTrue())
+	})
+
+	It("keeps the envelope in msgpack", func() {
+		var res map[string]any
+		get("/books", "application/msgpack")
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/msgpack"))
+		Expect(codec.NewDecoder(recorder.Body, &codec.MsgpackHandle{}).Decode(&res)).To(Succeed())
+		Expect(res).To(HaveKeyWithValue("success", true))
+		Expect(res).To(HaveKeyWithValue("data", HaveLen(3)))
+	})
+
+	It("flattens lists to csv rows", func() {
+		get("/rows?format=csv", "application/json")
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/csv"))
+		records, err := csv.NewReader(recorder.Body).ReadAll()
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(4))
+		Expect(records[0]).To(Equal([]string{"id", "name", "author", "pages", "createdBy", "stars", "notes"}))
+		Expect(records[1][1:4]).To(Equal([]string{"A, the first", "", "300"}))
+		Expect(records[1][5:]).To(Equal([]string{"4.5", `["x"]`}))
+		Expect(records[2][5:]).To(Equal([]string{"", ""}))
+		Expect(records[3][1:4]).To(Equal([]string{"'=cmd|' /C calc'!A0", "'-Ngugi", "-1"}), "formulas are escaped")
+	})
+
+	It("writes list items as ndjson lines", func() {
+		get("/books", "application/x-ndjson")
+		Expect(recorder.Header().Get("X-Total-Count")).To(Equal("3"))
+		lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+		Expect(lines).To(HaveLen(3))
+		var book Book
+		Expect(json.NewDecoder(bytes.NewBufferString(lines[0])).Decode(&book)).To(Succeed())
+		Expect(book.Name).ToNot(BeEmpty())
+	})
+
+	It("does not serve ndjson for json text sequences", func() {
+		get("/books", "application/json-seq")
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/json;"))
+	})
+})
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrServer             = DefineError(ErrorCodeServerError, http.StatusInternalServerError, "server error")
+	ErrNotFound           = DefineError(ErrorCodeNotFoundError, http.StatusNotFound, "Not Found")
+	ErrIntegration        = DefineError(ErrorCodeIntegrationError, http.StatusBadGateway, "integration failed", Retryable())
+	ErrRequestLoad        = DefineError(ErrorCodeRequestLoadError, http.StatusBadRequest, "failed to load request")
+	ErrService            = DefineError(ErrorCodeServiceError, http.StatusInternalServerError, "service error")
+	ErrResponseProcessing = DefineError(ErrorCodeResponseProcessingError, http.StatusInternalServerError, "failed to process response")
+	ErrValidation         = DefineError(ErrorCodeValidatorError, http.StatusBadRequest, "validation failed")
+	ErrSQL                = DefineError(ErrorCodeSQLError, http.StatusInternalServerError, "query failed")
+	ErrUnauthorized       = DefineError(ErrorCodeUnauthorized, http.StatusUnauthorized, "invalid credentials")
+	ErrInvalidOperation   = DefineError(ErrorCodeInvalidOperation, http.StatusBadRequest, "invalid operation")
+	ErrForbidden          = DefineError(ErrorCodeForbidden, http.StatusForbidden, "forbidden")
+	ErrConflict           = DefineError(ErrorCodeConflict, http.StatusConflict, "conflicts with an existing resource")
+	ErrTimeout            = DefineError(ErrorCodeTimeout, http.StatusGatewayTimeout, "timed out", Retryable())
+	ErrRateLimited        = DefineError(ErrorCodeRateLimited, http.StatusTooManyRequests, "too many requests", Retryable())
+	ErrUnknownField       = DefineError("UnknownField", http.StatusBadRequest, "unknown field %s")
+)
+
+// postgres error codes mapped to definitions. foreign key violations are left to
+// applications, they may be bad references in requests as well as conflicts
+var pgErrorCodes = map[string]*ErrorDefinition{
+	"23505": ErrConflict, // unique_violation
+	"57014": ErrTimeout,  // query_canceled e.g. by statement_timeout
+}
+
+// codedError is implemented by errors naming the code of the definition they map to e.g.
+// the invalid cursors and unknown fields of query builders
+type codedError interface {
+	error
+	ErrorCode() string
+}
+
+func init() {
+	RegisterErrorMapping(func(err error) *ErrorDefinition {
+		var pgErr *pgconn.PgError
+		var coded codedError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows), errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		case errors.Is(err, context.DeadlineExceeded):
+			return ErrTimeout
+		case errors.As(err, &coded):
+			d, _ := LookupError(coded.ErrorCode())
+			return d
+		case errors.As(err, &pgErr):
+			return pgErrorCodes[pgErr.Code]
+		}
+		return nil
+	})
+}
+
+// ErrorDefinition declares an error with a stable code clients can rely on. definitions
+// are errors themselves so that errors.Is(err, ErrNotFound) matches any Error of that code
+type ErrorDefinition struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	// Message is a fmt template formatted with the args of New and Wrap
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+type ErrorDefinitionOption func(*ErrorDefinition)
+
+// Retryable marks errors that may succeed when the request is repeated
+func Retryable() ErrorDefinitionOption {
+	return func(d *ErrorDefinition) { d.Retryable = true }
+}
+
+// ErrorMapping maps errors e.g. of database drivers to definitions, nil when it does not apply
+type ErrorMapping func(err error) *ErrorDefinition
+
+var errorRegistry = struct {
+	mx       sync.RWMutex
+	defs     map[string]*ErrorDefinition
+	mappings []ErrorMapping
+}{defs: make(map[string]*ErrorDefinition)}
+
+// DefineError registers the definition of code. it panics when code is already defined
+// since codes must identify a single kind of error
+func DefineError(code string, status int, message string, opts ...ErrorDefinitionOption) *ErrorDefinition {
+	d := &ErrorDefinition{Code: code, Status: status, Message: message}
+	for i := range opts {
+		opts[i](d)
+	}
+	errorRegistry.mx.Lock()
+	defer errorRegistry.mx.Unlock()
+	if _, ok := errorRegistry.defs[code]; ok {
+		panic(fmt.Sprintf("error code %s is already defined", code))
+	}
+	errorRegistry.defs[code] = d
+	return d
+}
+
+// LookupError returns the definition of code
+func LookupError(code string) (d *ErrorDefinition, ok bool) {
+	errorRegistry.mx.RLock()
+	defer errorRegistry.mx.RUnlock()
+	d, ok = errorRegistry.defs[code]
+	return
+}
+
+// ErrorDefinitions lists the defined errors sorted by code e.g. to document them
+func ErrorDefinitions() (out []ErrorDefinition) {
+	errorRegistry.mx.RLock()
+	for _, d := range errorRegistry.defs {
+		out = append(out, *d)
+	}
+	errorRegistry.mx.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return
+}
+
+// RegisterErrorMapping adds a mapping consulted by GeneralError and MapError. mappings
+// registered last take precedence
+func RegisterErrorMapping(mapping ErrorMapping) {
+	errorRegistry.mx.Lock()
+	defer errorRegistry.mx.Unlock()
+	errorRegistry.mappings = append(errorRegistry.mappings, mapping)
+}
+
+// mappedError runs the mappings outside the registry lock, so they may look up or define errors
+func mappedError(err error) *ErrorDefinition {
+	if err == nil {
+		return nil
+	}
+	errorRegistry.mx.RLock()
+	mappings := append([]ErrorMapping(nil), errorRegistry.mappings...)
+	errorRegistry.mx.RUnlock()
+	for i := len(mappings) - 1; i >= 0; i-- {
+		if d := mappings[i](err); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func (d *ErrorDefinition) Error() string {
+	return d.Code
+}
+
+// New creates an Error of the definition with its message formatted with args
+func (d *ErrorDefinition) New(args ...any) Error {
+	return NewDefinedError[any](d, nil, args...)
+}
+
+// Wrap creates an Error of the definition caused by err
+func (d *ErrorDefinition) Wrap(err error, args ...any) Error {
+	return NewDefinedError[any](d, err, args...)
+}
+
+// NewDefinedError creates an Error of d about T. err, when set, is exposed through
+// errors.Is and errors.As and listed in the error data
+func NewDefinedError[T any](d *ErrorDefinition, err error, args ...any) Error {
+	er := _initError[T](d.Status, d.Code, err)
+	er.Message, er.Retryable, er.Errors = fmt.Sprintf(d.Message, args...), d.Retryable, nil
+	if err != nil {
+		er.Errors = []string{err.Error()}
+	}
+	return &er
+}
+
+// MapError converts err to an Error. Errors are returned as they are, errors matching a
+// mapping get the code and status of its definition and anything else is a server error
+func MapError[T any](err error) Error {
+	var e Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return GeneralError[T](err)
+}
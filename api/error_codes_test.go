@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/query"
+)
+
+var errBookLocked = api.DefineError("BookLocked", http.StatusLocked, "book %s is locked", api.Retryable())
+
+var _ = Describe("Error codes", func() {
+
+	It("creates errors from definitions", func() {
+		err := errBookLocked.New("b-1")
+		Expect(err.Error()).To(Equal("book b-1 is locked"))
+		Expect(err.Response()).To(HaveField("Error", HaveField("Retryable", BeTrue())))
+		Expect(errors.Is(err, errBookLocked)).To(BeTrue())
+		Expect(errors.Is(err, api.ErrNotFound)).To(BeFalse())
+		Expect(func() { api.DefineError("BookLocked", http.StatusConflict, "") }).To(Panic())
+
+		d, ok := api.LookupError("BookLocked")
+		Expect(ok).To(BeTrue())
+		Expect(d).To(BeIdenticalTo(errBookLocked))
+		Expect(api.ErrorDefinitions()).To(ContainElement(HaveField("Code", api.ErrorCodeConflict)))
+	})
+
+	It("unwraps to the source error and cause", func() {
+		source := errors.New("disk full")
+		err := api.ServerError(fmt.Errorf("saving book: %w", source)).WithCause(errBookLocked.New("b-2"))
+		Expect(errors.Is(err, source)).To(BeTrue())
+		Expect(errors.Is(err, errBookLocked)).To(BeTrue())
+		Expect(errors.Is(err, api.ErrServer)).To(BeTrue())
+		Expect(errors.Is(err, errBookLocked.New("b-2"))).To(BeTrue())
+		Expect(errors.Is(err, errBookLocked.New("b-3"))).To(BeFalse(), "errors of a code with other messages")
+
+		var model *api.ErrorModel[any]
+		Expect(errors.As(api.NotFoundError[Book](CreateBookRequest{}), &model)).To(BeTrue())
+		Expect(model.Code).To(Equal(api.ErrorCodeNotFoundError))
+	})
+
+	It("leaves errors intact when deriving new ones", func() {
+		base := api.GeneralError[Book](errors.New("failed"))
+		derived := base.WithErrorCodeAndHttpStatusCode(api.ErrorCodeForbidden, http.StatusForbidden).WithError(errors.New("detail"))
+		Expect(base.Response()).To(HaveField("Error", And(HaveField("Code", api.ErrorCodeServerError), HaveField("Errors", BeEmpty()))))
+		Expect(derived.Response()).To(HaveField("Error", And(HaveField("Code", api.ErrorCodeForbidden), HaveField("Errors", HaveLen(1)))))
+	})
+
+	It("lets mappings look up and define errors", func() {
+		errShelfFull := errors.New("shelf full")
+		var once sync.Once
+		var full *api.ErrorDefinition
+		api.RegisterErrorMapping(func(err error) *api.ErrorDefinition {
+			if !errors.Is(err, errShelfFull) {
+				return nil
+			}
+			once.Do(func() { full = api.DefineError("ShelfFull", http.StatusInsufficientStorage, "shelf is full") })
+			d, _ := api.LookupError("ShelfFull")
+			return d
+		})
+		e := api.MapError[Book](errors.Wrap(errShelfFull, "adding book"))
+		Expect(e.Response()).To(HaveField("Error", And(HaveField("Code", "ShelfFull"), HaveField("Http", http.StatusInsufficientStorage))))
+		Expect(errors.Is(e, full)).To(BeTrue())
+	})
+
+	DescribeTable("maps driver errors",
+		func(err error, code string, status int) {
+			e := api.SQLError[Book](err)
+			Expect(e.Response()).To(HaveField("Error", And(HaveField("Code", code), HaveField("Http", status))))
+		},
+		Entry("pgx no rows", errors.Wrap(pgx.ErrNoRows, "get book"), api.ErrorCodeNotFoundError, http.StatusNotFound),
+		Entry("sql no rows", sql.ErrNoRows, api.ErrorCodeNotFoundError, http.StatusNotFound),
+		Entry("unique violation", &pgconn.PgError{Code: "23505", Message: "duplicate key"}, api.ErrorCodeConflict, http.StatusConflict),
+		Entry("foreign key violation", &pgconn.PgError{Code: "23503"}, api.ErrorCodeSQLError, http.StatusInternalServerError),
+		Entry("deadline", context.DeadlineExceeded, api.ErrorCodeTimeout, http.StatusGatewayTimeout),
+		Entry("unknown query field", &query.UnknownIdentifierError{Kind: "field", Name: "password", Relation: "users"}, "UnknownField", http.StatusBadRequest),
+		Entry("invalid cursor", query.ErrInvalidCursor, api.ErrorCodeValidatorError, http.StatusBadRequest),
+		Entry("invalid search", errors.Wrap(query.ErrInvalidSearch, "pages_gt"), api.ErrorCodeValidatorError, http.StatusBadRequest),
+		Entry("invalid csv", &query.CSVLineError{Line: 3, Column: "pages", Err: errors.New("not a number")}, api.ErrorCodeValidatorError, http.StatusBadRequest),
+		Entry("anything else", errors.New("syntax error"), api.ErrorCodeSQLError, http.StatusInternalServerError),
+	)
+})
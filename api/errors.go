@@ -3,11 +3,14 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/kod2ulz/gostart/collections"
 	"github.com/kod2ulz/gostart/object"
 	"github.com/kod2ulz/gostart/utils"
+	"github.com/pkg/errors"
 )
 
 var (
@@ -21,6 +24,10 @@ var (
 	ErrorCodeSQLError                string = "SQLError"
 	ErrorCodeUnauthorized            string = "InvalidCredentials"
 	ErrorCodeInvalidOperation        string = "InvalidOperation"
+	ErrorCodeForbidden               string = "Forbidden"
+	ErrorCodeConflict                string = "Conflict"
+	ErrorCodeTimeout                 string = "Timeout"
+	ErrorCodeRateLimited             string = "RateLimited"
 )
 
 type Error interface {
@@ -33,6 +40,7 @@ type Error interface {
 	WithError(err error) (out Error)
 	WithCause(err Error) (out Error)
 	Response() interface{}
+	model() *ErrorModel[any]
 }
 
 type ErrorModel[T any] struct {
@@ -43,7 +51,20 @@ type ErrorModel[T any] struct {
 	Param   RequestParam      `json:"params,omitempty"`
 	Errors  []string          `json:"data,omitempty"`
 	Fields  map[string]string `json:"fields,omitempty"`
-	Cause   Error             `json:"cause,omitempty"`
+	// FieldErrors details the rules failed by each field of the request
+	FieldErrors []FieldError `json:"fieldErrors,omitempty"`
+	Retryable   bool         `json:"retryable,omitempty"`
+	Cause       Error        `json:"cause,omitempty"`
+
+	err error
+}
+
+// FieldError is a validation rule failed by a field. Field is its json path e.g. items[0].name
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
 }
 
 func (e *ErrorModel[T]) http() int {
@@ -54,42 +75,111 @@ func (e *ErrorModel[T]) Error() string {
 	return e.Message
 }
 
+// clone lets the With mutators leave errors shared e.g. through package vars intact
+func (e *ErrorModel[T]) clone() *ErrorModel[T] {
+	c := *e
+	if e.Errors != nil {
+		c.Errors = append(make([]string, 0, len(e.Errors)+1), e.Errors...)
+	}
+	return &c
+}
+
 func (e *ErrorModel[T]) WithErrorCode(errorCode string) (out Error) {
-	e.Code = errorCode
-	return e
+	c := e.clone()
+	c.Code = errorCode
+	return c
 }
 
 func (e *ErrorModel[T]) WithHttpStatusCode(statusCode int) (out Error) {
-	e.Http = statusCode
-	return e
+	c := e.clone()
+	c.Http = statusCode
+	return c
 }
 
 func (e *ErrorModel[T]) WithErrorCodeAndHttpStatusCode(errorCode string, statusCode int) (out Error) {
-	return e.WithErrorCode(errorCode).WithHttpStatusCode(statusCode)
+	c := e.clone()
+	c.Code, c.Http = errorCode, statusCode
+	return c
 }
 
 func (e *ErrorModel[T]) WithMessage(message string, opts...any) (out Error) {
-	e.Message = fmt.Sprintf(message, opts...)
-	return e
+	c := e.clone()
+	c.Message = fmt.Sprintf(message, opts...)
+	return c
 }
 
 func (e *ErrorModel[T]) WithError(err error) (out Error) {
-	if len(e.Errors) == 0 {
-		e.Errors = []string{}
+	c := e.clone()
+	if len(c.Errors) == 0 {
+		c.Errors = []string{}
+	}
+	c.Errors = append(c.Errors, err.Error())
+	if c.err == nil {
+		c.err = err
 	}
-	e.Errors = append(e.Errors, err.Error())
-	return e
+	return c
 }
 
 func (e *ErrorModel[T]) WithCause(err Error) (out Error) {
-	e.Cause = err
-	return e
+	c := e.clone()
+	c.Cause = err
+	return c
+}
+
+// Unwrap exposes the error e was created from and its Cause to errors.Is and errors.As
+func (e *ErrorModel[T]) Unwrap() (out []error) {
+	if e.err != nil {
+		out = append(out, e.err)
+	}
+	if e.Cause != nil {
+		out = append(out, e.Cause)
+	}
+	return
+}
+
+// Is matches definitions and other Errors with the code of e
+func (e *ErrorModel[T]) Is(target error) bool {
+	switch t := target.(type) {
+	case *ErrorDefinition:
+		return t.Code == e.Code
+	case Error:
+		target := t.model()
+		return target.Code == e.Code && target.Message == e.Message
+	}
+	return false
+}
+
+// As sets **ErrorModel[any] targets regardless of the type e is about
+func (e *ErrorModel[T]) As(target any) bool {
+	if t, ok := target.(**ErrorModel[any]); ok {
+		*t = e.model()
+		return true
+	}
+	return false
 }
 
 func (e *ErrorModel[T]) Response() (out interface{}) {
 	return ErrorResponse[T](e)
 }
 
+func (e *ErrorModel[T]) model() *ErrorModel[any] {
+	return &ErrorModel[any]{
+		Type: e.Type, Message: e.Message, Code: e.Code, Http: e.Http, Param: e.Param,
+		Errors: e.Errors, Fields: e.Fields, FieldErrors: e.FieldErrors, Retryable: e.Retryable, Cause: e.Cause, err: e.err,
+	}
+}
+
+// withoutInternals drops the cause and type of e, and the details of server errors
+func (e *ErrorModel[T]) withoutInternals() (out *ErrorModel[T]) {
+	c := *e
+	out = &c
+	out.Type, out.Cause = "", nil
+	if out.Http >= http.StatusInternalServerError {
+		out.Message, out.Errors, out.Param = http.StatusText(out.Http), nil, nil
+	}
+	return
+}
+
 func _initError[T any](httpCode int, statusCode string, err error) (out ErrorModel[T]) {
 	var message string
 	var errorMessages collections.List[string]
@@ -106,6 +196,7 @@ func _initError[T any](httpCode int, statusCode string, err error) (out ErrorMod
 		Code:    statusCode,
 		Http:    httpCode,
 		Errors:  errorMessages,
+		err:     err,
 	}
 	if out.Type == "interface{}" {
 		out.Type = "Undefined"
@@ -127,8 +218,13 @@ func ServiceErrorUnauthorised(err error) (out Error) {
 		WithErrorCodeAndHttpStatusCode(ErrorCodeUnauthorized, http.StatusUnauthorized)
 }
 
+// GeneralError is a server error unless err matches an error mapping e.g. sql.ErrNoRows
+// which is a NotFoundError
 func GeneralError[T any](err error) (out Error) {
 	er := _initError[T](http.StatusInternalServerError, ErrorCodeServerError, err)
+	if d := mappedError(err); d != nil {
+		er.Code, er.Http, er.Retryable = d.Code, d.Status, d.Retryable
+	}
 	if err == nil || !strings.Contains(err.Error(), ". ") {
 		return &er
 	}
@@ -151,15 +247,14 @@ func RequestLoadError[T any](err error) (out Error) {
 	return GeneralError[T](err).WithErrorCodeAndHttpStatusCode(ErrorCodeValidatorError, http.StatusBadRequest)
 }
 
-func ValidatorError[T any](err error) (out Error) {
+// ValidatorError reports failed validation. messages of validator.ValidationErrors are
+// translated to the first supported of languages e.g. from the Accept-Language header
+func ValidatorError[T any](err error, languages ...string) (out Error) {
 	er := _initError[T](http.StatusBadRequest, ErrorCodeValidatorError, err)
 	if err == nil || !strings.Contains(err.Error(), "Key:") {
 		return &er
 	}
 	errs := strings.Split(err.Error(), "Key:")
-	if len(errs) == 0 {
-		return &er
-	}
 	er.Errors, er.Fields = make([]string, 0), map[string]string{}
 	for _, msg := range errs {
 		if !strings.Contains(msg, "Error:") {
@@ -173,11 +268,30 @@ func ValidatorError[T any](err error) (out Error) {
 		er.Message = er.Errors[0]
 		er.Errors = nil
 	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return &er
+	}
+	trans, root := utils.Validator.Translator(languages...), reflect.TypeOf((*T)(nil)).Elem()
+	er.Fields, er.FieldErrors = make(map[string]string), make([]FieldError, len(fieldErrors))
+	for i, fe := range fieldErrors {
+		path := utils.Validator.FieldPath(root, fe)
+		er.FieldErrors[i] = FieldError{
+			Field: path, Rule: fe.Tag(), Param: fe.Param(),
+			Message: utils.Validator.TranslateValidation(fe, trans, path[strings.LastIndexByte(path, '.')+1:]),
+		}
+		er.Fields[path] = er.FieldErrors[i].Message
+	}
 	return &er
 }
 
+// SQLError reports failed queries. errors matching a mapping keep its code e.g. Conflict
+// for unique violations
 func SQLError[T any](err error) (out Error) {
-	return GeneralError[T](err).WithErrorCode(ErrorCodeSQLError)
+	if out = GeneralError[T](err); out.model().Code == ErrorCodeServerError {
+		return out.WithErrorCode(ErrorCodeSQLError)
+	}
+	return
 }
 
 func SqlQueryError[P RequestParam, T any](param P, out T, err error) (T, Error) {
@@ -5,6 +5,9 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -68,4 +71,38 @@ func (p *Headers) WithBasicAuth(username, password string) *Headers {
 		(*p)["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString(creds)
 	}
 	return p
-}
\ No newline at end of file
+}
+
+// AcceptLanguages lists the locales of an Accept-Language header by preference. regional
+// locales e.g. fr-CH are followed by their language so that either can be matched
+func AcceptLanguages(header string) (out []string) {
+	type language struct {
+		tag string
+		q   float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag = strings.TrimSpace(tag); tag == "" || tag == "*" {
+			continue
+		}
+		lang := language{tag: tag, q: 1}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if val, err := strconv.ParseFloat(q, 64); err == nil {
+				lang.q = val
+			}
+		}
+		if lang.q > 0 {
+			languages = append(languages, lang)
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+	for _, lang := range languages {
+		base, region, regional := strings.Cut(lang.tag, "-")
+		if regional {
+			out = append(out, strings.ToLower(base)+"_"+strings.ToUpper(region))
+		}
+		out = append(out, strings.ToLower(base))
+	}
+	return
+}
@@ -53,7 +53,7 @@ func JSONLogMiddleware(log *logr.Logger) gin.HandlerFunc {
 			entry.Error(c.Errors.String())
 		} else if c.Writer.Status() >= 400 {
 			entry.WithField("errors", c.Errors).Warn("")
-		} else if fields["path"] == "/ok" {
+		} else if path := fields["path"]; path == "/ok" || path == "/health/live" || path == "/health/ready" {
 			entry.Trace("consul hc")
 		} else {
 			entry.Info("")
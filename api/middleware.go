@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/router"
 )
 
 type Middleware interface {
@@ -9,16 +10,20 @@ type Middleware interface {
 }
 
 func WithUser[TokenRequest RequestParam, UserResponse, TokenResponse any](svc SessionService[UserResponse, TokenResponse]) gin.HandlerFunc {
-	return func(c *gin.Context) {
+	return WithUserFunc[TokenRequest](svc).Gin()
+}
+
+// WithUserFunc is WithUser for any router
+func WithUserFunc[TokenRequest RequestParam, UserResponse, TokenResponse any](svc SessionService[UserResponse, TokenResponse]) router.HandlerFunc {
+	return func(c router.Context) {
 		var loadError Error
 		var req TokenRequest
 		if req, loadError = loadParamFromRequest[TokenRequest](c); loadError != nil {
-			c.AbortWithStatusJSON(loadError.http(), ErrorResponse[TokenRequest](loadError))
-		} else if validationError := req.Validate(c); loadError != nil {
-			e := ServiceErrorUnauthorised(validationError).(*ErrorModel[UserResponse])
-			c.AbortWithStatusJSON(e.Http, ErrorResponse[UserResponse](e))
-		} else if user, err := svc.Verify(c); err != nil {
-			c.AbortWithStatusJSON(err.(*ErrorModel[UserResponse]).Http, ErrorResponse[UserResponse](err))
+			RenderError[TokenRequest](c, loadError)
+		} else if validationError := req.Validate(c); validationError != nil {
+			RenderError[UserResponse](c, ServiceErrorUnauthorised(validationError))
+		} else if user, err := svc.Verify(router.Unwrap(c)); err != nil {
+			RenderError[UserResponse](c, err)
 		} else {
 			c.Set(ContextAuthUserKey, user)
 			c.Next()
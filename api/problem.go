@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/kod2ulz/gostart/router"
+)
+
+const (
+	// ContentTypeProblem is the media type of RFC 9457 problem details
+	ContentTypeProblem = "application/problem+json"
+	// ContextErrorRendererKey holds the ErrorRenderer of a request. see ErrorRendering
+	ContextErrorRendererKey = "gostart/api/error-renderer"
+)
+
+type ErrorFormat string
+
+const (
+	// ErrorFormatEnvelope renders errors in the Response envelope
+	ErrorFormatEnvelope ErrorFormat = "envelope"
+	// ErrorFormatProblem renders errors as RFC 9457 (formerly 7807) problem details
+	ErrorFormatProblem ErrorFormat = "problem"
+)
+
+// ErrorRenderer writes failed responses. responseType names the type the request would
+// have responded with
+type ErrorRenderer interface {
+	RenderError(c router.Context, err Error, responseType string)
+}
+
+type ErrorRendererFunc func(c router.Context, err Error, responseType string)
+
+func (f ErrorRendererFunc) RenderError(c router.Context, err Error, responseType string) {
+	f(c, err, responseType)
+}
+
+// DefaultErrorRenderer renders errors of requests without an ErrorRendering middleware
+var DefaultErrorRenderer ErrorRenderer = NewErrorRenderer(ErrorFormatEnvelope)
+
+type ErrorRendererOption func(*errorRenderer)
+
+// WithProblemTypeBase prefixes the kebab cased codes of errors to form problem type URIs
+// e.g. https://errors.startup.io/ gives https://errors.startup.io/validation-error.
+// problems are typed about:blank without it
+func WithProblemTypeBase(uri string) ErrorRendererOption {
+	return func(r *errorRenderer) { r.typeBase = uri }
+}
+
+// StripInternalErrors hides causes, go type names and the details of server errors
+// from clients e.g. in production
+func StripInternalErrors(strip bool) ErrorRendererOption {
+	return func(r *errorRenderer) { r.strip = strip }
+}
+
+type errorRenderer struct {
+	format   ErrorFormat
+	typeBase string
+	strip    bool
+}
+
+// NewErrorRenderer renders errors in format unless the request accepts problem details,
+// which are preferred whenever they are asked for
+func NewErrorRenderer(format ErrorFormat, opts ...ErrorRendererOption) *errorRenderer {
+	r := &errorRenderer{format: format}
+	for i := range opts {
+		opts[i](r)
+	}
+	return r
+}
+
+func (r *errorRenderer) RenderError(c router.Context, err Error, responseType string) {
+	model := err.model()
+	if r.strip {
+		model, responseType = model.withoutInternals(), ""
+	}
+	if r.format != ErrorFormatProblem && !acceptsProblem(c.GetHeader("Accept")) {
+		c.JSON(model.Http, ErrorResponse[any](model).withType(responseType))
+		return
+	}
+	problem := r.problem(model)
+	problem.Instance = requestInstance(c)
+	data, e := json.Marshal(problem)
+	if e != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse[any](ServerError(e)))
+		return
+	}
+	c.Data(model.Http, ContentTypeProblem, data)
+}
+
+func (r *errorRenderer) problem(model *ErrorModel[any]) (out *Problem) {
+	out = &Problem{Type: "about:blank", Title: http.StatusText(model.Http), Status: model.Http, Detail: model.Message}
+	if r.typeBase != "" && model.Code != "" {
+		out.Type = r.typeBase + strcase.ToKebab(model.Code)
+		out.Title = strings.ToUpper(model.Code[:1]) + strcase.ToDelimited(model.Code, ' ')[1:]
+	}
+	out.Extensions = map[string]any{"code": model.Code}
+	if model.Retryable {
+		out.Extensions["retryable"] = true
+	}
+	if len(model.Errors) > 0 {
+		out.Extensions["data"] = model.Errors
+	}
+	if len(model.FieldErrors) > 0 {
+		out.Extensions["fieldErrors"] = model.FieldErrors
+	} else if len(model.Fields) > 0 {
+		out.Extensions["fields"] = model.Fields
+	}
+	if model.Param != nil {
+		out.Extensions["params"] = model.Param
+	}
+	if model.Cause != nil {
+		out.Extensions["cause"] = r.problem(model.Cause.model())
+	}
+	return
+}
+
+// Problem is an RFC 9457 problem details object. Extensions are inlined as members
+type Problem struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail,omitempty"`
+	Instance   string         `json:"instance,omitempty"`
+	Extensions map[string]any `json:"-"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+	out := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	for k, v := range p.Extensions {
+		if _, reserved := out[k]; reserved {
+			continue
+		} else if out[k], err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(out)
+}
+
+// ErrorRendering renders the errors of subsequent handlers with renderer
+func ErrorRendering(renderer ErrorRenderer) router.HandlerFunc {
+	return func(c router.Context) {
+		c.Set(ContextErrorRendererKey, renderer)
+		c.Next()
+	}
+}
+
+// RenderError writes err with the ErrorRenderer of the request and aborts it
+func RenderError[T any](c router.Context, err Error) {
+	requestErrorRenderer(c).RenderError(c, err, typeName[T]())
+	c.Abort()
+}
+
+func requestErrorRenderer(c router.Context) ErrorRenderer {
+	if val, ok := c.Get(ContextErrorRendererKey); ok {
+		if r, ok := val.(ErrorRenderer); ok && r != nil {
+			return r
+		}
+	}
+	return DefaultErrorRenderer
+}
+
+// clientError is err as the ErrorRenderer of the request would show it to clients
+func clientError(c router.Context, err Error) *ErrorModel[any] {
+	if r, ok := requestErrorRenderer(c).(*errorRenderer); ok && r.strip {
+		return err.model().withoutInternals()
+	}
+	return err.model()
+}
+
+// acceptsProblem checks whether an Accept header prefers problem details to plain json
+func acceptsProblem(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		if mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil &&
+			mediaType == ContentTypeProblem && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestInstance identifies the failed request by its id, or its path without one
+func requestInstance(c router.Context) string {
+	if id := c.GetHeader(RequestID); id != "" {
+		return "urn:request:" + id
+	} else if req := c.Request(); req != nil && req.URL != nil {
+		return req.URL.Path
+	}
+	return ""
+}
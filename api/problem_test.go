@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+var _ = Describe("Error rendering", func() {
+
+	var recorder *httptest.ResponseRecorder
+	books := bookService()
+	failing := func(context.Context) (Book, api.Error) {
+		return Book{}, api.ServerError(errors.New("connection refused by db-01")).
+			WithCause(api.SQLError[Book](errors.New("dial tcp 10.0.0.4:5432")))
+	}
+	engine := func(renderer api.ErrorRenderer) *gin.Engine {
+		return utils.Test.GinRouter(func(e *gin.Engine) {
+			if renderer != nil {
+				e.Use(api.ErrorRendering(renderer).Gin())
+			}
+			e.POST("/books", api.ParamHandlerWithResponse[CreateBookRequest](books.createBook))
+			e.GET("/failing", api.BasicHandler(failing))
+		})
+	}
+	invalidBook := func(header ...string) *http.Request {
+		req := utils.Test.Request(http.MethodPost, "/books", utils.Test.JsonDataOf("author", "TestBot", "pages", 50))
+		if len(header) > 0 {
+			req.Header.Set("Accept", header[0])
+		}
+		req.Header.Set(api.RequestID, "req-1")
+		return req
+	}
+
+	BeforeEach(func() { recorder = httptest.NewRecorder() })
+
+	It("renders the response envelope by default", func() {
+		var res *ResultModel[CreateBookRequest, any]
+		engine(nil).ServeHTTP(recorder, invalidBook())
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res.Error().Code).To(Equal(api.ErrorCodeValidatorError))
+		Expect((*res)["type"]).To(Equal("api_test.CreateBookRequest"))
+	})
+
+	It("renders problem details to requests accepting them", func() {
+		var res map[string]any
+		engine(nil).ServeHTTP(recorder, invalidBook("application/problem+json, application/json;q=0.5"))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal(api.ContentTypeProblem))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res).To(HaveKeyWithValue("type", "about:blank"))
+		Expect(res).To(HaveKeyWithValue("title", "Bad Request"))
+		Expect(res).To(HaveKeyWithValue("status", BeEquivalentTo(http.StatusBadRequest)))
+		Expect(res).To(HaveKeyWithValue("instance", "urn:request:req-1"))
+		Expect(res).To(HaveKeyWithValue("code", api.ErrorCodeValidatorError))
+		Expect(res).To(HaveKeyWithValue("fieldErrors", HaveLen(2)))
+	})
+
+	It("renders problems when configured to", func() {
+		var res map[string]any
+		renderer := api.NewErrorRenderer(api.ErrorFormatProblem, api.WithProblemTypeBase("https://errors.startup.io/"))
+		engine(renderer).ServeHTTP(recorder, invalidBook())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal(api.ContentTypeProblem))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res).To(HaveKeyWithValue("type", "https://errors.startup.io/validation-error"))
+		Expect(res).To(HaveKeyWithValue("title", "Validation error"))
+	})
+
+	It("exposes causes unless internal errors are stripped", func() {
+		var res map[string]any
+		engine(api.NewErrorRenderer(api.ErrorFormatProblem)).ServeHTTP(recorder, utils.Test.Request(http.MethodGet, "/failing", nil))
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res).To(HaveKeyWithValue("detail", ContainSubstring("db-01")))
+		Expect(res).To(HaveKey("cause"))
+
+		recorder = httptest.NewRecorder()
+		engine(api.NewErrorRenderer(api.ErrorFormatEnvelope, api.StripInternalErrors(true))).
+			ServeHTTP(recorder, utils.Test.Request(http.MethodGet, "/failing", nil))
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Body.String()).ToNot(ContainSubstring("db-01"))
+		Expect(recorder.Body.String()).ToNot(ContainSubstring("api_test."))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res).To(HaveKeyWithValue("error", And(
+			HaveKeyWithValue("message", "Internal Server Error"), Not(HaveKey("cause")))))
+	})
+})
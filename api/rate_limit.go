@@ -0,0 +1,161 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kod2ulz/gostart/router"
+	"github.com/pkg/errors"
+)
+
+// rateLimitSweepSize is the number of clients of a class after which idle clients are forgotten
+const rateLimitSweepSize = 10000
+
+// RateLimit allows each client Requests in every period Per, in bursts of up to Requests
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRateLimit reads limits written <requests>/<period> e.g. 10/s, 100/m, 1000/h or 50/10s.
+// an empty string is no limit
+func ParseRateLimit(s string) (limit RateLimit, err error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return limit, errors.Errorf("rate limit %q is not written <requests>/<period>", s)
+	} else if limit.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || limit.Requests < 0 {
+		return RateLimit{}, errors.Errorf("rate limit %q does not start with a number of requests", s)
+	}
+	if period = strings.TrimSpace(period); period != "" && strings.IndexAny(period[:1], "0123456789") < 0 {
+		period = "1" + period
+	}
+	if limit.Per, err = time.ParseDuration(period); err != nil || limit.Per <= 0 {
+		return RateLimit{}, errors.Errorf("rate limit %q does not end with a period", s)
+	}
+	return
+}
+
+// Unlimited reports whether the limit lets every request through
+func (l RateLimit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+func (l RateLimit) String() string {
+	if l.Unlimited() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// NewRateLimiter limits the requests of each client to the limit of their rate limit class.
+// clients are told apart by key, their address by default. limits may be changed with
+// SetLimit while the routes are served e.g. when their config is reloaded
+//
+//	limiter := api.NewRateLimiter(nil)
+//	limiter.SetLimit("auth", api.RateLimit{Requests: 5, Per: time.Minute})
+//	registry.WithRateLimiter(limiter.Limit)
+func NewRateLimiter(key func(router.Context) string) *RateLimiter {
+	if key == nil {
+		key = clientAddress
+	}
+	return &RateLimiter{key: key, limits: make(map[string]RateLimit), buckets: make(map[string]map[string]*rateBucket)}
+}
+
+// RateLimiter enforces the limits of rate limit classes, see NewRateLimiter
+type RateLimiter struct {
+	mx      sync.Mutex
+	key     func(router.Context) string
+	limits  map[string]RateLimit
+	buckets map[string]map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// SetLimit changes the limit of class, starting every client afresh. an unlimited limit
+// lifts it
+func (l *RateLimiter) SetLimit(class string, limit RateLimit) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.limits[class] = limit
+	delete(l.buckets, class)
+}
+
+// Limits are the limits of every class set so far
+func (l *RateLimiter) Limits() map[string]RateLimit {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	out := make(map[string]RateLimit, len(l.limits))
+	for class, limit := range l.limits {
+		out[class] = limit
+	}
+	return out
+}
+
+// Limit is the middleware enforcing the limit class has when each request is made,
+// rejecting requests over it with ErrRateLimited and a Retry-After header
+func (l *RateLimiter) Limit(class string) router.HandlerFunc {
+	return func(c router.Context) {
+		if wait, ok := l.allow(class, l.key(c), time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			RenderError[any](c, ErrRateLimited.New())
+			return
+		}
+		c.Next()
+	}
+}
+
+// allow takes a token from the bucket of the client, else returns the time until one is available
+func (l *RateLimiter) allow(class, key string, now time.Time) (time.Duration, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	limit := l.limits[class]
+	if limit.Unlimited() {
+		return 0, true
+	}
+	buckets := l.buckets[class]
+	if buckets == nil {
+		buckets = make(map[string]*rateBucket)
+		l.buckets[class] = buckets
+	}
+	b, ok := buckets[key]
+	if !ok {
+		if len(buckets) >= rateLimitSweepSize {
+			for k, idle := range buckets {
+				if now.Sub(idle.at) >= limit.Per {
+					delete(buckets, k)
+				}
+			}
+		}
+		b = &rateBucket{tokens: float64(limit.Requests), at: now}
+		buckets[key] = b
+	}
+	rate := float64(limit.Requests) / limit.Per.Seconds()
+	b.tokens, b.at = math.Min(float64(limit.Requests), b.tokens+now.Sub(b.at).Seconds()*rate), now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// clientAddress is the host of the address the request came from
+func clientAddress(c router.Context) string {
+	req := c.Request()
+	if req == nil {
+		return ""
+	} else if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/router"
+)
+
+var _ = Describe("Rate limiter", func() {
+
+	It("parses limits", func() {
+		for s, limit := range map[string]api.RateLimit{
+			"":         {},
+			"10/s":     {Requests: 10, Per: time.Second},
+			" 100 / m": {Requests: 100, Per: time.Minute},
+			"50/10s":   {Requests: 50, Per: 10 * time.Second},
+			"1000/h":   {Requests: 1000, Per: time.Hour},
+		} {
+			parsed, err := api.ParseRateLimit(s)
+			Expect(err).NotTo(HaveOccurred(), s)
+			Expect(parsed).To(Equal(limit), s)
+		}
+		for _, s := range []string{"10", "ten/s", "-1/s", "10/", "10/fortnight", "10/0s"} {
+			_, err := api.ParseRateLimit(s)
+			Expect(err).To(HaveOccurred(), s)
+		}
+		Expect(api.RateLimit{}.Unlimited()).To(BeTrue())
+		Expect(api.RateLimit{Requests: 5, Per: time.Minute}.String()).To(Equal("5/1m0s"))
+	})
+
+	It("limits each client of a class until the limit changes", func() {
+		limiter := api.NewRateLimiter(nil)
+		limiter.SetLimit("auth", api.RateLimit{Requests: 2, Per: time.Hour})
+		m := router.NewMux()
+		m.GET("/login", limiter.Limit("auth"), func(c router.Context) { c.JSON(http.StatusOK, "ok") })
+		m.GET("/ping", limiter.Limit("browse"), func(c router.Context) { c.JSON(http.StatusOK, "pong") })
+		get := func(path, client string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.RemoteAddr = client + ":4321"
+			m.ServeHTTP(rec, req)
+			return rec
+		}
+
+		Expect(get("/login", "10.0.0.1").Code).To(Equal(http.StatusOK))
+		Expect(get("/login", "10.0.0.1").Code).To(Equal(http.StatusOK))
+		rec := get("/login", "10.0.0.1")
+		Expect(rec.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(rec.Header().Get("Retry-After")).To(Equal("1800"))
+		Expect(rec.Body.String()).To(ContainSubstring(api.ErrorCodeRateLimited))
+		Expect(get("/login", "10.0.0.2").Code).To(Equal(http.StatusOK), "other clients")
+		Expect(get("/ping", "10.0.0.1").Code).To(Equal(http.StatusOK), "classes without a limit")
+
+		limiter.SetLimit("auth", api.RateLimit{})
+		Expect(get("/login", "10.0.0.1").Code).To(Equal(http.StatusOK))
+		Expect(limiter.Limits()).To(HaveKeyWithValue("auth", api.RateLimit{}))
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/router"
 	"github.com/pkg/errors"
 )
 
@@ -13,23 +14,17 @@ type RoutineWithResponseFunc[T any] func(context.Context) (T, Error)
 type RoutineWithListResponseFunc[T any] func(context.Context) ([]T, Error)
 
 func BasicHandler[T any](serviceFunc RoutineWithResponseFunc[T]) gin.HandlerFunc {
-	return serviceHandler(serviceFunc, func(ctx *gin.Context, out T) {
-		ctx.JSON(http.StatusOK, DataResponse(out))
-	})
+	return BasicHandlerFunc(serviceFunc).Gin()
 }
 
 func HandlerWithParam[P RequestParam](serviceFunc gin.HandlerFunc) gin.HandlerFunc {
-	return genericHandlerWithParam[P](serviceFunc)
+	return HandlerFuncWithParam[P](func(ctx router.Context) {
+		serviceFunc(router.Unwrap(ctx).(*gin.Context))
+	}).Gin()
 }
 
 func ParamHandlerWithResponse[P RequestParam, T any](serviceFunc RoutineWithResponseFunc[T]) gin.HandlerFunc {
-	return serviceHandlerWithParam(serviceFunc, func(ctx *gin.Context, param P, out T) {
-		refs := map[string]any{}
-		if val, ok := ctx.Get(param.ReferencesContextKey()); ok {
-			refs, _ = val.(map[string]any)
-		}
-		ctx.JSON(http.StatusOK, DataResponse(out).WithReferences(refs))
-	})
+	return ParamHandlerFuncWithResponse[P](serviceFunc).Gin()
 }
 
 // func HandlerWithListResponse[T any](serviceFunc RoutineWithListResponseFunc[T]) gin.HandlerFunc {
@@ -39,7 +34,35 @@ func ParamHandlerWithResponse[P RequestParam, T any](serviceFunc RoutineWithResp
 // }
 
 func ParamHandlerWithListResponse[P RequestParam, T any](serviceFunc RoutineWithListResponseFunc[T]) gin.HandlerFunc {
-	return serviceHandlerWithParam(serviceFunc, func(ctx *gin.Context, param P, res []T) {
+	return ParamHandlerFuncWithListResponse[P](serviceFunc).Gin()
+}
+
+// BasicHandlerFunc is BasicHandler for any router
+func BasicHandlerFunc[T any](serviceFunc RoutineWithResponseFunc[T]) router.HandlerFunc {
+	return serviceHandler(serviceFunc, func(ctx router.Context, out T) {
+		WriteResponse(ctx, http.StatusOK, DataResponse(out))
+	})
+}
+
+// HandlerFuncWithParam is HandlerWithParam for any router
+func HandlerFuncWithParam[P RequestParam](serviceFunc router.HandlerFunc) router.HandlerFunc {
+	return genericHandlerWithParam[P](serviceFunc)
+}
+
+// ParamHandlerFuncWithResponse is ParamHandlerWithResponse for any router
+func ParamHandlerFuncWithResponse[P RequestParam, T any](serviceFunc RoutineWithResponseFunc[T]) router.HandlerFunc {
+	return serviceHandlerWithParam(serviceFunc, func(ctx router.Context, param P, out T) {
+		refs := map[string]any{}
+		if val, ok := ctx.Get(param.ReferencesContextKey()); ok {
+			refs, _ = val.(map[string]any)
+		}
+		WriteResponse(ctx, http.StatusOK, DataResponse(out).WithReferences(refs))
+	})
+}
+
+// ParamHandlerFuncWithListResponse is ParamHandlerWithListResponse for any router
+func ParamHandlerFuncWithListResponse[P RequestParam, T any](serviceFunc RoutineWithListResponseFunc[T]) router.HandlerFunc {
+	return serviceHandlerWithParam(serviceFunc, func(ctx router.Context, param P, res []T) {
 		meta, refs := &Metadata{}, map[string]any{}
 		if val, ok := ctx.Get(param.MetadataContextKey()); ok {
 			meta, _ = val.(*Metadata)
@@ -47,57 +70,57 @@ func ParamHandlerWithListResponse[P RequestParam, T any](serviceFunc RoutineWith
 		if val, ok := ctx.Get(param.ReferencesContextKey()); ok {
 			refs, _ = val.(map[string]any)
 		}
-		ctx.JSON(http.StatusOK, ListResponse(res, *meta).WithReferences(refs))
+		WriteResponse(ctx, http.StatusOK, ListResponse(res, *meta).WithReferences(refs))
 	})
 }
 
-func serviceHandler[T any](serviceFunc func(context.Context) (T, Error), resultHandler func(*gin.Context, T)) gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		if out, err := serviceFunc(ctx); err != nil {
-			ctx.JSON(http.StatusInternalServerError, ctx.Error(err))
+func serviceHandler[T any](serviceFunc func(context.Context) (T, Error), resultHandler func(router.Context, T)) router.HandlerFunc {
+	return func(ctx router.Context) {
+		if out, err := serviceFunc(router.Unwrap(ctx)); err != nil {
+			RenderError[T](ctx, err)
 		} else {
 			resultHandler(ctx, out)
 		}
 	}
 }
 
-func serviceHandlerWithParam[P RequestParam, T any](serviceFunc func(context.Context) (T, Error), successHandler func(*gin.Context, P, T)) gin.HandlerFunc {
-	return func(ctx *gin.Context) {
+func serviceHandlerWithParam[P RequestParam, T any](serviceFunc func(context.Context) (T, Error), successHandler func(router.Context, P, T)) router.HandlerFunc {
+	return func(ctx router.Context) {
 		var err Error
 		var param P
 		if param, err = loadParamFromRequest[P](ctx); err != nil {
-			ctx.JSON(err.http(), ErrorResponse[P](err))
+			RenderError[P](ctx, err)
 			return
 		}
 		ctx.Set(param.ContextKey(), param)
-		if out, err := serviceFunc(ctx); err != nil {
-			ctx.JSON(err.http(), err)
+		if out, err := serviceFunc(router.Unwrap(ctx)); err != nil {
+			RenderError[T](ctx, err)
 		} else {
 			successHandler(ctx, param, out)
 		}
 	}
 }
 
-func loadParamFromRequest[P RequestParam](ctx *gin.Context) (param P, err Error) {
+func loadParamFromRequest[P RequestParam](ctx router.Context) (param P, err Error) {
 	var e error
 	var p RequestParam
-	if p, e = (*new(P)).RequestLoad(ctx); e != nil {
+	if p, e = (*new(P)).RequestLoad(router.Unwrap(ctx)); e != nil {
 		return param, RequestLoadError[P](errors.Wrapf(e, "failed to load %T from request", param))
 	}
 	ctx.Set(p.ContextKey(), p)
 	if e = p.Validate(ctx); e != nil {
-		return param, ValidatorError[P](errors.Wrapf(e, "validation failed for %T", param))
+		return param, ValidatorError[P](errors.Wrapf(e, "validation failed for %T", param), AcceptLanguages(ctx.GetHeader("Accept-Language"))...)
 	}
 	param = p.(P)
 	return
 }
 
-func genericHandlerWithParam[P RequestParam](serviceFunc gin.HandlerFunc) gin.HandlerFunc {
-	return func(ctx *gin.Context) {
+func genericHandlerWithParam[P RequestParam](serviceFunc router.HandlerFunc) router.HandlerFunc {
+	return func(ctx router.Context) {
 		var err Error
 		var param P
 		if param, err = loadParamFromRequest[P](ctx); err != nil {
-			ctx.JSON(err.http(), ErrorResponse[P](err))
+			RenderError[P](ctx, err)
 			return
 		}
 		ctx.Set(param.ContextKey(), param)
@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/kod2ulz/gostart/services/auth"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+// strictTokenRequest loads any token from the query for validation to reject short ones
+type strictTokenRequest struct {
+	Token string `json:"token" validate:"required,min=64"`
+	api.RequestModal[strictTokenRequest]
+}
+
+func (r strictTokenRequest) RequestLoad(ctx context.Context) (api.RequestParam, error) {
+	out := strictTokenRequest{Token: r.Query(ctx, "token").String()}
+	out.SetInRequest(ctx, out)
+	return out, nil
+}
+
+var _ = Describe("Request Handler on net/http", func() {
+
+	var user auth.UserData
+	var recorder *httptest.ResponseRecorder
+	books := bookService()
+	headers := api.Headers{}
+	userStore := auth.InMemoryUserStore()
+	sessionService := auth.SessionService(nil, userStore)
+
+	mux := router.NewMux()
+	mux.POST("/session/verify", api.ParamHandlerFuncWithResponse[auth.VerifyTokenRequest](sessionService.Verify))
+	group := mux.Group("/books", api.WithUserFunc[auth.VerifyTokenRequest](sessionService))
+	group.Handle(http.MethodGet, "", api.ParamHandlerFuncWithListResponse[ListBooksRequest](books.listBooks))
+	group.Handle(http.MethodGet, "/:id", api.ParamHandlerFuncWithResponse[DetailedBookRequest](books.getBookByID))
+	mux.Group("/strict", api.WithUserFunc[strictTokenRequest](sessionService)).Handle(http.MethodGet, "", func(c router.Context) {
+		c.JSON(http.StatusOK, "reached")
+	})
+
+	BeforeEach(func(ctx context.Context) {
+		signupReq := createSignupRequest()
+		user = registerUser(ctx, signupReq, sessionService)
+		token := authenticateUser(ctx, signupReq, sessionService)
+		headers.WithBearerToken(token.AccessToken)
+		recorder = httptest.NewRecorder()
+	})
+
+	AfterEach(func() { books.clear() })
+
+	It("loads params from the request without gin", func() {
+		var res api.Response[auth.User]
+		mux.ServeHTTP(recorder, utils.Test.Request(http.MethodPost, "/session/verify", []byte("{}"), headers))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res.Success).To(BeTrue())
+	})
+
+	It("reads query parameters and list metadata", func() {
+		var res api.Response[[]Book]
+		_, createErr := books.seed(15, &user)
+		Expect(createErr).To(BeNil())
+		mux.ServeHTTP(recorder, utils.Test.Request(http.MethodGet, "/books?limit=10&page=2", nil, headers))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res.Meta).ToNot(BeNil())
+		Expect(res.Meta.Limit).To(BeEquivalentTo(10))
+		Expect(res.Meta.Offset).To(BeEquivalentTo(10))
+		Expect(res.Meta.Total).To(BeEquivalentTo(15))
+	})
+
+	It("reads path parameters", func() {
+		newBooks, _ := books.seed(1, &user)
+		var res api.Response[Book]
+		mux.ServeHTTP(recorder, utils.Test.Request(http.MethodGet, "/books/"+newBooks[0].ID.String(), nil, headers))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		var book Book
+		Expect(res.ParseDataTo(&book)).To(Succeed())
+		Expect(book).To(Equal(*newBooks[0]))
+	})
+
+	It("rejects tokens that fail validation", func() {
+		mux.ServeHTTP(recorder, utils.Test.Request(http.MethodGet, "/strict?token=short", nil))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("reached"))
+	})
+
+	It("rejects requests without a token", func() {
+		mux.ServeHTTP(recorder, utils.Test.Request(http.MethodGet, "/books", nil))
+		Expect(recorder.Code).To(BeNumerically(">=", 400))
+	})
+})
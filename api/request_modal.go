@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/router"
 	"github.com/kod2ulz/gostart/utils"
 	"github.com/pkg/errors"
 )
@@ -23,14 +23,16 @@ func (r RequestModal[T]) Validate(ctx context.Context) error {
 func (r RequestModal[T]) RequestLoad(ctx context.Context) (param RequestParam, err error) {
 	t := new(T)
 	if err = r.LoadFromJsonBody(ctx, t); err == nil {
-		ctx.(*gin.Context).Set((*t).ContextKey(), t)
+		setInRequest(ctx, (*t).ContextKey(), t)
 		return *t, err
 	}
 	return nil, err
 }
 
 func (r RequestModal[T]) LoadFromJsonBody(ctx context.Context, out interface{}) (err error) {
-	if err = ctx.(*gin.Context).ShouldBindJSON(out); err != nil {
+	if c, ok := router.FromContext(ctx); !ok {
+		return errors.Errorf("failed to load json body into %T. no request in context", out)
+	} else if err = c.ShouldBindJSON(out); err != nil {
 		return errors.Wrapf(err, "failed to load json body into %T from request", out)
 	}
 	return
@@ -50,7 +52,7 @@ func (r RequestModal[T]) ReferencesContextKey() string {
 }
 
 func (r RequestModal[T]) SetResponseMetadata(ctx context.Context, meta *Metadata) (err error) {
-	ctx.(*gin.Context).Set(r.MetadataContextKey(), meta)
+	setInRequest(ctx, r.MetadataContextKey(), meta)
 	return
 }
 
@@ -64,7 +66,7 @@ func (r RequestModal[T]) SetResponseReference(ctx context.Context, key string, v
 		ref = make(map[string]any)
 	}
 	ref[key] = value
-	ctx.(*gin.Context).Set(r.ReferencesContextKey(), ref)
+	setInRequest(ctx, r.ReferencesContextKey(), ref)
 	return
 }
 
@@ -108,8 +110,13 @@ func (p RequestModal[T]) InContext(ctx context.Context, in T) context.Context {
 	return context.WithValue(ctx, in.ContextKey(), in)
 }
 
+// SetInRequest stores in on the request ctx belongs to so that it can be loaded by later handlers
+func (p RequestModal[T]) SetInRequest(ctx context.Context, in T) {
+	setInRequest(ctx, in.ContextKey(), in)
+}
+
 func (p RequestModal[T]) Query(ctx context.Context, name string, _default ...string) (out utils.Value) {
-	if v := ctx.(*gin.Context).Query(name); v != "" {
+	if v := requestValue(ctx, router.Context.Query, name); v != "" {
 		return utils.Value(v)
 	} else if len(_default) > 0 {
 		return utils.Value(_default[0])
@@ -118,7 +125,7 @@ func (p RequestModal[T]) Query(ctx context.Context, name string, _default ...str
 }
 
 func (p RequestModal[T]) Path(ctx context.Context, name string, _default ...string) (out utils.Value) {
-	if v := ctx.(*gin.Context).Param(name); v != "" {
+	if v := requestValue(ctx, router.Context.Param, name); v != "" {
 		return utils.Value(v)
 	} else if len(_default) > 0 {
 		return utils.Value(_default[0])
@@ -141,10 +148,8 @@ func (p RequestModal[T]) Headers(ctx context.Context, names ...string) (out map[
 		}
 		return ""
 	}
-	if ct, ok := ctx.(*gin.Context); ok {
-		getHeaderValue = func(s string) string {
-			return ct.Request.Header.Get(s)
-		}
+	if c, ok := router.FromContext(ctx); ok {
+		getHeaderValue = c.GetHeader
 	}
 	for _, header := range names {
 		if header := strings.Trim(header, " "); header == "" {
@@ -157,7 +162,7 @@ func (p RequestModal[T]) Headers(ctx context.Context, names ...string) (out map[
 }
 
 func (p RequestModal[T]) Authorization(ctx context.Context) (out string) {
-	return ctx.(*gin.Context).Request.Header.Get("Authorization")
+	return requestValue(ctx, router.Context.GetHeader, Authorization)
 }
 
 func (p RequestModal[T]) WithHeaderValues(ctx context.Context, headers ...string) context.Context {
@@ -173,3 +178,18 @@ func (p RequestModal[T]) WithHeaderValues(ctx context.Context, headers ...string
 	}
 	return ctx
 }
+
+// setInRequest sets key on the request ctx belongs to. it does nothing outside of a request
+func setInRequest(ctx context.Context, key string, value any) {
+	if c, ok := router.FromContext(ctx); ok {
+		c.Set(key, value)
+	}
+}
+
+// requestValue reads name from the request ctx belongs to with get e.g. router.Context.Query
+func requestValue(ctx context.Context, get func(router.Context, string) string, name string) string {
+	if c, ok := router.FromContext(ctx); ok {
+		return get(c, name)
+	}
+	return ""
+}
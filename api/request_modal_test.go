@@ -105,6 +105,21 @@ var _ = Describe("RequestModal", func() {
 				Expect(res.Error().Message).ToNot(BeEmpty())
 				Expect(res.Error().Code).To(Equal(api.ErrorCodeValidatorError))
 				Expect(len(res.Error().Fields)).To(Equal(2))
+				Expect(res.Error().FieldErrors).To(HaveLen(2))
+				Expect(res.Error().Fields).To(HaveKey("name"))
+			})
+
+			It("localises validation messages to the Accept-Language of the request", func() {
+				var res *ResultModel[CreateBookRequest, any]
+				payload := utils.Test.JsonDataOf("author", "TestBot2", "pages", 50)
+				req := utils.Test.Request(http.MethodPost, "/books", payload)
+				req.Header.Set("Accept-Language", "de;q=0.9, fr-CH, en;q=0.8")
+				router.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(BeNil())
+				Expect(res.Error().FieldErrors).To(ContainElement(api.FieldError{
+					Field: "name", Rule: "required", Message: "name est un champ obligatoire",
+				}))
 			})
 		})
 
@@ -3,7 +3,7 @@ package api
 import (
 	"context"
 
-	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/router"
 	"github.com/kod2ulz/gostart/utils"
 	"github.com/pkg/errors"
 )
@@ -30,7 +30,7 @@ func ParamsFromContext[P RequestParam](ctx context.Context) (P, error) {
 }
 
 func QueryFromContext(ctx context.Context, name, _default string) utils.Value {
-	if v := ctx.(*gin.Context).Query(name); v != "" {
+	if v := requestValue(ctx, router.Context.Query, name); v != "" {
 		return utils.Value(v)
 	}
 	return utils.Value(_default)
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strconv"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/kod2ulz/gostart/query"
 	"github.com/pkg/errors"
@@ -16,6 +15,8 @@ type ListRequest struct {
 	User   User  `json:"-" validate:"required"`
 	Limit  int32 `validate:"required,gte=1"`
 	Offset int32 `validate:"omitempty,gte=0"`
+	// Cursor pages by keyset instead of Offset when set
+	Cursor string
 	RequestModal[ListRequest]
 }
 
@@ -24,7 +25,9 @@ func (r ListRequest) Metadata() (out *Metadata) {
 		// Current: int64(r.Offset),
 		Limit: int64(r.Limit), Offset: int64(r.Offset),
 	}
-	if out.Offset > 0 && out.Limit > 0 {
+	if r.Cursor != "" {
+		out.Offset = 0
+	} else if out.Offset > 0 && out.Limit > 0 {
 		out.Page = (out.Offset / out.Limit) + 1
 	}
 	return
@@ -48,13 +51,17 @@ func (r ListRequest) RequestLoad(ctx context.Context) (param RequestParam, err e
 	}
 	out.Limit = query("limit", 20)
 	out.Offset = query("offset", 0)
-	if page := query("page", 1); page > 1 && out.Offset == 0 {
+	if out.Cursor = out.Query(ctx, "cursor").String(); out.Cursor != "" {
+		out.Offset = 0
+	} else if page := query("page", 1); page > 1 && out.Offset == 0 {
 		out.Offset = out.Limit * (page - 1)
 	}
-	ctx.(*gin.Context).Set(out.ContextKey(), &out)
+	setInRequest(ctx, out.ContextKey(), &out)
 	return out, err
 }
 
+// SearchURL reads the url search of fields, declared once per endpoint e.g. with
+// query.SearchSchemaOf[Book]()
 func (r ListRequest) SearchURL(ctx context.Context, fields query.UrlFields) query.URLSearchParam {
 	return fields.SearchParams(ctx, r.Query)
 }
@@ -95,7 +102,7 @@ func (r ListRequestWithID[ID]) RequestLoad(ctx context.Context) (param RequestPa
 	var out ListRequestWithID[ID] = ListRequestWithID[ID]{ListRequest: ListRequest{}}
 	if p, e := out.ListRequest.RequestLoad(ctx); e != nil {
 		return param, RequestLoadError[ListRequestWithID[ID]](errors.Wrapf(e, "failed to load %T from request", r))
-	} else if pathId = ctx.(*gin.Context).Param("id"); pathId == "" {
+	} else if pathId = out.Path(ctx, "id").String(); pathId == "" {
 		return param, errors.Errorf("could not load path parameter value with key:id")
 	} else {
 		out.ListRequest = p.(ListRequest)
@@ -116,6 +123,6 @@ func (r ListRequestWithID[ID]) RequestLoad(ctx context.Context) (param RequestPa
 		i, _ := strconv.Atoi(pathId)
 		out.setId(i)
 	}
-	ctx.(*gin.Context).Set(out.ContextKey(), out)
+	setInRequest(ctx, out.ContextKey(), out)
 	return out, err
 }
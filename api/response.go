@@ -12,21 +12,21 @@ import (
 )
 
 func ErrorResponse[T any](err Error) Response[T] {
-	return Response[T]{
-		Timestamp: time.Now().Unix(), Error: err,
-		Type: strings.TrimPrefix(fmt.Sprintf("%T", new(T)), "*")}
+	return Response[T]{Timestamp: time.Now().Unix(), Error: err, Type: typeName[T]()}
 }
 
 func DataResponse[T any](data T) Response[T] {
-	return Response[T]{
-		Timestamp: time.Now().Unix(), Data: data, Success: true,
-		Type: strings.TrimPrefix(fmt.Sprintf("%T", new(T)), "*")}
+	return Response[T]{Timestamp: time.Now().Unix(), Data: data, Success: true, Type: typeName[T]()}
 }
 
 func ListResponse[T any](data []T, meta Metadata) Response[[]T] {
 	return Response[[]T]{
 		Timestamp: time.Now().Unix(), Data: data, Meta: &meta, Success: true,
-		Type: strings.TrimPrefix(fmt.Sprintf("%T", new(T)), "*") + "[]"}
+		Type: typeName[T]() + "[]"}
+}
+
+func typeName[T any]() string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", new(T)), "*")
 }
 
 func EmptyResponse[T any]() (out Response[T]) {
@@ -65,6 +65,11 @@ func (r Response[T]) ParseDataTo(out *T) error {
 	return errors.Wrapf(utils.StructCopy(r.Data, out), "failed to parse %T to %T", r.Data, *out)
 }
 
+func (r Response[T]) withType(name string) Response[T] {
+	r.Type = name
+	return r
+}
+
 func (r Response[T]) Failed() bool {
 	return r.HasError()
 }
@@ -113,6 +118,9 @@ type Metadata struct {
 	Limit   int64 `json:"limit,omitempty"`
 	Offset  int64 `json:"offset,omitempty"`
 	Page    int64 `json:"page,omitempty"`
+	// keyset pagination cursors, set instead of Offset and Page. see query sqlBuilder.Keyset
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 func (m *Metadata) WithTotal(total int64) *Metadata {
@@ -135,6 +143,12 @@ func (m *Metadata) WithPage(page int64) *Metadata {
 	return m
 }
 
+// WithCursors sets the cursors of the pages around a keyset page e.g. from query sqlBuilder.Cursors
+func (m *Metadata) WithCursors(next, prev string) *Metadata {
+	m.NextCursor, m.PrevCursor = next, prev
+	return m
+}
+
 func (m *Metadata) WithCurrent(current int64) *Metadata {
 	m.Current = current
 	return m
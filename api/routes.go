@@ -0,0 +1,341 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/pkg/errors"
+)
+
+// RoleUser is implemented by users that carry roles. routes declaring roles require it
+type RoleUser interface {
+	User
+	HasRole(role string) bool
+}
+
+// Deprecation marks a route that is being phased out. it is announced to clients
+// with the Deprecation, Sunset and Link response headers
+type Deprecation struct {
+	Since       time.Time `json:"since,omitempty"`
+	Sunset      time.Time `json:"sunset,omitempty"`
+	Replacement string    `json:"replacement,omitempty"`
+	Message     string    `json:"message,omitempty"`
+}
+
+// Route declares an endpoint along with the metadata used to secure, list and document it
+type Route struct {
+	Method  string
+	Path    string
+	Version string
+	Summary string
+	Tags    []string
+	// Param is the RequestParam loaded by the handler. nil when it takes none
+	Param reflect.Type
+	// Response is the type of the data in the response envelope
+	Response reflect.Type
+	// Search lists the url search fields the handler reads e.g. through ListRequest.SearchURL
+	Search      *query.UrlFields
+	List        bool
+	Auth        bool
+	Roles       []string
+	RateLimit   string
+	Deprecation *Deprecation
+	Middleware  []router.HandlerFunc
+	Handler     router.HandlerFunc
+}
+
+// FullPath is the path of the route including its version prefix
+func (r Route) FullPath() string {
+	if r.Version == "" {
+		return r.Path
+	}
+	return joinRoutePath("/"+strings.Trim(r.Version, "/"), r.Path)
+}
+
+func (r Route) String() string {
+	return fmt.Sprintf("%s %s", r.Method, r.FullPath())
+}
+
+type RouteOption func(*Route)
+
+func WithSummary(summary string) RouteOption {
+	return func(r *Route) { r.Summary = summary }
+}
+
+func WithTags(tags ...string) RouteOption {
+	return func(r *Route) { r.Tags = append(r.Tags, tags...) }
+}
+
+// WithVersion prefixes the route path with version e.g. v1
+func WithVersion(version string) RouteOption {
+	return func(r *Route) { r.Version = version }
+}
+
+// Authenticated requires an authenticated user
+func Authenticated() RouteOption {
+	return func(r *Route) { r.Auth = true }
+}
+
+// WithRoles requires an authenticated user holding at least one of roles
+func WithRoles(roles ...string) RouteOption {
+	return func(r *Route) { r.Auth, r.Roles = true, append(r.Roles, roles...) }
+}
+
+// WithRateLimit assigns the route to a rate limit class enforced by the registry's rate limiter
+func WithRateLimit(class string) RouteOption {
+	return func(r *Route) { r.RateLimit = class }
+}
+
+// WithSearch documents the url search fields read by the route
+func WithSearch(fields query.UrlFields) RouteOption {
+	return func(r *Route) { r.Search = &fields }
+}
+
+func WithDeprecation(deprecation Deprecation) RouteOption {
+	return func(r *Route) { r.Deprecation = &deprecation }
+}
+
+// WithMiddleware runs handlers after the auth, role and rate limit checks and before the route handler
+func WithMiddleware(handlers ...router.HandlerFunc) RouteOption {
+	return func(r *Route) { r.Middleware = append(r.Middleware, handlers...) }
+}
+
+func newRoute(method, path string, handler router.HandlerFunc, param, response reflect.Type, opts []RouteOption) (out Route) {
+	out = Route{Method: method, Path: path, Handler: handler, Param: param, Response: response}
+	for i := range opts {
+		opts[i](&out)
+	}
+	return
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// ParamRoute declares a route served by ParamHandlerFuncWithResponse
+func ParamRoute[P RequestParam, T any](method, path string, serviceFunc RoutineWithResponseFunc[T], opts ...RouteOption) Route {
+	return newRoute(method, path, ParamHandlerFuncWithResponse[P](serviceFunc), typeOf[P](), typeOf[T](), opts)
+}
+
+// ListRoute declares a route served by ParamHandlerFuncWithListResponse
+func ListRoute[P RequestParam, T any](method, path string, serviceFunc RoutineWithListResponseFunc[T], opts ...RouteOption) (out Route) {
+	out = newRoute(method, path, ParamHandlerFuncWithListResponse[P](serviceFunc), typeOf[P](), typeOf[T](), opts)
+	out.List = true
+	return
+}
+
+// BasicRoute declares a route served by BasicHandlerFunc
+func BasicRoute[T any](method, path string, serviceFunc RoutineWithResponseFunc[T], opts ...RouteOption) Route {
+	return newRoute(method, path, BasicHandlerFunc(serviceFunc), nil, typeOf[T](), opts)
+}
+
+// HandlerRoute declares a route served by a plain handler
+func HandlerRoute(method, path string, handler router.HandlerFunc, opts ...RouteOption) Route {
+	return newRoute(method, path, handler, nil, nil, opts)
+}
+
+// Group prefixes the paths of routes and applies opts to each of them
+func Group(prefix string, routes []Route, opts ...RouteOption) (out []Route) {
+	out = make([]Route, len(routes))
+	for i := range routes {
+		out[i] = routes[i]
+		out[i].Path = joinRoutePath(prefix, routes[i].Path)
+		for j := range opts {
+			opts[j](&out[i])
+		}
+	}
+	return
+}
+
+func joinRoutePath(prefix, relative string) string {
+	if relative == "" {
+		return "/" + strings.Trim(prefix, "/")
+	}
+	return path.Join("/", prefix, relative)
+}
+
+// RouteProvider is implemented by services that declare their routes
+type RouteProvider interface {
+	Routes() []Route
+}
+
+// Registry mounts declared routes on a router and keeps their metadata for route
+// listings and docs
+type Registry struct {
+	mx        sync.RWMutex
+	routes    []Route
+	auth      router.HandlerFunc
+	rateLimit func(class string) router.HandlerFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// WithAuthenticator sets the middleware that loads the user of routes requiring authentication
+// e.g. WithUserFunc
+func (r *Registry) WithAuthenticator(auth router.HandlerFunc) *Registry {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.auth = auth
+	return r
+}
+
+// WithRateLimiter sets the factory of the middleware enforcing each rate limit class e.g.
+// RateLimiter.Limit. without one rate limit classes are only informational
+func (r *Registry) WithRateLimiter(limiter func(class string) router.HandlerFunc) *Registry {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.rateLimit = limiter
+	return r
+}
+
+// Mount registers routes on engine. it fails without registering anything when a route
+// requires authentication and no authenticator is set
+func (r *Registry) Mount(engine router.Engine, routes ...Route) (err error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for _, route := range routes {
+		if route.Handler == nil {
+			return errors.Errorf("route %s has no handler", route)
+		} else if route.Auth && r.auth == nil {
+			return errors.Errorf("route %s requires authentication but no authenticator is set", route)
+		}
+	}
+	for _, route := range routes {
+		engine.Handle(route.Method, route.FullPath(), r.handlers(route)...)
+		r.routes = append(r.routes, route)
+	}
+	return
+}
+
+// MountProviders mounts the routes of every provider
+func (r *Registry) MountProviders(engine router.Engine, providers ...RouteProvider) (err error) {
+	for _, provider := range providers {
+		if err = r.Mount(engine, provider.Routes()...); err != nil {
+			return errors.Wrapf(err, "failed to mount routes of %T", provider)
+		}
+	}
+	return
+}
+
+func (r *Registry) handlers(route Route) (out []router.HandlerFunc) {
+	if route.Deprecation != nil {
+		out = append(out, deprecationHeaders(*route.Deprecation))
+	}
+	if route.Auth {
+		out = append(out, r.auth)
+	}
+	if len(route.Roles) > 0 {
+		out = append(out, RequireRoles(route.Roles...))
+	}
+	if route.RateLimit != "" && r.rateLimit != nil {
+		if limiter := r.rateLimit(route.RateLimit); limiter != nil {
+			out = append(out, limiter)
+		}
+	}
+	out = append(out, route.Middleware...)
+	return append(out, route.Handler)
+}
+
+// Routes lists the mounted routes sorted by path then method
+func (r *Registry) Routes() (out []Route) {
+	r.mx.RLock()
+	out = make([]Route, len(r.routes))
+	copy(out, r.routes)
+	r.mx.RUnlock()
+	sort.SliceStable(out, func(i, j int) bool {
+		if pi, pj := out[i].FullPath(), out[j].FullPath(); pi != pj {
+			return pi < pj
+		}
+		return out[i].Method < out[j].Method
+	})
+	return
+}
+
+// RouteInfo is the json form of a Route served by ListHandler
+type RouteInfo struct {
+	Method      string       `json:"method"`
+	Path        string       `json:"path"`
+	Version     string       `json:"version,omitempty"`
+	Summary     string       `json:"summary,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Param       string       `json:"param,omitempty"`
+	Response    string       `json:"response,omitempty"`
+	List        bool         `json:"list,omitempty"`
+	Auth        bool         `json:"auth"`
+	Roles       []string     `json:"roles,omitempty"`
+	RateLimit   string       `json:"rateLimit,omitempty"`
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+}
+
+func (r Route) Info() (out RouteInfo) {
+	out = RouteInfo{
+		Method: r.Method, Path: r.FullPath(), Version: r.Version, Summary: r.Summary, Tags: r.Tags, List: r.List,
+		Auth: r.Auth, Roles: r.Roles, RateLimit: r.RateLimit, Deprecation: r.Deprecation,
+	}
+	if r.Param != nil {
+		out.Param = r.Param.String()
+	}
+	if r.Response != nil {
+		out.Response = r.Response.String()
+	}
+	return
+}
+
+// ListHandler serves the mounted routes e.g. on /routes
+func (r *Registry) ListHandler() router.HandlerFunc {
+	return func(c router.Context) {
+		routes := r.Routes()
+		out := make([]RouteInfo, len(routes))
+		for i := range routes {
+			out[i] = routes[i].Info()
+		}
+		c.JSON(http.StatusOK, DataResponse(out))
+	}
+}
+
+// RequireRoles rejects requests whose user does not hold at least one of roles
+func RequireRoles(roles ...string) router.HandlerFunc {
+	return func(c router.Context) {
+		user, err := GetUser(c)
+		if err != nil {
+			RenderError[User](c, ServiceErrorUnauthorised(err))
+			return
+		} else if ru, ok := user.(RoleUser); ok {
+			for _, role := range roles {
+				if ru.HasRole(role) {
+					c.Next()
+					return
+				}
+			}
+		}
+		RenderError[User](c, GeneralError[User](errors.Errorf("requires one of the roles %s", strings.Join(roles, ", "))).
+			WithErrorCodeAndHttpStatusCode(ErrorCodeForbidden, http.StatusForbidden))
+	}
+}
+
+func deprecationHeaders(d Deprecation) router.HandlerFunc {
+	deprecation := "true"
+	if !d.Since.IsZero() {
+		deprecation = fmt.Sprintf("@%d", d.Since.Unix())
+	}
+	return func(c router.Context) {
+		c.Header("Deprecation", deprecation)
+		if !d.Sunset.IsZero() {
+			c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if d.Replacement != "" {
+			c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", d.Replacement))
+		}
+		c.Next()
+	}
+}
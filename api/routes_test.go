@@ -0,0 +1,138 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+type roleUser struct {
+	id    uuid.UUID
+	roles []string
+}
+
+func (u roleUser) ID() uuid.UUID { return u.id }
+
+func (u roleUser) HasRole(role string) bool {
+	for i := range u.roles {
+		if u.roles[i] == role {
+			return true
+		}
+	}
+	return false
+}
+
+// headerAuth authenticates users with their roles listed in the X-Roles header
+func headerAuth(c router.Context) {
+	roles := c.GetHeader("X-Roles")
+	if roles == "" {
+		e := api.ServiceErrorUnauthorised(nil)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, e.Response())
+		return
+	}
+	c.Set(api.ContextAuthUserKey, roleUser{id: uuid.New(), roles: utils.Value(roles).StringList(",")})
+	c.Next()
+}
+
+type shelf struct{ limited []string }
+
+func (s *shelf) Routes() []api.Route {
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	return append(api.Group("/books", []api.Route{
+		api.ListRoute[ListBooksRequest](http.MethodGet, "", func(ctx context.Context) ([]Book, api.Error) {
+			return []Book{{Name: "Dune"}}, nil
+		}, api.WithRoles("reader", "admin"), api.WithRateLimit("browse")),
+		api.BasicRoute(http.MethodGet, "/count", func(ctx context.Context) (int, api.Error) {
+			return 1, nil
+		}, api.WithDeprecation(api.Deprecation{Sunset: sunset, Replacement: "/v2/books/stats"})),
+	}, api.WithVersion("v1"), api.WithTags("books")),
+		api.HandlerRoute(http.MethodGet, "/ping", func(c router.Context) { c.JSON(http.StatusOK, "pong") }),
+	)
+}
+
+var _ = Describe("Route registry", func() {
+	var mux http.Handler
+	var registry *api.Registry
+	var recorder *httptest.ResponseRecorder
+	var books *shelf
+
+	BeforeEach(func() {
+		m := router.NewMux()
+		books = &shelf{}
+		registry = api.NewRegistry().WithAuthenticator(headerAuth).WithRateLimiter(func(class string) router.HandlerFunc {
+			return func(c router.Context) {
+				books.limited = append(books.limited, class)
+				c.Next()
+			}
+		})
+		Expect(registry.MountProviders(m, books)).To(Succeed())
+		m.GET("/routes", registry.ListHandler())
+		mux, recorder = m, httptest.NewRecorder()
+	})
+
+	get := func(path string, roles string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if roles != "" {
+			req.Header.Set("X-Roles", roles)
+		}
+		mux.ServeHTTP(recorder, req)
+	}
+
+	It("mounts routes under their version and group", func() {
+		get("/v1/books", "reader")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(books.limited).To(Equal([]string{"browse"}))
+	})
+
+	It("requires authentication for routes with roles", func() {
+		get("/v1/books", "")
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects users without a required role", func() {
+		get("/v1/books", "guest")
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(books.limited).To(BeEmpty())
+	})
+
+	It("announces deprecated routes", func() {
+		get("/v1/books/count", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Deprecation")).To(Equal("true"))
+		Expect(recorder.Header().Get("Sunset")).To(Equal("Tue, 01 Jan 2030 00:00:00 GMT"))
+		Expect(recorder.Header().Get("Link")).To(ContainSubstring("/v2/books/stats"))
+	})
+
+	It("lists mounted routes with their metadata", func() {
+		var res api.Response[[]api.RouteInfo]
+		get("/routes", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		var routes []api.RouteInfo
+		Expect(res.ParseDataTo(&routes)).To(Succeed())
+		Expect(routes).To(HaveLen(3))
+		Expect(routes[0].Path).To(Equal("/ping"))
+		Expect(routes[1]).To(MatchFields(IgnoreExtras, Fields{
+			"Method": Equal(http.MethodGet), "Path": Equal("/v1/books"), "Version": Equal("v1"), "Auth": BeTrue(),
+			"Roles": ConsistOf("reader", "admin"), "RateLimit": Equal("browse"), "List": BeTrue(),
+			"Param": Equal("api.ListRequest"), "Response": Equal("api_test.Book"), "Tags": ConsistOf("books"),
+		}))
+		Expect(routes[2].Deprecation).ToNot(BeNil())
+	})
+
+	It("refuses to mount authenticated routes without an authenticator", func() {
+		err := api.NewRegistry().Mount(router.NewMux(), books.Routes()...)
+		Expect(err).To(MatchError(ContainSubstring("no authenticator")))
+	})
+})
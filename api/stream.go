@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TrailerTotalCount carries the number of items of streamed responses
+	TrailerTotalCount = "X-Total-Count"
+	// TrailerStreamError carries the error that interrupted a streamed csv response
+	TrailerStreamError = "X-Stream-Error"
+)
+
+// StreamFlushSize is the number of items written to streamed responses between flushes
+var StreamFlushSize = 100
+
+// Stream yields the items of a streamed response e.g. the rows of query SelectStream
+type Stream[T any] interface {
+	Next() bool
+	Value() T
+	Err() error
+	Close()
+}
+
+// countedStream is a Stream that knows the total of its items once it is closed
+type countedStream interface {
+	Total(ctx context.Context) (int64, error)
+}
+
+type RoutineWithStreamResponseFunc[T any] func(context.Context) (Stream[T], Error)
+
+// StreamOf streams items already in memory
+func StreamOf[T any](items ...T) Stream[T] {
+	return &sliceStream[T]{items: items, index: -1}
+}
+
+type sliceStream[T any] struct {
+	items []T
+	index int
+}
+
+func (s *sliceStream[T]) Next() bool {
+	if s.index+1 >= len(s.items) {
+		return false
+	}
+	s.index++
+	return true
+}
+
+func (s *sliceStream[T]) Value() T { return s.items[s.index] }
+
+func (s *sliceStream[T]) Err() error { return nil }
+
+func (s *sliceStream[T]) Close() {}
+
+func (s *sliceStream[T]) Total(context.Context) (int64, error) { return int64(len(s.items)), nil }
+
+func ParamHandlerWithStreamResponse[P RequestParam, T any](serviceFunc RoutineWithStreamResponseFunc[T]) gin.HandlerFunc {
+	return ParamHandlerFuncWithStreamResponse[P](serviceFunc).Gin()
+}
+
+// ParamHandlerFuncWithStreamResponse is ParamHandlerWithStreamResponse for any router
+func ParamHandlerFuncWithStreamResponse[P RequestParam, T any](serviceFunc RoutineWithStreamResponseFunc[T]) router.HandlerFunc {
+	return serviceHandlerWithParam(serviceFunc, func(ctx router.Context, param P, stream Stream[T]) {
+		WriteStream(ctx, http.StatusOK, stream)
+	})
+}
+
+// WriteStream writes the items of stream as they are read, flushing every StreamFlushSize
+// items. json responses keep the Response envelope, ndjson responses end with a line
+// holding the metadata or error and csv responses report them in trailers. the stream
+// is closed when it is exhausted or the client disconnects. a nil stream is written as an
+// empty list
+func WriteStream[T any](c router.Context, code int, stream Stream[T]) {
+	if stream == nil || (reflect.ValueOf(stream).Kind() == reflect.Pointer && reflect.ValueOf(stream).IsNil()) {
+		stream = StreamOf[T]()
+	}
+	ctx := context.Context(c)
+	if req := c.Request(); req != nil {
+		ctx = req.Context()
+	}
+	var enc streamEncoder
+	switch NegotiateFormat(c) {
+	case FormatNDJSON:
+		enc = &ndjsonStream{}
+	case FormatCSV:
+		enc = &csvStream{t: typeOf[T](), opts: csvStreamOptions()}
+	default:
+		enc = &jsonStream{typeName: typeName[T]() + "[]"}
+	}
+	w := c.Writer()
+	w.Header().Add("Trailer", TrailerTotalCount)
+	w.Header().Add("Trailer", TrailerStreamError)
+	c.Header("Content-Type", enc.contentType())
+	c.Status(code)
+
+	var err error
+	var count int64
+	if err = enc.begin(w); err == nil {
+		for stream.Next() {
+			if err = ctx.Err(); err != nil {
+				break
+			} else if err = enc.item(w, stream.Value()); err != nil {
+				break
+			} else if count++; count%int64(StreamFlushSize) == 0 {
+				flush(w)
+			}
+		}
+	}
+	stream.Close()
+	if err == nil {
+		err = stream.Err()
+	}
+	if ctx.Err() != nil {
+		c.Error(errors.Wrapf(ctx.Err(), "client left after %d items", count))
+		return
+	}
+
+	meta := &Metadata{Current: count, Total: count}
+	if counted, ok := stream.(countedStream); ok && err == nil {
+		meta.Total, err = counted.Total(ctx)
+	}
+	var failure *ErrorModel[any]
+	if err != nil {
+		failure = clientError(c, GeneralError[T](errors.Wrapf(err, "streaming interrupted after %d items", count)))
+		w.Header().Set(TrailerStreamError, failure.Message)
+		c.Error(err)
+	} else {
+		w.Header().Set(TrailerTotalCount, strconv.FormatInt(meta.Total, 10))
+	}
+	if err = enc.end(w, meta, failure); err != nil {
+		c.Error(err)
+	}
+	flush(w)
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type streamEncoder interface {
+	contentType() string
+	begin(w io.Writer) error
+	item(w io.Writer, v any) error
+	end(w io.Writer, meta *Metadata, failure *ErrorModel[any]) error
+}
+
+// jsonStream writes the Response envelope with its data array written item by item
+type jsonStream struct {
+	typeName string
+	count    int
+}
+
+func (s *jsonStream) contentType() string { return "application/json; charset=utf-8" }
+
+func (s *jsonStream) begin(w io.Writer) error {
+	typ, _ := json.Marshal(s.typeName)
+	_, err := io.WriteString(w, `{"type":`+string(typ)+`,"data":[`)
+	return err
+}
+
+func (s *jsonStream) item(w io.Writer, v any) (err error) {
+	if s.count++; s.count > 1 {
+		if _, err = io.WriteString(w, ","); err != nil {
+			return
+		}
+	}
+	return writeJSON(w, v)
+}
+
+func (s *jsonStream) end(w io.Writer, meta *Metadata, failure *ErrorModel[any]) (err error) {
+	if failure != nil {
+		if _, err = io.WriteString(w, `],"error":`); err == nil {
+			err = writeJSON(w, failure)
+		}
+		if err == nil {
+			_, err = io.WriteString(w, `,"time":`+strconv.FormatInt(time.Now().Unix(), 10)+`,"success":false}`)
+		}
+		return
+	}
+	if _, err = io.WriteString(w, `],"meta":`); err == nil {
+		err = writeJSON(w, meta)
+	}
+	if err == nil {
+		_, err = io.WriteString(w, `,"time":`+strconv.FormatInt(time.Now().Unix(), 10)+`,"success":true}`)
+	}
+	return
+}
+
+// ndjsonStream writes an item per line followed by a line with the meta or error
+type ndjsonStream struct{}
+
+func (ndjsonStream) contentType() string { return "application/x-ndjson" }
+
+func (ndjsonStream) begin(io.Writer) error { return nil }
+
+func (ndjsonStream) item(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (ndjsonStream) end(w io.Writer, meta *Metadata, failure *ErrorModel[any]) error {
+	if failure != nil {
+		return json.NewEncoder(w).Encode(map[string]any{"error": failure})
+	}
+	return json.NewEncoder(w).Encode(map[string]any{"meta": meta})
+}
+
+// csvRowWriter is the writer of query.NewCSVWriter
+type csvRowWriter interface {
+	WriteHeader() error
+	Write(any) error
+	Flush() error
+}
+
+type csvStream struct {
+	t    reflect.Type
+	opts []query.CSVOption
+	out  csvRowWriter
+}
+
+// csvStreamOptions are the options of the registered csv encoder, escaping formulas when
+// it was replaced by one of another type
+func csvStreamOptions() []query.CSVOption {
+	if enc, ok := LookupEncoder(FormatCSV); ok {
+		if enc, ok := enc.(csvEncoder); ok {
+			return enc.opts
+		}
+	}
+	return []query.CSVOption{query.CSVEscapeFormulas()}
+}
+
+func (s *csvStream) contentType() string { return "text/csv; charset=utf-8" }
+
+func (s *csvStream) begin(w io.Writer) error {
+	s.out = query.NewCSVWriter(w, s.t, s.opts...)
+	return s.out.WriteHeader()
+}
+
+func (s *csvStream) item(_ io.Writer, v any) (err error) {
+	if err = s.out.Write(v); err == nil {
+		// emptied after each row so that flushing the response sends every row written
+		err = s.out.Flush()
+	}
+	return
+}
+
+func (s *csvStream) end(io.Writer, *Metadata, *ErrorModel[any]) error {
+	return s.out.Flush()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	return err
+}
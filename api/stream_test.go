@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+// failingStream yields the books of the embedded stream then fails
+type failingStream struct {
+	api.Stream[Book]
+	closed bool
+}
+
+func (s *failingStream) Err() error { return errors.New("connection reset") }
+
+func (s *failingStream) Close() { s.closed = true }
+
+type exportBooksRequest struct {
+	api.RequestModal[exportBooksRequest]
+}
+
+func (r exportBooksRequest) RequestLoad(ctx context.Context) (api.RequestParam, error) {
+	return r, nil
+}
+
+var _ = Describe("Streamed responses", func() {
+
+	var recorder *httptest.ResponseRecorder
+	var failing *failingStream
+	books := make([]Book, 250)
+	for i := range books {
+		books[i] = Book{Name: fmt.Sprintf("Book %d", i), Pages: i}
+	}
+	mux := router.NewMux()
+	mux.GET("/books", api.ParamHandlerFuncWithStreamResponse[exportBooksRequest](func(context.Context) (api.Stream[Book], api.Error) {
+		return api.StreamOf(books...), nil
+	}))
+	mux.GET("/none", api.ParamHandlerFuncWithStreamResponse[exportBooksRequest](func(context.Context) (api.Stream[Book], api.Error) {
+		return nil, nil
+	}))
+	mux.GET("/failing", func(c router.Context) {
+		api.WriteStream(c, http.StatusOK, api.Stream[Book](failing))
+	})
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		failing = &failingStream{Stream: api.StreamOf(books[:3]...)}
+	})
+
+	get := func(path, accept string) *http.Request {
+		req := utils.Test.Request(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		return req
+	}
+
+	It("streams the json response envelope", func() {
+		var res api.Response[[]Book]
+		mux.ServeHTTP(recorder, get("/books", "application/json"))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res.Success).To(BeTrue())
+		Expect(res.Data).To(HaveLen(250))
+		Expect(res.Meta.Total).To(BeEquivalentTo(250))
+		Expect(recorder.Result().Trailer.Get(api.TrailerTotalCount)).To(Equal("250"))
+	})
+
+	It("streams nil streams as empty lists", func() {
+		var res api.Response[[]Book]
+		mux.ServeHTTP(recorder, get("/none", "application/json"))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"data":[]`))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(res.Success).To(BeTrue())
+		Expect(res.Meta.Total).To(BeEquivalentTo(0))
+		Expect(recorder.Result().Trailer.Get(api.TrailerTotalCount)).To(Equal("0"))
+	})
+
+	It("ends ndjson streams with a metadata line", func() {
+		var lines []map[string]any
+		mux.ServeHTTP(recorder, get("/books?format=ndjson", "*/*"))
+		scanner := bufio.NewScanner(recorder.Body)
+		for scanner.Scan() {
+			var line map[string]any
+			Expect(json.Unmarshal(scanner.Bytes(), &line)).To(Succeed())
+			lines = append(lines, line)
+		}
+		Expect(lines).To(HaveLen(251))
+		Expect(lines[0]).To(HaveKeyWithValue("name", "Book 0"))
+		Expect(lines[250]).To(HaveKeyWithValue("meta", HaveKeyWithValue("total", BeEquivalentTo(250))))
+	})
+
+	It("streams csv rows with the total in a trailer", func() {
+		mux.ServeHTTP(recorder, get("/books", "text/csv"))
+		records, err := csv.NewReader(recorder.Body).ReadAll()
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(251))
+		Expect(records[0]).To(ContainElements("name", "pages"))
+		Expect(recorder.Result().Trailer.Get(api.TrailerTotalCount)).To(Equal("250"))
+	})
+
+	It("reports errors interrupting the stream", func() {
+		var res *ResultModel[exportBooksRequest, []Book]
+		mux.ServeHTTP(recorder, get("/failing", "application/json"))
+		Expect(json.NewDecoder(recorder.Body).Decode(&res)).To(Succeed())
+		Expect(*res).To(And(HaveKeyWithValue("success", false), HaveKey("time")))
+		Expect(res.Data()).To(HaveLen(3))
+		Expect(res.Error().Message).To(ContainSubstring("interrupted after 3 items"))
+		Expect(recorder.Result().Trailer.Get(api.TrailerStreamError)).ToNot(BeEmpty())
+		Expect(failing.closed).To(BeTrue())
+	})
+
+	It("stops when the client disconnects", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mux.ServeHTTP(recorder, get("/failing", "application/x-ndjson").WithContext(ctx))
+		Expect(recorder.Body.String()).To(BeEmpty())
+		Expect(failing.closed).To(BeTrue())
+	})
+})
@@ -3,22 +3,24 @@ package app
 import (
 	"context"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/discovery"
 	"github.com/kod2ulz/gostart/logr"
+	"github.com/kod2ulz/gostart/openapi"
 	"github.com/kod2ulz/gostart/utils"
 	"github.com/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 	consulapi "github.com/hashicorp/consul/api"
-	"github.com/joho/godotenv"
 )
 
 var (
@@ -26,12 +28,36 @@ var (
 	_instance *ap
 )
 
+// App names the application type for use in signatures outside this package e.g. ProvideFunc constructors
+type App = ap
+
+// ap is an app as seen by the code holding it. component constructors get views of the
+// same state that also list the components being built, to detect dependency cycles
 type ap struct {
-	router *gin.Engine
-	log    *logr.Logger
-	start  time.Time
-	conf   *conf
-	consul *consulapi.Client
+	*appState
+	resolving *resolveChain
+}
+
+type appState struct {
+	router     *gin.Engine
+	log        *logr.Logger
+	start      time.Time
+	conf       *conf
+	consul     *consulapi.Client
+	http       *http.Server
+	listener   atomic.Pointer[net.Listener]
+	hooks      shutdownHooks
+	health     healthChecks
+	components components
+	routes     *api.Registry
+	errors     api.ErrorRenderer
+	discovery  *discovery.Client
+	discOnce   sync.Once
+	limiter    *api.RateLimiter
+	rateLimits rateLimitClasses
+	watchConf  bool
+	confOnce   sync.Once
+	hooksOnce  sync.Once
 
 	serviceId string
 
@@ -40,6 +66,8 @@ type ap struct {
 	cancel context.CancelFunc
 }
 
+// Init initialises the default application instance from the process config sources,
+// which it follows once the app runs. subsequent calls return the same instance
 func Init(strict ...bool) *ap {
 	if _instance != nil {
 		return _instance
@@ -48,27 +76,43 @@ func Init(strict ...bool) *ap {
 	if len(strict) > 0 {
 		_strict = strict[0]
 	}
-	if err := godotenv.Load(); err != nil {
-		if _strict {
-			log.Fatalf("error loading env files. %v", err)
-		}
-		log.Printf("error loading env files. %v", err)
-	}
+	utils.Env.Strict(_strict)
+	loadConfigSources(_strict)
 	if err := logr.Config(); err != nil {
 		logr.Log().WithError(err).Fatal("Application log initialisation failed")
 	}
-	logr.Log().Println("starting app initialisation")
-	_instance = &ap{
-		log:   logr.Log(),
-		start: time.Now(),
-		conf:  Conf(),
-		osc:   make(chan os.Signal, 1),
-	}
-	_instance.ctx, _instance.cancel = context.WithCancel(context.Background())
-	_instance.initAPI()
+	logr.Log().WithField("config.sources", utils.Env.Sources()).Println("starting app initialisation")
+	_instance = New(WithConfig(Conf()))
+	_instance.watchConf = true
+	discovery.SetDefault(_instance.Discovery())
 	return _instance
 }
 
+// New creates an application with its own router, logger, config and components.
+// unlike Init it neither loads config sources nor touches the default instance, so
+// several apps may live in the same process
+func New(opts ...Option) *ap {
+	o := &options{}
+	for i := range opts {
+		opts[i](o)
+	}
+	a := &ap{appState: &appState{log: o.log, start: time.Now(), conf: o.config(), osc: make(chan os.Signal, 1), routes: api.NewRegistry()}}
+	if a.log == nil {
+		a.log = logr.Log()
+	}
+	if o.auth != nil {
+		a.routes.WithAuthenticator(o.auth)
+	}
+	if a.errors = o.errors; a.errors == nil {
+		a.errors = a.conf.Http.ErrorRenderer()
+	}
+	a.limiter = api.NewRateLimiter(nil)
+	a.routes.WithRateLimiter(a.rateLimit)
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.initAPI()
+	return a
+}
+
 func (a *ap) Logger() *logr.Logger {
 	return a.log
 }
@@ -97,34 +141,67 @@ func (a *ap) Config() *conf {
 	return a.conf
 }
 
+// Consul returns the client of the default instance
 func Consul() (client *consulapi.Client) {
-	a := instance()
-	if client = a.consul; client == nil {
-		a.Log().Panic("consul client not initialsed")
-	}
-	return
+	return instance().Consul()
 }
 
+// Log returns the logger of the default instance or the global logger when there is none
 func Log() *logr.Logger {
-	return instance().log
+	if _instance == nil {
+		return logr.Log()
+	}
+	return _instance.log
 }
 
 func Service(name string) (out *consulapi.AgentService, err error) {
-	var ok bool
-	if services, err := Consul().Agent().Services(); err != nil {
-		return out, errors.Wrap(err, "error fetching registered consul services")
-	} else if out, ok = services[name]; !ok {
-		return out, errors.Errorf("service %s unknown to consul agent", name)
+	return instance().Service(name)
+}
+
+func ServiceUrl(name string) (out string) {
+	return instance().ServiceUrl(name)
+}
+
+func (a *ap) Consul() (client *consulapi.Client) {
+	if client = a.consul; client == nil {
+		a.log.Panic("consul client not initialsed")
 	}
 	return
 }
 
-func ServiceUrl(name string) (out string) {
-	service, err := Service(name)
+// Discovery returns the service discovery client of the app. it shares the consul client
+// used for registration and only serves DISCOVERY_STATIC_<SERVICE> endpoints when consul
+// is not configured
+func (a *ap) Discovery() *discovery.Client {
+	a.discOnce.Do(func() {
+		client := a.consul
+		if client == nil && utils.Env.Helper("CONSUL").GetString("HTTP_ADDR", "") != "" {
+			var err error
+			if client, err = consulapi.NewClient(consulConfig()); err != nil {
+				a.log.WithError(err).Error("consul client initialisation failed, using static service endpoints")
+			}
+		}
+		a.discovery = discovery.New(client, discovery.Config().Options()...)
+	})
+	return a.discovery
+}
+
+// Service picks a passing instance of the named service
+func (a *ap) Service(name string) (out *consulapi.AgentService, err error) {
+	var instance discovery.Instance
+	if instance, err = a.Discovery().Pick(a.ctx, name); err != nil {
+		return nil, errors.Wrapf(err, "failed to discover service %s", name)
+	}
+	return instance.AgentService(), nil
+}
+
+// ServiceUrl is the base url of a passing instance of the named service
+func (a *ap) ServiceUrl(name string) (out string) {
+	instance, err := a.Discovery().Pick(a.ctx, name)
 	if err == nil {
-		return fmt.Sprintf("http://%s:%v", service.Address, service.Port)
+		return instance.URL()
 	}
-	Log().WithError(err).WithField("consul.service", name).Error("failed to get service url")
+	a.log.WithError(err).WithField("consul.service", name).Error("failed to get service url")
 	return
 }
 
@@ -138,7 +215,7 @@ func (a *ap) Register(name ...string) (err error) {
 	} else if consulAddress := env.Get("HTTP_ADDR", ""); !consulAddress.Valid() {
 		return utils.Error.LogOK(a.log.Warnf, "env var %s_HTTP_ADDR not set. skipping consul initialization", env.Prefix())
 	}
-	config := consulapi.DefaultConfig()
+	config := consulConfig()
 	if len(name) > 0 && name[0] != "" {
 		serviceName = name[0]
 	} else {
@@ -160,7 +237,7 @@ func (a *ap) Register(name ...string) (err error) {
 		Address: serviceHost,
 		Tags:    []string{a.conf.Version, serviceName, serviceHost, a.start.In(a.conf.Location).Format(time.RFC1123Z)},
 		Check: &consulapi.AgentServiceCheck{
-			HTTP:     fmt.Sprintf("http://%s:%v/ok", a.conf.Host, a.conf.HttpPort),
+			HTTP:     fmt.Sprintf("http://%s:%v/health/ready", a.conf.Host, a.conf.HttpPort),
 			Interval: a.conf.Uptime.Interval.String(),
 			Timeout:  a.conf.Uptime.Timeout.String(),
 		},
@@ -174,24 +251,79 @@ func (a *ap) Run() {
 	fmt.Println()
 	utils.Error.Fail(a.log.Entry, a.Register(), "failed to register service with consul")
 	signal.Notify(a.osc, os.Interrupt, syscall.SIGTERM)
+	a.followConfig()
+	a.hooksOnce.Do(a.registerShutdownHooks)
+	if err := a.startComponents(); err != nil {
+		a.log.WithError(err).Error("failed to start components")
+		a.shutdown()
+		return
+	}
 	startupMsg := "started"
 	if a.router != nil {
-		startupMsg += " with http router " + a.conf.Address()
-		go a.router.Run(a.conf.Address())
+		ln, err := net.Listen("tcp", a.conf.Address())
+		if err != nil {
+			a.log.WithError(err).Errorf("failed to listen on %s", a.conf.Address())
+			a.shutdown()
+			return
+		}
+		startupMsg += " with http router " + ln.Addr().String()
+		a.http = &http.Server{Addr: ln.Addr().String(), Handler: a.router}
+		a.listener.Store(&ln)
+		go a.serve(ln)
 	}
 	a.log.Printf(startupMsg)
 	<-a.osc
-	a.cancel()
+	signal.Stop(a.osc)
 	fmt.Println()
-	a.shutdown()
-	if a.consul != nil && a.serviceId != "" {
-		a.consul.Agent().ServiceDeregister(a.serviceId)
+	if failed := a.shutdown(); failed > 0 {
+		a.log.Warnf("shutdown complete with %d failed hooks", failed)
+		return
 	}
 	a.log.Printf("shutdown complete")
 }
 
-func (a *ap) shutdown() {
-	a.log.Printf("shutting down")
+// Stop triggers the shutdown sequence of a running app
+func (a *ap) Stop() {
+	select {
+	case a.osc <- syscall.SIGTERM:
+	default:
+	}
+}
+
+// Addr is the address the http server is listening on, or nil when it is not running
+func (a *ap) Addr() net.Addr {
+	if ln := a.listener.Load(); ln != nil {
+		return (*ln).Addr()
+	}
+	return nil
+}
+
+func (a *ap) serve(ln net.Listener) {
+	if err := a.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		a.log.WithError(err).Error("http server failed")
+		a.Stop()
+	}
+}
+
+func (a *ap) registerShutdownHooks() {
+	OnShutdown(a, "consul", func() error {
+		if a.consul == nil || a.serviceId == "" {
+			return nil
+		}
+		return a.consul.Agent().ServiceDeregister(a.serviceId)
+	}, WithShutdownPriority(ShutdownPriorityDiscovery))
+	OnShutdown(a, "http", func(ctx context.Context) error {
+		if a.http == nil {
+			return nil
+		}
+		return a.http.Shutdown(ctx)
+	}, WithShutdownPriority(ShutdownPriorityHttp), WithShutdownTimeout(a.conf.Shutdown.DrainTimeout))
+	OnShutdown(a, "context", utils.ShFunc1(a.cancel), WithShutdownPriority(ShutdownPriorityContext))
+	OnShutdown(a, "discovery", func() {
+		if a.discovery != nil {
+			a.discovery.Close()
+		}
+	}, WithShutdownPriority(ShutdownPriorityContext))
 }
 
 func (a *ap) initAPI() {
@@ -207,20 +339,22 @@ func (a *ap) initAPI() {
 		}
 	)
 	a.router = gin.New()
-	a.router.Use(api.JSONLogMiddleware(a.log), gin.Recovery(), cors.New(cors.Config{
-		AllowOrigins:     a.conf.Http.AllowOrigins,
-		AllowMethods:     a.conf.Http.AllowMethods,
-		AllowHeaders:     a.conf.Http.AllowHeaders,
-		ExposeHeaders:    a.conf.Http.ExposeHeaders,
-		AllowCredentials: a.conf.Http.AllowCredentials,
-		// AllowOriginFunc: func(origin string) bool {
-		// 	return true
-		// },
-		MaxAge: a.conf.Http.MaxAge,
-	}))
+	a.router.Use(api.JSONLogMiddleware(a.log), gin.Recovery(), a.conf.Http.CORS(), api.ErrorRendering(a.errors).Gin())
 	a.router.GET("/", ok)
 	a.router.GET("/ok", ok)
 	a.router.GET("/stats", status)
+	a.router.GET("/health/live", a.healthHandler(HealthCheckLiveness))
+	a.router.GET("/health/ready", a.healthHandler(HealthCheckReadiness))
+	if a.conf.Http.ListRoutes {
+		a.router.GET("/routes", a.routes.ListHandler().Gin())
+	}
+	if a.conf.Http.OpenAPI {
+		info := openapi.Info{Title: a.conf.Name, Version: a.conf.Version}
+		a.router.GET("/openapi.json", openapi.Handler(info, a.routes).Gin())
+		if a.conf.Http.SwaggerUI {
+			a.router.GET("/docs", openapi.SwaggerUI(a.conf.Name, "/openapi.json", a.conf.Http.SwaggerUIAssets).Gin())
+		}
+	}
 }
 
 func instance() *ap {
@@ -0,0 +1,376 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/app"
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+type recorder struct {
+	mx     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]string{}, r.events...)
+}
+
+type repo struct {
+	name string
+	log  *recorder
+}
+
+func (r *repo) Start(ctx context.Context) error {
+	r.log.add("start " + r.name)
+	return nil
+}
+
+func (r *repo) Close() error {
+	r.log.add("close " + r.name)
+	return nil
+}
+
+type service struct {
+	repo *repo
+	log  *recorder
+}
+
+func (s *service) Stop(ctx context.Context) error {
+	s.log.add("stop service")
+	return nil
+}
+
+var _ = Describe("App", func() {
+
+	It("creates independent instances", func() {
+		one := app.New(app.WithName("one"), app.WithAddress("127.0.0.1", 0))
+		two := app.New(app.WithName("two"), app.WithAddress("127.0.0.1", 0))
+		Expect(one.Config().Name).To(Equal("one"))
+		Expect(two.Config().Name).To(Equal("two"))
+		Expect(one.Router()).NotTo(BeIdenticalTo(two.Router()))
+
+		app.Provide(one, &repo{name: "one"})
+		_, err := app.Resolve[*repo](two)
+		Expect(errors.Is(err, app.ErrComponentNotFound)).To(BeTrue())
+	})
+
+	It("resolves components by type and name", func() {
+		a := app.New()
+		calls := 0
+		app.Provide(a, &repo{name: "primary"})
+		app.Provide(a, &repo{name: "replica"}, app.Named("replica"))
+		app.ProvideFunc(a, func(a *app.App) (*service, error) {
+			calls++
+			return &service{repo: app.MustResolve[*repo](a)}, nil
+		})
+
+		Expect(app.MustResolve[*repo](a).name).To(Equal("primary"))
+		Expect(app.MustResolve[*repo](a, "replica").name).To(Equal("replica"))
+		svc := app.MustResolve[*service](a)
+		Expect(svc.repo.name).To(Equal("primary"))
+		Expect(app.MustResolve[*service](a)).To(BeIdenticalTo(svc))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("reports constructor failures and dependency cycles", func() {
+		a := app.New()
+		app.ProvideFunc(a, func(a *app.App) (*repo, error) { return nil, errors.New("no database") })
+		_, err := app.Resolve[*repo](a)
+		Expect(err).To(MatchError(ContainSubstring("no database")))
+
+		app.ProvideFunc(a, func(a *app.App) (*service, error) {
+			_, err := app.Resolve[*service](a)
+			return nil, err
+		})
+		_, err = app.Resolve[*service](a)
+		Expect(err).To(MatchError(ContainSubstring("dependency cycle")))
+	})
+
+	It("builds components once for concurrent callers", func() {
+		a := app.New()
+		var calls atomic.Int32
+		release := make(chan struct{})
+		app.ProvideFunc(a, func(a *app.App) (*repo, error) {
+			calls.Add(1)
+			<-release
+			return &repo{name: "slow"}, nil
+		})
+		var wg sync.WaitGroup
+		resolved := make([]*repo, 2)
+		errs := make([]error, 2)
+		for i := range resolved {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				resolved[i], errs[i] = app.Resolve[*repo](a)
+			}(i)
+		}
+		Eventually(calls.Load).Should(BeEquivalentTo(1))
+		close(release)
+		wg.Wait()
+		Expect(errs).To(HaveEach(BeNil()))
+		Expect(resolved[0]).To(BeIdenticalTo(resolved[1]))
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("starts and stops components with the app", func() {
+		events := &recorder{}
+		a := app.New(app.WithAddress("127.0.0.1", 0))
+		app.Provide(a, &repo{name: "db", log: events})
+		app.ProvideFunc(a, func(a *app.App) (*service, error) {
+			return &service{repo: app.MustResolve[*repo](a), log: events}, nil
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			a.Run()
+		}()
+		Eventually(a.Addr).ShouldNot(BeNil())
+		Expect(events.list()).To(Equal([]string{"start db"}))
+
+		res, err := http.Get(fmt.Sprintf("http://%s/health/live", a.Addr()))
+		Expect(err).NotTo(HaveOccurred())
+		res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		a.Stop()
+		Eventually(done).Should(BeClosed())
+		Expect(events.list()).To(Equal([]string{"start db", "stop service", "close db"}))
+	})
+
+	It("reports readiness separately from liveness", func() {
+		a := app.New()
+		a.Liveness("process", func(context.Context) error { return nil })
+		a.Readiness("database", func(context.Context) error { return errors.New("connection refused") })
+		a.Readiness("cache", func(context.Context) error { return errors.New("timeout") }, app.NonCritical())
+		a.Readiness("queue", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, app.WithProbeTimeout(10*time.Millisecond), app.NonCritical())
+
+		serve := func(path string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			a.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			return rec
+		}
+		live := serve("/health/live")
+		Expect(live.Code).To(Equal(http.StatusOK))
+		Expect(live.Body.String()).To(ContainSubstring(`"process":{"status":"up"`))
+
+		ready := serve("/health/ready")
+		Expect(ready.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(ready.Body.String()).To(ContainSubstring(`"database":{"status":"down","error":"connection refused"`))
+
+		report := a.Health(context.Background(), app.HealthCheckReadiness)
+		Expect(report.Status).To(Equal(app.HealthStatusDown))
+		Expect(report.Checks).To(HaveLen(3))
+		Expect(report.Checks["cache"].Critical).To(BeFalse())
+		Expect(report.Checks["queue"].Error).To(ContainSubstring("probe timed out"))
+
+		a.Readiness("database", func(context.Context) error { return nil })
+		Expect(serve("/health/ready").Code).To(Equal(http.StatusOK), "non critical failures")
+	})
+
+	It("does not allow credentials from any origin", func() {
+		a := app.New()
+		conf := a.Config().Http
+		Expect(conf.AllowOrigins).To(Equal([]string{"*"}))
+		Expect(conf.AllowCredentials).To(BeTrue())
+		cors := func(origin string) http.Header {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+			req.Header.Set("Origin", origin)
+			a.Router().ServeHTTP(rec, req)
+			return rec.Header()
+		}
+		headers := cors("https://evil.io")
+		Expect(headers.Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		Expect(headers.Get("Access-Control-Allow-Credentials")).To(BeEmpty())
+
+		conf.SetAllowOrigins("https://*.startup.io, https://app.io")
+		Expect(cors("https://evil.startup.io").Get("Access-Control-Allow-Origin")).To(BeEmpty())
+		headers = cors("https://app.io")
+		Expect(headers.Get("Access-Control-Allow-Origin")).To(Equal("https://app.io"))
+		Expect(headers.Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+
+		conf.AllowCredentials = false
+		conf.SetAllowOrigins("https://*.startup.io")
+		Expect(cors("https://admin.startup.io").Get("Access-Control-Allow-Origin")).To(Equal("https://admin.startup.io"))
+	})
+
+	It("runs shutdown hooks by priority and registration order", func() {
+		events := &recorder{}
+		a := app.New(app.WithAddress("127.0.0.1", 0))
+		app.OnShutdown(a, "workers", func() { events.add("workers") }, app.WithShutdownPriority(app.ShutdownPriorityWorkers))
+		app.OnShutdown(a, "cache", func() error { events.add("cache"); return nil })
+		cancelled := make(chan struct{})
+		app.OnShutdown(a, "queue", func(ctx context.Context) error {
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		}, app.WithShutdownTimeout(10*time.Millisecond))
+		app.OnShutdown(a, "storage", func() error {
+			select {
+			case <-a.Context().Done():
+				events.add("storage after context")
+			default:
+				events.add("storage before context")
+			}
+			return nil
+		}, app.WithShutdownPriority(app.ShutdownPriorityStorage))
+
+		hooks := a.ShutdownHooks()
+		Expect(hooks).To(HaveLen(4))
+		Expect(hooks[0].Name).To(Equal("cache"))
+		Expect(hooks[1].Timeout).To(Equal(10 * time.Millisecond))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			a.Run()
+		}()
+		Eventually(a.Addr).ShouldNot(BeNil())
+		a.Stop()
+		Eventually(done).Should(BeClosed())
+		// the stuck queue hook is abandoned once its context times out
+		Eventually(cancelled).Should(BeClosed())
+		Expect(events.list()).To(Equal([]string{"cache", "workers", "storage after context"}))
+	})
+
+	It("registers shutdown hooks once across runs", func() {
+		events := &recorder{}
+		a := app.New(app.WithAddress("127.0.0.1", 0))
+		app.Provide(a, &service{log: events})
+		run := func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				a.Run()
+			}()
+			Eventually(a.Addr).ShouldNot(BeNil())
+			a.Stop()
+			Eventually(done).Should(BeClosed())
+		}
+		run()
+		hooks := a.ShutdownHooks()
+		run()
+		Expect(a.ShutdownHooks()).To(HaveLen(len(hooks)))
+		Expect(events.list()).To(Equal([]string{"stop service", "stop service"}))
+	})
+
+	It("mounts declared routes and lists them", func() {
+		for _, key := range []string{"APP_HTTP_SERVER_LIST_ROUTES", "APP_HTTP_SERVER_OPENAPI"} {
+			Expect(os.Setenv(key, "true")).To(Succeed())
+			DeferCleanup(os.Unsetenv, key)
+		}
+		a := app.New(app.WithAuthenticator(func(c app.Context) { c.AbortWithStatusJSON(http.StatusUnauthorized, "denied") }))
+		Expect(a.Mount(routes{
+			api.BasicRoute(http.MethodGet, "/time", func(context.Context) (string, api.Error) { return "now", nil }, api.WithVersion("v2")),
+			api.BasicRoute(http.MethodGet, "/secret", func(context.Context) (string, api.Error) { return "", nil }, api.Authenticated()),
+		})).To(Succeed())
+
+		serve := func(path string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			a.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			return rec
+		}
+		Expect(serve("/v2/time").Code).To(Equal(http.StatusOK))
+		Expect(serve("/secret").Code).To(Equal(http.StatusUnauthorized))
+		rec := serve("/routes")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(And(ContainSubstring(`"path":"/v2/time"`), ContainSubstring(`"path":"/secret"`)))
+		Expect(a.Routes().Routes()).To(HaveLen(2))
+		rec = serve("/openapi.json")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"/v2/time"`))
+	})
+
+	It("follows config changes only while running", func() {
+		const key = "APP_HTTP_SERVER_ALLOW_ORIGINS"
+		DeferCleanup(os.Unsetenv, key)
+		a := app.New(app.WithAddress("127.0.0.1", 0))
+		Expect(os.Setenv(key, "https://app.io")).To(Succeed())
+		utils.Env.Refresh()
+		Expect(a.Config().Http.AllowOrigins).To(Equal([]string{"*"}))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			a.Run()
+		}()
+		Eventually(a.Addr).ShouldNot(BeNil())
+		Expect(os.Setenv(key, "https://admin.app.io")).To(Succeed())
+		utils.Env.Refresh()
+		Expect(a.Config().Http.AllowOrigins).To(Equal([]string{"https://admin.app.io"}))
+
+		a.Stop()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("limits rate limit classes from config and follows changes", func() {
+		const key = "APP_HTTP_SERVER_RATE_LIMIT_EXPORT"
+		Expect(os.Setenv(key, "2/h")).To(Succeed())
+		DeferCleanup(os.Unsetenv, key)
+		a := app.New(app.WithAddress("127.0.0.1", 0))
+		Expect(a.Mount(routes{
+			api.BasicRoute(http.MethodGet, "/export", func(context.Context) (string, api.Error) { return "rows", nil }, api.WithRateLimit("export")),
+		})).To(Succeed())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			a.Run()
+		}()
+		Eventually(a.Addr).ShouldNot(BeNil())
+		DeferCleanup(func() {
+			a.Stop()
+			Eventually(done).Should(BeClosed())
+		})
+
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			a.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+			return rec
+		}
+		Expect(serve().Code).To(Equal(http.StatusOK))
+		Expect(serve().Code).To(Equal(http.StatusOK))
+		rec := serve()
+		Expect(rec.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(rec.Header().Get("Retry-After")).NotTo(BeEmpty())
+
+		Expect(os.Setenv(key, "3/h")).To(Succeed())
+		utils.Env.Refresh()
+		for i := 0; i < 3; i++ {
+			Expect(serve().Code).To(Equal(http.StatusOK))
+		}
+		Expect(serve().Code).To(Equal(http.StatusTooManyRequests))
+
+		Expect(os.Setenv(key, "")).To(Succeed())
+		utils.Env.Refresh()
+		Expect(serve().Code).To(Equal(http.StatusOK), "classes without a limit are not limited")
+	})
+})
+
+type routes []api.Route
+
+func (r routes) Routes() []api.Route { return r }
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/kod2ulz/gostart/utils"
+	"github.com/pkg/errors"
+)
+
+var ErrComponentNotFound = errors.New("component not found")
+
+// Starter is implemented by components that need to be started before the app serves traffic
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+type component struct {
+	name     string
+	typ      reflect.Type
+	value    any
+	factory  func(*ap) (any, error)
+	priority int
+	resolved bool
+	building chan struct{} // closed when the call building the component returns
+	stopping bool          // its stop hook is registered
+}
+
+func (c *component) String() string {
+	if c.name == "" {
+		return c.typ.String()
+	}
+	return fmt.Sprintf("%s(%s)", c.typ, c.name)
+}
+
+type ComponentOption func(*component)
+
+// Named distinguishes several components of the same type
+func Named(name string) ComponentOption {
+	return func(c *component) { c.name = name }
+}
+
+// WithStopPriority sets the shutdown priority of the component. defaults to ShutdownPriorityWorkers
+func WithStopPriority(priority int) ComponentOption {
+	return func(c *component) { c.priority = priority }
+}
+
+type components struct {
+	mx   sync.Mutex
+	list []*component
+}
+
+func (cs *components) add(c *component) {
+	cs.mx.Lock()
+	defer cs.mx.Unlock()
+	for i := range cs.list {
+		if cs.list[i].typ == c.typ && cs.list[i].name == c.name {
+			cs.list[i] = c
+			return
+		}
+	}
+	cs.list = append(cs.list, c)
+}
+
+func (cs *components) find(typ reflect.Type, name string) *component {
+	cs.mx.Lock()
+	defer cs.mx.Unlock()
+	for i := range cs.list {
+		if cs.list[i].typ == typ && cs.list[i].name == name {
+			return cs.list[i]
+		}
+	}
+	return nil
+}
+
+func (cs *components) all() (out []*component) {
+	cs.mx.Lock()
+	defer cs.mx.Unlock()
+	out = make([]*component, len(cs.list))
+	copy(out, cs.list)
+	return
+}
+
+// resolveChain lists the components being built by the calls leading to a constructor
+type resolveChain struct {
+	component *component
+	parent    *resolveChain
+}
+
+func (rc *resolveChain) has(c *component) bool {
+	for ; rc != nil; rc = rc.parent {
+		if rc.component == c {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve builds c once. concurrent callers wait for the call building it and retry
+// when it fails, while constructors resolving a component they are building fail
+func (cs *components) resolve(a *ap, c *component) (out any, err error) {
+	cs.mx.Lock()
+	for !c.resolved && c.building != nil {
+		if a.resolving.has(c) {
+			cs.mx.Unlock()
+			return nil, errors.Errorf("dependency cycle while resolving %s", c)
+		}
+		building := c.building
+		cs.mx.Unlock()
+		<-building
+		cs.mx.Lock()
+	}
+	if c.resolved {
+		cs.mx.Unlock()
+		return c.value, nil
+	}
+	building := make(chan struct{})
+	c.building = building
+	cs.mx.Unlock()
+
+	built := false
+	defer func() {
+		cs.mx.Lock()
+		defer cs.mx.Unlock()
+		if c.building = nil; built {
+			c.value, c.resolved = out, true
+		}
+		close(building)
+	}()
+	if out, err = c.factory(&ap{appState: a.appState, resolving: &resolveChain{c, a.resolving}}); err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s", c)
+	}
+	built = true
+	return
+}
+
+func componentType[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Provide registers component as the instance of T held by the app. components are
+// started in registration order when the app runs if they implement Starter, and
+// stopped in reverse order if they have a Stop or Close method
+func Provide[T any](a *ap, component T, opts ...ComponentOption) T {
+	a.components.add(newComponent[T](component, nil, opts...))
+	return component
+}
+
+// ProvideFunc registers a constructor for T. it is called once, the first time T is resolved
+// or when the app starts, and may itself resolve other components
+func ProvideFunc[T any](a *ap, fn func(*ap) (T, error), opts ...ComponentOption) {
+	a.components.add(newComponent[T](nil, func(a *ap) (any, error) { return fn(a) }, opts...))
+}
+
+func newComponent[T any](value any, factory func(*ap) (any, error), opts ...ComponentOption) *component {
+	c := &component{typ: componentType[T](), value: value, factory: factory, priority: ShutdownPriorityWorkers, resolved: factory == nil}
+	for i := range opts {
+		opts[i](c)
+	}
+	return c
+}
+
+// Resolve returns the component registered for T
+func Resolve[T any](a *ap, name ...string) (out T, err error) {
+	var _name string
+	if len(name) > 0 {
+		_name = name[0]
+	}
+	c := a.components.find(componentType[T](), _name)
+	if c == nil {
+		return out, errors.Wrapf(ErrComponentNotFound, "%s", componentType[T]())
+	}
+	var val any
+	if val, err = a.components.resolve(a, c); err != nil {
+		return
+	}
+	return val.(T), nil
+}
+
+// MustResolve is like Resolve but panics when the component is missing or fails to build
+func MustResolve[T any](a *ap, name ...string) T {
+	out, err := Resolve[T](a, name...)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// startComponents builds every component, starts those that implement Starter and
+// registers shutdown hooks for those that can be stopped
+func (a *ap) startComponents() (err error) {
+	list := a.components.all()
+	for _, c := range list {
+		if _, err = a.components.resolve(a, c); err != nil {
+			return
+		}
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].stopping {
+			continue
+		} else if stop := componentStopFunc(list[i].value); stop != nil {
+			OnShutdown(a, list[i].String(), stop, WithShutdownPriority(list[i].priority))
+			list[i].stopping = true
+		}
+	}
+	for _, c := range list {
+		if s, ok := c.value.(Starter); ok {
+			if err = s.Start(a.ctx); err != nil {
+				return errors.Wrapf(err, "failed to start %s", c)
+			}
+			a.log.Infof("started %s", c)
+		}
+	}
+	return
+}
+
+func componentStopFunc(value any) utils.ShFunc3 {
+	switch v := value.(type) {
+	case interface{ Stop(context.Context) error }:
+		return v.Stop
+	case interface{ Stop() error }:
+		return utils.ContextFunc(v.Stop)
+	case interface{ Stop() }:
+		return utils.ContextFunc(v.Stop)
+	case interface{ Close(context.Context) error }:
+		return v.Close
+	case interface{ Close() error }:
+		return utils.ContextFunc(v.Close)
+	case interface{ Close() }:
+		return utils.ContextFunc(v.Close)
+	}
+	return nil
+}
@@ -2,79 +2,196 @@ package app
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/logr"
 	"github.com/kod2ulz/gostart/utils"
 )
 
 var _config *conf
 
+// Conf returns the config of the default instance, loaded once from the APP_ prefix
 func Conf() *conf {
 	if _config != nil {
 		return _config
 	}
-	var host = utils.Env.GetHost()
-	var env = utils.Env.Helper("APP")
-	_config = &conf{
-		Host:        host,
-		Name:        env.Get("NAME", host).String(),
-		Version:     env.Get("VERSION", "ver-0.0.0").String(),
-		HttpPort:    env.Get("HTTP_PORT", "49080").Int(),
-		HttpAddress: env.Get("HTTP_ADDRESS", "0.0.0.0").String(),
-		Location:    env.Get("TIME_LOCATION", "Africa/Kampala").Location(),
-		Uptime:      UptimeCheckConf(env.Prefix(), "UPTIME_CHECK"),
-		Http:        HttpConf(env.Prefix(), "HTTP_SERVER"),
+	var err error
+	if _config, err = NewConf(); err != nil {
+		panic(err)
 	}
 	return _config
 }
 
+// NewConf loads the app config under prefix (APP by default). unlike Conf the result is not cached
+func NewConf(prefix ...string) (out *conf, err error) {
+	out = &conf{Host: utils.Env.GetHost(), prefix: utils.Env.Helper(prefix...).OrDefault("APP").Prefix()}
+	if err = utils.Env.LoadOrDefault(out.prefix, out); err != nil {
+		return nil, err
+	}
+	if out.Name == "" {
+		out.Name = out.Host
+	}
+	return
+}
+
 type conf struct {
 	Host        string
-	Name        string
-	Version     string
-	HttpPort    int
-	HttpAddress string
-	Location    *time.Location
-	Uptime      *uptimeCheckConf
-	Http        *httpConf
+	Name        string           `env:"NAME"`
+	Version     string           `env:"VERSION" default:"ver-0.0.0"`
+	HttpPort    int              `env:"HTTP_PORT" default:"49080"`
+	HttpAddress string           `env:"HTTP_ADDRESS" default:"0.0.0.0"`
+	Location    *time.Location   `env:"TIME_LOCATION" default:"Africa/Kampala"`
+	Uptime      *uptimeCheckConf `envPrefix:"UPTIME_CHECK"`
+	Http        *httpConf        `envPrefix:"HTTP_SERVER"`
+	Shutdown    *shutdownConf    `envPrefix:"SHUTDOWN"`
+
+	prefix string
 }
 
 func (c conf) Address() string {
 	return fmt.Sprintf("%s:%d", c.HttpAddress, c.HttpPort)
 }
 
+// Prefix is the env prefix the config was loaded from
+func (c conf) Prefix() string {
+	return c.prefix
+}
+
 type httpConf struct {
-	AllowOrigins     []string
-	AllowMethods     []string
-	AllowHeaders     []string
-	ExposeHeaders    []string
-	MaxAge           time.Duration
-	AllowCredentials bool
+	mx   sync.RWMutex
+	cors gin.HandlerFunc
+
+	AllowOrigins     []string      `env:"ALLOW_ORIGINS" default:"*"`
+	AllowMethods     []string      `env:"ALLOW_METHODS" default:"GET,POST,PUT,HEAD,OPTIONS"`
+	AllowHeaders     []string      `env:"ALLOW_HEADERS" default:"Origin,Content-Length,Accept-Encoding,Authorization,Accept-Language,Content-Type"`
+	ExposeHeaders    []string      `env:"EXPOSE_HEADERS" default:"Content-Length,Host,Content-Type,Connection"`
+	MaxAge           time.Duration `env:"MAX_AGE" default:"12h"`
+	AllowCredentials bool          `env:"ALLOW_CREDENTIALS" default:"true"`
+	ListRoutes       bool          `env:"LIST_ROUTES" default:"false"`
+	OpenAPI          bool          `env:"OPENAPI" default:"false"`
+	SwaggerUI        bool          `env:"SWAGGER_UI" default:"false"`
+	// SwaggerUIAssets is the url of a self hosted swagger-ui-dist, the unpkg CDN by default
+	SwaggerUIAssets string `env:"SWAGGER_UI_ASSETS"`
+	// ErrorFormat is envelope or problem. requests accepting application/problem+json get problems either way
+	ErrorFormat         string `env:"ERROR_FORMAT" default:"envelope"`
+	ErrorTypeBase       string `env:"ERROR_TYPE_BASE"`
+	StripInternalErrors bool   `env:"STRIP_INTERNAL_ERRORS" default:"false"`
+}
+
+// ErrorRenderer renders errors as configured by ErrorFormat, ErrorTypeBase and StripInternalErrors
+func (c *httpConf) ErrorRenderer() api.ErrorRenderer {
+	return api.NewErrorRenderer(api.ErrorFormat(c.ErrorFormat),
+		api.WithProblemTypeBase(c.ErrorTypeBase), api.StripInternalErrors(c.StripInternalErrors))
 }
 
 func HttpConf(prefix ...string) (conf *httpConf) {
-	env := utils.Env.Helper(prefix...).OrDefault("HTTP_SERVER")
-
-	return &httpConf{
-		AllowOrigins:     env.Get("ALLOW_ORIGINS", "*").StringList(","),
-		AllowMethods:     env.Get("ALLOW_METHODS", "GET,POST,PUT,HEAD,OPTIONS").StringList(","),
-		AllowHeaders:     env.Get("ALLOW_HEADERS", "Origin,Content-Length,Accept-Encoding,Authorization,Accept-Language,Content-Type").StringList(","),
-		ExposeHeaders:    env.Get("EXPOSE_HEADERS", "Content-Length,Host,Content-Type,Connection").StringList(","),
-		MaxAge:           env.Get("MAX_AGE", "12h").Duration(),
-		AllowCredentials: env.Get("ALLOW_CREDENTIALS", "true").Bool(),
+	conf = &httpConf{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).OrDefault("HTTP_SERVER").Prefix(), conf)
+	return
+}
+
+// SetAllowOrigins replaces the CORS origins with a comma separated list
+func (c *httpConf) SetAllowOrigins(origins string) {
+	var list []string
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			list = append(list, origin)
+		}
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.AllowOrigins = list
+	if c.cors != nil {
+		c.cors = c.corsHandler()
+	}
+}
+
+// AllowOrigin checks origin against AllowOrigins which may contain * or wildcard
+// entries such as https://*.startup.io. wildcard entries are ignored while credentials
+// are allowed
+func (c *httpConf) AllowOrigin(origin string) bool {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return c.allowOrigin(origin)
+}
+
+func (c *httpConf) allowOrigin(origin string) bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		} else if prefix, suffix, ok := strings.Cut(allowed, "*"); ok && !c.AllowCredentials &&
+			len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS is the CORS middleware of the config, following changes made by SetAllowOrigins.
+// a literal * allows every origin without credentials, so that no site can make
+// credentialed requests, and wildcard entries are ignored while credentials are allowed
+func (c *httpConf) CORS() gin.HandlerFunc {
+	c.mx.Lock()
+	if c.cors == nil {
+		c.cors = c.corsHandler()
+	}
+	c.mx.Unlock()
+	return func(ctx *gin.Context) {
+		c.mx.RLock()
+		handler := c.cors
+		c.mx.RUnlock()
+		handler(ctx)
 	}
 }
 
+// corsHandler builds the CORS middleware of the current origins. c.mx must be held
+func (c *httpConf) corsHandler() gin.HandlerFunc {
+	config := cors.Config{
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				logr.Log().Warn("credentials are not allowed in CORS requests from any origin (*)")
+			}
+			config.AllowAllOrigins, config.AllowCredentials = true, false
+			return cors.New(config)
+		} else if strings.Contains(origin, "*") && c.AllowCredentials {
+			logr.Log().Warnf("ignoring CORS origin %s, wildcard origins are not allowed with credentials", origin)
+		}
+	}
+	config.AllowOriginFunc = c.allowOrigin
+	return cors.New(config)
+}
+
 type uptimeCheckConf struct {
-	Interval time.Duration
-	Timeout  time.Duration
+	Interval     time.Duration `env:"INTERVAL" default:"10s"`
+	Timeout      time.Duration `env:"TIMEOUT" default:"30s"`
+	ProbeTimeout time.Duration `env:"PROBE_TIMEOUT" default:"5s"`
 }
 
 func UptimeCheckConf(prefix ...string) (conf *uptimeCheckConf) {
-	env := utils.Env.Helper(prefix...).OrDefault("UPTIME_CHECK")
+	conf = &uptimeCheckConf{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).OrDefault("UPTIME_CHECK").Prefix(), conf)
+	return
+}
 
-	return &uptimeCheckConf{
-		Interval: env.Get("INTERVAL", "10s").Duration(),
-		Timeout:  env.Get("TIMEOUT", "30s").Duration(),
-	}
+type shutdownConf struct {
+	DrainTimeout time.Duration `env:"DRAIN_TIMEOUT" default:"30s"`
+	HookTimeout  time.Duration `env:"HOOK_TIMEOUT" default:"10s"`
+}
+
+func ShutdownConf(prefix ...string) (conf *shutdownConf) {
+	conf = &shutdownConf{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).OrDefault("SHUTDOWN").Prefix(), conf)
+	return
 }
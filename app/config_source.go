@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kod2ulz/gostart/logr"
+	"github.com/kod2ulz/gostart/utils"
+	"github.com/pkg/errors"
+)
+
+var (
+	defaultConfigFiles = []string{"config.yaml", "config.yml", "config.json", "config.toml"}
+	consulWatchRetry   = 10 * time.Second
+)
+
+// loadConfigSources builds the config chain used by utils.Env. from lowest to highest
+// precedence: defaults, config file (APP_CONFIG_FILE), .env, os env and consul kv
+// (CONSUL_KV_PREFIX). .env values missing from the os env are exported to it for the
+// aws and consul clients, which read it directly
+func loadConfigSources(strict bool) {
+	report := func(err error, msg string) {
+		if strict {
+			log.Fatalf("%s. %v", msg, err)
+		}
+		log.Printf("%s. %v", msg, err)
+	}
+	logr.Getenv = func(key string) string { return utils.Env.GetOrDefault(key, "").String() }
+	if src, err := utils.ExportDotEnv(); err != nil {
+		report(err, "error loading env files")
+	} else {
+		utils.Env.AddSource(utils.EnvPriorityDotEnv, src)
+	}
+	if path := configFile(); path != "" {
+		if src, err := utils.FileSource(path); err != nil {
+			report(err, "error loading config file")
+		} else {
+			utils.Env.AddSource(utils.EnvPriorityFile, src)
+		}
+	}
+	if prefix := utils.Env.GetOrDefault("CONSUL_KV_PREFIX", "").String(); prefix != "" {
+		if client, err := consulapi.NewClient(consulConfig()); err != nil {
+			report(err, "consul client initialisation failed")
+		} else if src, err := ConsulKVSource(client, prefix); err != nil {
+			report(err, "error loading consul kv config")
+		} else {
+			utils.Env.AddSource(utils.EnvPriorityRemote, src)
+		}
+	}
+}
+
+// Defaults registers fallback values that any other config source overrides. it should
+// be called before Init since the app config is loaded during initialisation
+func Defaults(values map[string]string) {
+	utils.Env.AddSource(utils.EnvPriorityDefaults, utils.MapSource("defaults", values))
+}
+
+func configFile() string {
+	if path := utils.Env.GetOrDefault("APP_CONFIG_FILE", "").String(); path != "" {
+		return path
+	}
+	for _, path := range defaultConfigFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// consulConfig resolves the consul agent settings through utils.Env so that
+// they may be supplied by any config source and not only the process env
+func consulConfig() *consulapi.Config {
+	config := consulapi.DefaultConfig()
+	env := utils.Env.Helper("CONSUL")
+	if addr := env.GetString("HTTP_ADDR", ""); addr != "" {
+		config.Address = addr
+	}
+	if token := env.GetString("HTTP_TOKEN", ""); token != "" {
+		config.Token = token
+	}
+	return config
+}
+
+type consulKVSource struct {
+	mx     sync.RWMutex
+	client *consulapi.Client
+	prefix string
+	index  uint64
+	values map[string]string
+}
+
+func (s *consulKVSource) Name() string { return fmt.Sprintf("consul:%s", s.prefix) }
+
+func (s *consulKVSource) Lookup(key string) (val string, ok bool) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	val, ok = s.values[key]
+	return
+}
+
+func (s *consulKVSource) Reload() error {
+	return s.load(nil)
+}
+
+// Watch uses consul blocking queries so that changes are picked up as soon as they are written
+func (s *consulKVSource) Watch(ctx context.Context, changed func()) error {
+	for ctx.Err() == nil {
+		s.mx.RLock()
+		index := s.index
+		s.mx.RUnlock()
+		if err := s.load((&consulapi.QueryOptions{WaitIndex: index, WaitTime: 5 * time.Minute}).WithContext(ctx)); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logr.Log().WithError(err).Errorf("failed to watch %s", s.Name())
+			select {
+			case <-ctx.Done():
+			case <-time.After(consulWatchRetry):
+			}
+			continue
+		}
+		s.mx.RLock()
+		updated := s.index != index
+		s.mx.RUnlock()
+		if updated {
+			changed()
+		}
+	}
+	return nil
+}
+
+func (s *consulKVSource) load(opts *consulapi.QueryOptions) error {
+	pairs, meta, err := s.client.KV().List(s.prefix, opts)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list consul keys under %s", s.prefix)
+	}
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		if key := utils.EnvKey(strings.TrimPrefix(pair.Key, s.prefix)); key != "" && !strings.HasSuffix(pair.Key, "/") {
+			values[key] = string(pair.Value)
+		}
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if meta.LastIndex < s.index {
+		// the index went backwards e.g. after a consul restart, start over
+		s.index = 0
+	} else {
+		s.index = meta.LastIndex
+	}
+	s.values = values
+	return nil
+}
+
+// ConsulKVSource reads every key under prefix from the consul kv store. keys are taken
+// relative to the prefix and converted to env names so that config/my-service/app/http_port
+// read with prefix config/my-service supplies APP_HTTP_PORT. the source can be watched
+// for changes with utils.Env.Watch
+func ConsulKVSource(client *consulapi.Client, prefix string) (utils.EnvSource, error) {
+	out := &consulKVSource{client: client, prefix: strings.Trim(prefix, "/") + "/"}
+	if err := out.load(nil); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
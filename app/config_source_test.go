@@ -0,0 +1,139 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kod2ulz/gostart/app"
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// consulKV serves the kv endpoint of the consul http api with blocking query support
+type consulKV struct {
+	*httptest.Server
+	mx      sync.Mutex
+	index   uint64
+	changed chan struct{}
+	pairs   consulapi.KVPairs
+}
+
+func newConsulKV(pairs map[string]string) *consulKV {
+	c := &consulKV{changed: make(chan struct{})}
+	c.set(pairs)
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	DeferCleanup(c.Close)
+	return c
+}
+
+func (c *consulKV) client() *consulapi.Client {
+	config := consulapi.DefaultConfig()
+	config.Address = c.URL
+	client, err := consulapi.NewClient(config)
+	Expect(err).NotTo(HaveOccurred())
+	return client
+}
+
+func (c *consulKV) set(pairs map[string]string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.pairs = nil
+	for k, v := range pairs {
+		c.pairs = append(c.pairs, &consulapi.KVPair{Key: k, Value: []byte(v)})
+	}
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *consulKV) serve(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	c.mx.Lock()
+	index, changed := c.index, c.changed
+	c.mx.Unlock()
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait >= index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	out := consulapi.KVPairs{}
+	for _, pair := range c.pairs {
+		if strings.HasPrefix(pair.Key, prefix) {
+			out = append(out, pair)
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func lookup(source utils.EnvSource, key string) string {
+	val, _ := source.Lookup(key)
+	return val
+}
+
+var _ = Describe("Consul kv config", func() {
+
+	It("reads keys relative to the prefix as env names", func() {
+		consul := newConsulKV(map[string]string{
+			"config/orders/app/http_port":                 "8080",
+			"config/orders/app/http-server/allow_origins": "a.io",
+			"config/orders/app/":                          "",
+			"config/payments/app/http_port":               "9090",
+		})
+		source, err := app.ConsulKVSource(consul.client(), "/config/orders/")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.Name()).To(Equal("consul:config/orders/"))
+		Expect(lookup(source, "APP_HTTP_PORT")).To(Equal("8080"))
+		Expect(lookup(source, "APP_HTTP_SERVER_ALLOW_ORIGINS")).To(Equal("a.io"))
+		_, ok := source.Lookup("APP")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("fails when consul is unreachable", func() {
+		consul := newConsulKV(nil)
+		client := consul.client()
+		consul.Close()
+		_, err := app.ConsulKVSource(client, "config/orders")
+		Expect(err).To(MatchError(ContainSubstring("config/orders/")))
+	})
+
+	It("follows changes with blocking queries", func() {
+		consul := newConsulKV(map[string]string{"config/orders/app/version": "1"})
+		source, err := app.ConsulKVSource(consul.client(), "config/orders")
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		changes, done := make(chan struct{}, 1), make(chan struct{})
+		go func() {
+			defer close(done)
+			source.(interface {
+				Watch(context.Context, func()) error
+			}).Watch(ctx, func() { changes <- struct{}{} })
+		}()
+		DeferCleanup(func() {
+			cancel()
+			Eventually(done).Should(BeClosed())
+		})
+
+		consul.set(map[string]string{"config/orders/app/version": "2"})
+		Eventually(changes).Should(Receive())
+		Expect(lookup(source, "APP_VERSION")).To(Equal("2"))
+
+		Expect(source.(interface{ Reload() error }).Reload()).To(Succeed())
+		Consistently(changes, 100*time.Millisecond).ShouldNot(Receive())
+	})
+})
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "up"
+	HealthStatusDown HealthStatus = "down"
+)
+
+type HealthCheckKind string
+
+const (
+	HealthCheckLiveness  HealthCheckKind = "live"
+	HealthCheckReadiness HealthCheckKind = "ready"
+)
+
+// HealthProbe checks a single dependency of the application. it should return
+// promptly when ctx is done
+type HealthProbe func(context.Context) error
+
+type HealthCheckOption func(*healthCheck)
+
+// WithProbeTimeout overrides the default probe timeout (APP_UPTIME_CHECK_PROBE_TIMEOUT)
+func WithProbeTimeout(timeout time.Duration) HealthCheckOption {
+	return func(h *healthCheck) {
+		if timeout > 0 {
+			h.timeout = timeout
+		}
+	}
+}
+
+// NonCritical probes are reported but do not change the overall status
+func NonCritical() HealthCheckOption {
+	return func(h *healthCheck) { h.critical = false }
+}
+
+type healthCheck struct {
+	name     string
+	kind     HealthCheckKind
+	probe    HealthProbe
+	timeout  time.Duration
+	critical bool
+}
+
+type HealthCheckResult struct {
+	Status   HealthStatus `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Duration int64        `json:"duration"`
+	Critical bool         `json:"critical"`
+}
+
+type HealthReport struct {
+	Status  HealthStatus                 `json:"status"`
+	App     string                       `json:"app"`
+	Host    string                       `json:"host"`
+	Version string                       `json:"version"`
+	Uptime  string                       `json:"uptime"`
+	Checks  map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+type healthChecks struct {
+	mx       sync.RWMutex
+	checks   []*healthCheck
+	stopping atomic.Bool
+}
+
+func (h *healthChecks) add(check *healthCheck) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	for i := range h.checks {
+		if h.checks[i].name == check.name && h.checks[i].kind == check.kind {
+			h.checks[i] = check
+			return
+		}
+	}
+	h.checks = append(h.checks, check)
+}
+
+func (h *healthChecks) of(kind HealthCheckKind) (out []*healthCheck) {
+	h.mx.RLock()
+	defer h.mx.RUnlock()
+	for i := range h.checks {
+		if h.checks[i].kind == kind {
+			out = append(out, h.checks[i])
+		}
+	}
+	return
+}
+
+// Liveness registers a probe that should only fail when the process needs to be restarted
+func (a *ap) Liveness(name string, probe HealthProbe, opts ...HealthCheckOption) *ap {
+	return a.healthCheck(HealthCheckLiveness, name, probe, opts...)
+}
+
+// Readiness registers a probe for a dependency that must be available for the app to serve traffic
+func (a *ap) Readiness(name string, probe HealthProbe, opts ...HealthCheckOption) *ap {
+	return a.healthCheck(HealthCheckReadiness, name, probe, opts...)
+}
+
+func (a *ap) healthCheck(kind HealthCheckKind, name string, probe HealthProbe, opts ...HealthCheckOption) *ap {
+	check := &healthCheck{name: name, kind: kind, probe: probe, timeout: a.conf.Uptime.ProbeTimeout, critical: true}
+	for i := range opts {
+		opts[i](check)
+	}
+	a.health.add(check)
+	return a
+}
+
+// Health runs all probes of the given kind concurrently
+func (a *ap) Health(ctx context.Context, kind HealthCheckKind) (out HealthReport) {
+	out = HealthReport{
+		Status: HealthStatusUp, App: a.conf.Name, Host: a.conf.Host, Version: a.conf.Version,
+		Uptime: time.Since(a.start).Round(100 * time.Millisecond).String(),
+	}
+	checks := a.health.of(kind)
+	if kind == HealthCheckReadiness && a.health.stopping.Load() {
+		out.Status = HealthStatusDown
+	}
+	if len(checks) == 0 {
+		return
+	}
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+	out.Checks = make(map[string]HealthCheckResult, len(checks))
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check *healthCheck) {
+			defer wg.Done()
+			result := runProbe(ctx, check)
+			mx.Lock()
+			defer mx.Unlock()
+			out.Checks[check.name] = result
+			if result.Status == HealthStatusDown && check.critical {
+				out.Status = HealthStatusDown
+			}
+		}(check)
+	}
+	wg.Wait()
+	return
+}
+
+func runProbe(ctx context.Context, check *healthCheck) (out HealthCheckResult) {
+	start, done := time.Now(), make(chan error, 1)
+	pctx, cancel := context.WithTimeout(ctx, check.timeout)
+	defer cancel()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.Errorf("probe panic: %v", r)
+			}
+		}()
+		done <- check.probe(pctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-pctx.Done():
+		err = errors.Wrapf(pctx.Err(), "probe timed out after %v", check.timeout)
+	}
+	out = HealthCheckResult{Status: HealthStatusUp, Critical: check.critical, Duration: time.Since(start).Milliseconds()}
+	if err != nil {
+		out.Status, out.Error = HealthStatusDown, err.Error()
+	}
+	return
+}
+
+// HttpHealthCheck probes a downstream http dependency. any response below 400 is considered healthy
+func HttpHealthCheck(url string) HealthProbe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create request to %s", url)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrapf(err, "request to %s failed", url)
+		}
+		defer res.Body.Close()
+		if res.StatusCode >= 400 {
+			return errors.Errorf("%s returned %s", url, res.Status)
+		}
+		return nil
+	}
+}
+
+func (a *ap) healthHandler(kind HealthCheckKind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := a.Health(c, kind)
+		if report.Status != HealthStatusUp {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/logr"
+	"github.com/kod2ulz/gostart/router"
+)
+
+type options struct {
+	log       *logr.Logger
+	conf      *conf
+	prefix    string
+	auth      router.HandlerFunc
+	errors    api.ErrorRenderer
+	configure []func(*conf)
+}
+
+type Option func(*options)
+
+// WithLogger replaces the logger derived from the global logr logger
+func WithLogger(log *logr.Logger) Option {
+	return func(o *options) { o.log = log }
+}
+
+// WithConfig uses an already loaded config e.g. from Conf or NewConf
+func WithConfig(c *conf) Option {
+	return func(o *options) { o.conf = c }
+}
+
+// WithConfigPrefix loads the config from env vars under prefix instead of APP
+func WithConfigPrefix(prefix string) Option {
+	return func(o *options) { o.prefix = prefix }
+}
+
+func WithName(name string) Option {
+	return func(o *options) {
+		o.configure = append(o.configure, func(c *conf) { c.Name = name })
+	}
+}
+
+// WithAddress sets the http listen address. port 0 picks a free port
+func WithAddress(host string, port int) Option {
+	return func(o *options) {
+		o.configure = append(o.configure, func(c *conf) { c.HttpAddress, c.HttpPort = host, port })
+	}
+}
+
+// WithAuthenticator sets the middleware that authenticates mounted routes declared as
+// api.Authenticated e.g. api.WithUserFunc
+func WithAuthenticator(auth router.HandlerFunc) Option {
+	return func(o *options) { o.auth = auth }
+}
+
+// WithErrorRenderer renders the errors of api handlers with renderer instead of the one
+// configured by HTTP_SERVER_ERROR_FORMAT, HTTP_SERVER_ERROR_TYPE_BASE and
+// HTTP_SERVER_STRIP_INTERNAL_ERRORS
+func WithErrorRenderer(renderer api.ErrorRenderer) Option {
+	return func(o *options) { o.errors = renderer }
+}
+
+func (o *options) config() *conf {
+	if o.conf == nil {
+		var err error
+		if o.conf, err = NewConf(o.prefix); err != nil {
+			panic(err)
+		}
+	} else if len(o.configure) > 0 {
+		// keep shared configs such as the one returned by Conf intact
+		c := *o.conf
+		o.conf = &c
+	}
+	for i := range o.configure {
+		o.configure[i](o.conf)
+	}
+	return o.conf
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/logr"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+// watchConfigSources keeps the process config chain up to date for as long as the app
+// context is alive. watchable sources (config files, consul kv) are followed unless
+// APP_CONFIG_WATCH=false and every source is reloaded on SIGHUP
+func (a *ap) watchConfigSources() {
+	if utils.Env.GetOrDefault("APP_CONFIG_WATCH", "true").Bool() {
+		utils.Env.Watch(a.ctx)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-hup:
+				a.log.Info("SIGHUP received, reloading configuration")
+				if err := utils.Env.Reload(); err != nil {
+					a.log.WithError(err).Error("configuration reload failed")
+				}
+			}
+		}
+	}()
+	a.onConfigChange(logr.ENV_LOG_LEVEL, func(old, new utils.EnvValue) {
+		logr.SetLevel(new.Value)
+	})
+}
+
+// rateLimitClasses are the rate limit classes of the mounted routes
+type rateLimitClasses struct {
+	mx        sync.Mutex
+	classes   []string
+	following bool
+}
+
+// followConfig subscribes the running app to config changes, so apps that are never run
+// leave no subscriptions behind. they end with the app context
+func (a *ap) followConfig() {
+	a.confOnce.Do(func() {
+		if a.watchConf {
+			a.watchConfigSources()
+		}
+		a.subscribeConfig()
+	})
+}
+
+// subscribeConfig applies changes to the hot reloadable settings of this app, the allowed
+// origins and the rate limits of the classes mounted so far or later
+func (a *ap) subscribeConfig() {
+	if a.conf.prefix == "" {
+		return
+	}
+	a.onConfigChange(utils.EnvKey(a.conf.prefix, "HTTP_SERVER_ALLOW_ORIGINS"), func(old, new utils.EnvValue) {
+		if origins := new.Value; origins != "" {
+			a.conf.Http.SetAllowOrigins(origins)
+			return
+		}
+		a.conf.Http.SetAllowOrigins("*")
+	})
+	a.rateLimits.mx.Lock()
+	defer a.rateLimits.mx.Unlock()
+	a.rateLimits.following = true
+	for _, class := range a.rateLimits.classes {
+		a.followRateLimit(class)
+	}
+}
+
+// rateLimit enforces the limit of class set by <PREFIX>_HTTP_SERVER_RATE_LIMIT_<CLASS> e.g.
+// APP_HTTP_SERVER_RATE_LIMIT_AUTH=5/m. classes without a limit are not limited
+func (a *ap) rateLimit(class string) router.HandlerFunc {
+	if a.conf.prefix != "" {
+		a.rateLimits.mx.Lock()
+		if !slices.Contains(a.rateLimits.classes, class) {
+			a.rateLimits.classes = append(a.rateLimits.classes, class)
+			if val, ok := utils.Env.Trace(a.rateLimitKey(class)); ok {
+				a.setRateLimit(class, val)
+			}
+			if a.rateLimits.following {
+				a.followRateLimit(class)
+			}
+		}
+		a.rateLimits.mx.Unlock()
+	}
+	return a.limiter.Limit(class)
+}
+
+func (a *ap) rateLimitKey(class string) string {
+	return utils.EnvKey(a.conf.prefix, "HTTP_SERVER_RATE_LIMIT", class)
+}
+
+func (a *ap) followRateLimit(class string) {
+	a.onConfigChange(a.rateLimitKey(class), func(old, new utils.EnvValue) {
+		a.setRateLimit(class, new)
+	})
+}
+
+// setRateLimit applies the limit read from val, keeping the current one if it is invalid
+func (a *ap) setRateLimit(class string, val utils.EnvValue) {
+	limit, err := api.ParseRateLimit(val.Value)
+	if err != nil {
+		a.log.WithError(err).WithField("source", val.Source).Errorf("ignoring rate limit of %s", class)
+		return
+	}
+	a.limiter.SetLimit(class, limit)
+}
+
+// onConfigChange logs and forwards changes to key until the app is stopped
+func (a *ap) onConfigChange(key string, fn func(old, new utils.EnvValue)) {
+	unsubscribe := utils.Env.Subscribe(key, func(old, new utils.EnvValue) {
+		a.log.WithField("source", new.Source).Infof("%s changed from %q to %q", key, old.Value, new.Value)
+		fn(old, new)
+	})
+	go func() {
+		<-a.ctx.Done()
+		unsubscribe()
+	}()
+}
+
+// Feature reports whether the flag FEATURE_<NAME> is enabled. flags are resolved on every
+// call so they follow config reloads. name may be given in any case e.g. new-checkout
+func Feature(name string) bool {
+	val, ok := utils.Env.Trace(utils.EnvKey("FEATURE", name))
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(val.Value)) {
+	case "1", "t", "true", "on", "yes", "enabled":
+		return true
+	}
+	return false
+}
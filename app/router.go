@@ -1,14 +1,32 @@
 package app
 
-import "context"
+import (
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/pkg/errors"
+)
 
-type Context interface {
-	context.Context
-	Get(key string) (value any, exists bool)
-}
+type Context = router.Context
+
+type HandlerFunc = router.HandlerFunc
+
+type RouterEngine = router.Engine
 
-type HandlerFunc func(Context)
+// Engine registers router agnostic handlers on the app's router
+func (a *ap) Engine() RouterEngine {
+	return router.GinEngine(a.router)
+}
 
-type RouterEngine[ctx Context] interface {
+// Routes is the registry of the routes declared through Mount
+func (a *ap) Routes() *api.Registry {
+	return a.routes
+}
 
-}
\ No newline at end of file
+// Mount registers the routes declared by providers. routes requiring authentication
+// need an authenticator, see WithAuthenticator
+func (a *ap) Mount(providers ...api.RouteProvider) (err error) {
+	if err = a.routes.MountProviders(a.Engine(), providers...); err != nil {
+		return errors.Wrap(err, "failed to mount routes")
+	}
+	return
+}
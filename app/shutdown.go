@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kod2ulz/gostart/utils"
+	"github.com/pkg/errors"
+)
+
+// shutdown hook priorities. hooks with lower priority values run first
+// and hooks sharing a priority run in the order they were registered
+const (
+	ShutdownPriorityDiscovery = -30
+	ShutdownPriorityHttp      = -20
+	ShutdownPriorityContext   = -10
+	ShutdownPriorityDefault   = 0
+	ShutdownPriorityWorkers   = 10
+	ShutdownPriorityStorage   = 20
+)
+
+type ShutdownHook struct {
+	Name     string
+	Priority int
+	Timeout  time.Duration
+	fn       utils.ShFunc3
+	seq      int
+}
+
+type ShutdownHookOption func(*ShutdownHook)
+
+func WithShutdownPriority(priority int) ShutdownHookOption {
+	return func(h *ShutdownHook) { h.Priority = priority }
+}
+
+func WithShutdownTimeout(timeout time.Duration) ShutdownHookOption {
+	return func(h *ShutdownHook) {
+		if timeout > 0 {
+			h.Timeout = timeout
+		}
+	}
+}
+
+type shutdownHooks struct {
+	mx    sync.Mutex
+	seq   int
+	hooks []*ShutdownHook
+}
+
+func (s *shutdownHooks) add(hook *ShutdownHook) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.seq++
+	hook.seq = s.seq
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *shutdownHooks) ordered() (out []*ShutdownHook) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	out = make([]*ShutdownHook, len(s.hooks))
+	copy(out, s.hooks)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Priority == out[j].Priority {
+			return out[i].seq < out[j].seq
+		}
+		return out[i].Priority < out[j].Priority
+	})
+	return
+}
+
+// OnShutdown registers a named cleanup routine that Run will wait on when the application
+// is stopping. fn may be any of the utils.ShutdownFuncs signatures e.g. redis.Client.Close,
+// pgxpool.Pool.Close or mq.RMQ.Close
+func OnShutdown[F utils.ShutdownFuncs](a *ap, name string, fn F, opts ...ShutdownHookOption) {
+	hook := &ShutdownHook{
+		Name:     name,
+		Priority: ShutdownPriorityDefault,
+		Timeout:  a.conf.Shutdown.HookTimeout,
+		fn:       utils.ContextFunc(fn),
+	}
+	for i := range opts {
+		opts[i](hook)
+	}
+	a.hooks.add(hook)
+}
+
+// ShutdownHooks returns the registered hooks in the order in which they will be executed
+func (a *ap) ShutdownHooks() []*ShutdownHook {
+	return a.hooks.ordered()
+}
+
+func (a *ap) runShutdownHook(hook *ShutdownHook) (err error) {
+	log := a.log.WithField("hook", hook.Name).WithField("priority", hook.Priority)
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+	start, done := time.Now(), make(chan error, 1)
+	log.Infof("stopping %s", hook.Name)
+	go func() { done <- hook.fn(ctx) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.Wrapf(ctx.Err(), "%s did not stop within %v", hook.Name, hook.Timeout)
+	}
+	if err != nil {
+		return utils.Error.Log(log, err, "%s shutdown failed", hook.Name)
+	}
+	log.WithField("duration", time.Since(start).Milliseconds()).Infof("%s stopped", hook.Name)
+	return nil
+}
+
+func (a *ap) shutdown() (failed int) {
+	a.log.Printf("shutting down")
+	a.health.stopping.Store(true)
+	for _, hook := range a.hooks.ordered() {
+		if err := a.runShutdownHook(hook); err != nil {
+			failed++
+		}
+	}
+	return
+}
@@ -8,26 +8,18 @@ import (
 )
 
 type Config struct {
-	Driver             string
-	UserPool           string
-	ClientID           string
-	ClientSecret       string
-	AuthIssuerURL      string
-	JwkRefreshInterval utils.Value
-	PublicKeyURL       string
+	Driver             string      `env:"DRIVER" default:"cognito"`
+	UserPool           string      `env:"USER_POOL"`
+	ClientID           string      `env:"CLIENT_ID"`
+	ClientSecret       string      `env:"CLIENT_SECRET"`
+	AuthIssuerURL      string      `env:"ISSUER_URL" default:"https://auth.startup.io"`
+	JwkRefreshInterval utils.Value `env:"JWK_REFRESH_INTERVAL" default:"15m"`
+	PublicKeyURL       string      `env:"PUBLIK_KEY_URL"`
 }
 
 func InitConfig(prefix ...string) (conf *Config) {
-	env := utils.Env.Helper(prefix...).OrDefault("AUTH")
-	conf = &Config{
-		Driver:             env.GetString("DRIVER", cognitoDriver),
-		UserPool:           env.GetString("USER_POOL", ""),
-		ClientID:           env.GetString("CLIENT_ID", ""),
-		JwkRefreshInterval: env.Get("JWK_REFRESH_INTERVAL", "15m"),
-		ClientSecret:       env.GetString("CLIENT_SECRET", ""),
-		AuthIssuerURL:      env.GetString("ISSUER_URL", "https://auth.startup.io"),
-		PublicKeyURL:       env.GetString("PUBLIK_KEY_URL", ""),
-	}
+	conf = &Config{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).OrDefault("AUTH").Prefix(), conf)
 	if conf.PublicKeyURL != "" {
 		return
 	}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type Strategy string
+
+const (
+	RoundRobin        Strategy = "round-robin"
+	Random            Strategy = "random"
+	LeastRecentlyUsed Strategy = "lru"
+)
+
+// balancer picks one of the instances of a single service
+type balancer interface {
+	pick(instances []Instance) Instance
+}
+
+func newBalancer(strategy Strategy) balancer {
+	switch strategy {
+	case Random:
+		return &randomBalancer{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	case LeastRecentlyUsed:
+		return &lruBalancer{used: make(map[string]uint64)}
+	}
+	return &roundRobinBalancer{}
+}
+
+type roundRobinBalancer struct {
+	mx   sync.Mutex
+	next int
+}
+
+func (b *roundRobinBalancer) pick(instances []Instance) Instance {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	out := instances[b.next%len(instances)]
+	b.next = (b.next + 1) % len(instances)
+	return out
+}
+
+type randomBalancer struct {
+	mx  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (b *randomBalancer) pick(instances []Instance) Instance {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return instances[b.rnd.Intn(len(instances))]
+}
+
+type lruBalancer struct {
+	mx   sync.Mutex
+	seq  uint64
+	used map[string]uint64
+}
+
+func (b *lruBalancer) pick(instances []Instance) Instance {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	out := instances[0]
+	for _, i := range instances[1:] {
+		if b.used[i.ID] < b.used[out.ID] {
+			out = i
+		}
+	}
+	b.seq++
+	b.used[out.ID] = b.seq
+	if len(b.used) > 4*len(instances) {
+		// forget instances that have since left the pool
+		live := make(map[string]uint64, len(instances))
+		for _, i := range instances {
+			live[i.ID] = b.used[i.ID]
+		}
+		b.used = live
+	}
+	return out
+}
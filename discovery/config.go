@@ -0,0 +1,29 @@
+package discovery
+
+import (
+	"time"
+
+	"github.com/kod2ulz/gostart/utils"
+)
+
+type Conf struct {
+	Strategy      Strategy      `env:"STRATEGY" default:"round-robin"`
+	Tag           string        `env:"TAG"`
+	Scheme        string        `env:"SCHEME" default:"http"`
+	WaitTime      time.Duration `env:"WAIT_TIME" default:"5m"`
+	RetryInterval time.Duration `env:"RETRY_INTERVAL" default:"5s"`
+}
+
+func Config(prefix ...string) (conf *Conf) {
+	conf = &Conf{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).OrDefault("DISCOVERY").Prefix(), conf)
+	return
+}
+
+// Options converts the config into client options
+func (c *Conf) Options() []Option {
+	return []Option{
+		WithStrategy(c.Strategy), WithTag(c.Tag), WithScheme(c.Scheme),
+		WithWaitTime(c.WaitTime), WithRetryInterval(c.RetryInterval),
+	}
+}
@@ -0,0 +1,279 @@
+package discovery
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/kod2ulz/gostart/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Scheme marks urls that address a service by name e.g. consul://orders/v1/items
+const Scheme = "consul"
+
+var ErrNoInstances = errors.New("no healthy instances")
+
+// URLResolver rewrites service urls such as consul://orders/v1/items into the url of a live instance
+type URLResolver interface {
+	ResolveURL(ctx context.Context, u *url.URL) (*url.URL, error)
+}
+
+type Option func(*Client)
+
+func WithStrategy(strategy Strategy) Option {
+	return func(c *Client) { c.strategy = strategy }
+}
+
+// WithTag only considers instances registered with tag
+func WithTag(tag string) Option {
+	return func(c *Client) { c.tag = tag }
+}
+
+// WithScheme sets the scheme of instances that do not declare one in their "scheme" meta. defaults to http
+func WithScheme(scheme string) Option {
+	return func(c *Client) { c.scheme = scheme }
+}
+
+// WithStaticEndpoints configures fallback endpoints for service in addition to
+// those in DISCOVERY_STATIC_<SERVICE>
+func WithStaticEndpoints(service string, endpoints ...string) Option {
+	return func(c *Client) { c.static[service] = append(c.static[service], endpoints...) }
+}
+
+// WithWaitTime sets the maximum duration of the blocking queries used to watch services
+func WithWaitTime(wait time.Duration) Option {
+	return func(c *Client) { c.waitTime = wait }
+}
+
+func WithRetryInterval(retry time.Duration) Option {
+	return func(c *Client) { c.retry = retry }
+}
+
+// Client keeps a cache of the passing instances of every service it has been asked about,
+// updated in the background with consul blocking queries. without a consul client, or
+// when consul has no passing instances, endpoints configured as DISCOVERY_STATIC_<SERVICE>
+// are used instead
+type Client struct {
+	consul   *consulapi.Client
+	strategy Strategy
+	tag      string
+	scheme   string
+	waitTime time.Duration
+	retry    time.Duration
+	static   map[string][]string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mx        sync.Mutex
+	services  map[string]*service
+	balancers map[string]balancer
+}
+
+func New(consul *consulapi.Client, opts ...Option) (out *Client) {
+	out = &Client{
+		consul:    consul,
+		strategy:  RoundRobin,
+		scheme:    "http",
+		waitTime:  5 * time.Minute,
+		retry:     5 * time.Second,
+		static:    make(map[string][]string),
+		services:  make(map[string]*service),
+		balancers: make(map[string]balancer),
+	}
+	for i := range opts {
+		opts[i](out)
+	}
+	out.ctx, out.cancel = context.WithCancel(context.Background())
+	return
+}
+
+// Close stops watching services
+func (c *Client) Close() {
+	c.cancel()
+}
+
+// Instances returns the passing instances of service, or its static endpoints when there are none
+func (c *Client) Instances(ctx context.Context, name string) (out []Instance, err error) {
+	var watchErr error
+	if c.consul != nil {
+		s := c.service(name)
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if out, watchErr = s.list(); len(out) > 0 {
+			return
+		}
+	}
+	if out, err = c.staticInstances(name); err != nil || len(out) > 0 {
+		return
+	} else if watchErr != nil {
+		return nil, errors.Wrapf(ErrNoInstances, "%s: %v", name, watchErr)
+	}
+	return nil, errors.Wrapf(ErrNoInstances, "%s", name)
+}
+
+// Pick selects one instance of service using the configured strategy
+func (c *Client) Pick(ctx context.Context, name string) (out Instance, err error) {
+	var list []Instance
+	if list, err = c.Instances(ctx, name); err != nil {
+		return
+	}
+	return c.balancer(name).pick(list), nil
+}
+
+// ResolveURL replaces the scheme and host of consul://<service>/... urls with those of a
+// picked instance. other urls are returned as they are
+func (c *Client) ResolveURL(ctx context.Context, u *url.URL) (*url.URL, error) {
+	if u == nil || u.Scheme != Scheme {
+		return u, nil
+	}
+	instance, err := c.Pick(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	out := *u
+	out.Scheme, out.Host = instance.Scheme, instance.Host()
+	return &out, nil
+}
+
+// Resolve is ResolveURL for raw urls
+func (c *Client) Resolve(ctx context.Context, rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid url %q", rawUrl)
+	} else if u, err = c.ResolveURL(ctx, u); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (c *Client) balancer(name string) balancer {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if b, ok := c.balancers[name]; ok {
+		return b
+	}
+	c.balancers[name] = newBalancer(c.strategy)
+	return c.balancers[name]
+}
+
+func (c *Client) staticInstances(name string) (out []Instance, err error) {
+	endpoints := utils.Env.Helper("DISCOVERY_STATIC").Get(utils.EnvKey(name), "").StringList(",")
+	c.mx.Lock()
+	endpoints = append(endpoints, c.static[name]...)
+	c.mx.Unlock()
+	for _, endpoint := range endpoints {
+		if endpoint = strings.TrimSpace(endpoint); endpoint == "" {
+			continue
+		}
+		if !strings.Contains(endpoint, "://") {
+			endpoint = c.scheme + "://" + endpoint
+		}
+		var instance Instance
+		if instance, err = ParseInstance(name, endpoint); err != nil {
+			return nil, err
+		}
+		out = append(out, instance)
+	}
+	return
+}
+
+func (c *Client) service(name string) *service {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if s, ok := c.services[name]; ok {
+		return s
+	}
+	s := &service{name: name, ready: make(chan struct{})}
+	c.services[name] = s
+	go c.watch(s)
+	return s
+}
+
+func (c *Client) watch(s *service) {
+	var index uint64
+	for c.ctx.Err() == nil {
+		opts := (&consulapi.QueryOptions{WaitIndex: index, WaitTime: c.waitTime}).WithContext(c.ctx)
+		entries, meta, err := c.consul.Health().Service(s.name, c.tag, true, opts)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			log.WithError(err).WithField("consul.service", s.name).Warn("failed to watch service instances")
+			s.fail(err)
+			select {
+			case <-c.ctx.Done():
+			case <-time.After(c.retry):
+			}
+			continue
+		}
+		instances := make([]Instance, len(entries))
+		for i := range entries {
+			instances[i] = instanceFromEntry(entries[i], c.scheme)
+		}
+		sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+		s.update(instances)
+		if meta.LastIndex < index {
+			// the index went backwards e.g. after a consul restart, start over
+			index = 0
+		} else {
+			index = meta.LastIndex
+		}
+	}
+}
+
+type service struct {
+	name      string
+	mx        sync.RWMutex
+	instances []Instance
+	err       error
+	once      sync.Once
+	ready     chan struct{}
+}
+
+func (s *service) list() ([]Instance, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.instances, s.err
+}
+
+func (s *service) update(instances []Instance) {
+	s.mx.Lock()
+	s.instances, s.err = instances, nil
+	s.mx.Unlock()
+	s.once.Do(func() { close(s.ready) })
+}
+
+// fail records err while keeping the last known instances
+func (s *service) fail(err error) {
+	s.mx.Lock()
+	s.err = err
+	s.mx.Unlock()
+	s.once.Do(func() { close(s.ready) })
+}
+
+var _default atomic.Pointer[Client]
+
+// Default is the client used to resolve consul:// urls when none is configured explicitly.
+// until SetDefault is called it only knows static endpoints
+func Default() *Client {
+	if c := _default.Load(); c != nil {
+		return c
+	}
+	_default.CompareAndSwap(nil, New(nil))
+	return _default.Load()
+}
+
+func SetDefault(c *Client) {
+	_default.Store(c)
+}
@@ -0,0 +1,97 @@
+package discovery_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDiscovery(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Discovery Suite")
+}
+
+// consulStandIn serves the health endpoint of the consul http api with blocking query support
+type consulStandIn struct {
+	*httptest.Server
+	mx       sync.Mutex
+	index    uint64
+	changed  chan struct{}
+	services map[string][]*consulapi.ServiceEntry
+	requests []string
+}
+
+func newConsulStandIn() *consulStandIn {
+	c := &consulStandIn{index: 1, changed: make(chan struct{}), services: map[string][]*consulapi.ServiceEntry{}}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	DeferCleanup(c.Close)
+	return c
+}
+
+func (c *consulStandIn) client() *consulapi.Client {
+	config := consulapi.DefaultConfig()
+	config.Address = c.URL
+	client, err := consulapi.NewClient(config)
+	Expect(err).NotTo(HaveOccurred())
+	return client
+}
+
+func (c *consulStandIn) set(service string, entries ...*consulapi.ServiceEntry) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.services[service] = entries
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *consulStandIn) requestURIs() []string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]string{}, c.requests...)
+}
+
+func (c *consulStandIn) serve(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	c.mx.Lock()
+	c.requests = append(c.requests, r.URL.RequestURI())
+	index, changed := c.index, c.changed
+	c.mx.Unlock()
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait >= index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	w.Header().Set("Content-Type", "application/json")
+	entries := c.services[name]
+	if entries == nil {
+		entries = []*consulapi.ServiceEntry{}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+func entry(id, address string, port int, meta ...string) *consulapi.ServiceEntry {
+	out := &consulapi.ServiceEntry{
+		Node:    &consulapi.Node{Address: "10.0.0.1"},
+		Service: &consulapi.AgentService{ID: id, Service: "orders", Address: address, Port: port, Meta: map[string]string{}},
+	}
+	for i := 0; i+1 < len(meta); i += 2 {
+		out.Service.Meta[meta[i]] = meta[i+1]
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package discovery_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/discovery"
+	gohttp "github.com/kod2ulz/gostart/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Discovery", func() {
+	var ctx = context.Background()
+
+	Context("with consul", func() {
+		var consul *consulStandIn
+		var client *discovery.Client
+
+		BeforeEach(func() {
+			consul = newConsulStandIn()
+			consul.set("orders", entry("orders-1", "10.0.0.4", 8080), entry("orders-2", "", 8081, "scheme", "https"))
+		})
+
+		JustBeforeEach(func() {
+			DeferCleanup(client.Close)
+		})
+
+		Context("round robin", func() {
+			BeforeEach(func() { client = discovery.New(consul.client()) })
+
+			It("cycles through passing instances", func() {
+				var urls []string
+				for i := 0; i < 3; i++ {
+					instance, err := client.Pick(ctx, "orders")
+					Expect(err).NotTo(HaveOccurred())
+					urls = append(urls, instance.URL())
+				}
+				Expect(urls).To(Equal([]string{"http://10.0.0.4:8080", "https://10.0.0.1:8081", "http://10.0.0.4:8080"}))
+				Expect(consul.requestURIs()[0]).To(ContainSubstring("passing=1"))
+			})
+
+			It("follows changes with blocking queries", func() {
+				Expect(client.Instances(ctx, "orders")).To(HaveLen(2))
+				consul.set("orders", entry("orders-3", "10.0.0.9", 9000))
+				Eventually(func() ([]discovery.Instance, error) { return client.Instances(ctx, "orders") }).
+					Should(ConsistOf(HaveField("ID", "orders-3")))
+			})
+
+			It("resolves consul urls", func() {
+				Expect(client.Resolve(ctx, "consul://orders/v1/items?page=2")).To(Equal("http://10.0.0.4:8080/v1/items?page=2"))
+				Expect(client.Resolve(ctx, "https://example.com/a")).To(Equal("https://example.com/a"))
+			})
+
+			It("fails when a service has no instances", func() {
+				_, err := client.Pick(ctx, "payments")
+				Expect(err).To(MatchError(discovery.ErrNoInstances))
+			})
+
+			It("falls back to static endpoints", func() {
+				Expect(os.Setenv("DISCOVERY_STATIC_PAYMENTS", "payments.internal:7000, https://payments.backup")).To(Succeed())
+				DeferCleanup(os.Unsetenv, "DISCOVERY_STATIC_PAYMENTS")
+				list, err := client.Instances(ctx, "payments")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(list).To(HaveLen(2))
+				Expect(list[0].URL()).To(Equal("http://payments.internal:7000"))
+				Expect(list[1].URL()).To(Equal("https://payments.backup"))
+			})
+		})
+
+		Context("least recently used", func() {
+			BeforeEach(func() { client = discovery.New(consul.client(), discovery.WithStrategy(discovery.LeastRecentlyUsed)) })
+
+			It("prefers the instance used longest ago", func() {
+				consul.set("orders", entry("a", "10.0.0.1", 1), entry("b", "10.0.0.2", 2), entry("c", "10.0.0.3", 3))
+				Eventually(func() ([]discovery.Instance, error) { return client.Instances(ctx, "orders") }).Should(HaveLen(3))
+				var ids []string
+				for i := 0; i < 4; i++ {
+					instance, _ := client.Pick(ctx, "orders")
+					ids = append(ids, instance.ID)
+				}
+				Expect(ids).To(Equal([]string{"a", "b", "c", "a"}))
+			})
+		})
+	})
+
+	It("uses static endpoints without consul", func() {
+		client := discovery.New(nil, discovery.WithStaticEndpoints("orders", "127.0.0.1:1", "127.0.0.1:2"), discovery.WithStrategy(discovery.Random))
+		for i := 0; i < 5; i++ {
+			instance, err := client.Pick(ctx, "orders")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instance.Address).To(Equal("127.0.0.1"))
+			Expect(instance.Port).To(BeElementOf(1, 2))
+		}
+	})
+
+	Context("http clients", func() {
+		var server *httptest.Server
+		var client *discovery.Client
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"path": %q}`, r.URL.Path)
+			}))
+			DeferCleanup(server.Close)
+			client = discovery.New(nil, discovery.WithStaticEndpoints("orders", server.URL))
+		})
+
+		It("resolves service urls in http.Client", func() {
+			res := gohttp.Client[map[string]string](logrus.NewEntry(logrus.New())).Discovery(client).Get(ctx, "consul://orders/v1/items")
+			Expect(res.HasError()).To(BeFalse())
+			Expect(res.Data).To(HaveKeyWithValue("path", "/v1/items"))
+		})
+
+		It("resolves service urls in api.HttpClient", func() {
+			var out map[string]string
+			httpClient := api.InitHttpClient(logrus.NewEntry(logrus.New()), api.ClientConfig(), nil).WithDiscovery(client)
+			Expect(httpClient.Request(ctx).GetWithResponseBody("consul://orders/v1/items", &out)).To(Succeed())
+			Expect(out).To(HaveKeyWithValue("path", "/v1/items"))
+		})
+	})
+})
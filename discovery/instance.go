@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+// Instance is a single reachable endpoint of a service
+type Instance struct {
+	ID      string            `json:"id"`
+	Service string            `json:"service"`
+	Scheme  string            `json:"scheme"`
+	Address string            `json:"address"`
+	Port    int               `json:"port"`
+	Tags    []string          `json:"tags,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// Host is the address:port pair of the instance
+func (i Instance) Host() string {
+	if i.Port == 0 {
+		return i.Address
+	}
+	return net.JoinHostPort(i.Address, strconv.Itoa(i.Port))
+}
+
+// URL is the base url of the instance e.g. http://10.0.0.4:8080
+func (i Instance) URL() string {
+	return fmt.Sprintf("%s://%s", i.Scheme, i.Host())
+}
+
+// AgentService converts the instance into the consul representation returned by app.Service
+func (i Instance) AgentService() *consulapi.AgentService {
+	return &consulapi.AgentService{ID: i.ID, Service: i.Service, Address: i.Address, Port: i.Port, Tags: i.Tags, Meta: i.Meta}
+}
+
+func instanceFromEntry(entry *consulapi.ServiceEntry, scheme string) Instance {
+	out := Instance{
+		ID:      entry.Service.ID,
+		Service: entry.Service.Service,
+		Scheme:  scheme,
+		Address: entry.Service.Address,
+		Port:    entry.Service.Port,
+		Tags:    entry.Service.Tags,
+		Meta:    entry.Service.Meta,
+	}
+	if out.Address == "" && entry.Node != nil {
+		// services registered without an address are reachable on the node address
+		out.Address = entry.Node.Address
+	}
+	if s := entry.Service.Meta["scheme"]; s != "" {
+		out.Scheme = s
+	}
+	return out
+}
+
+// ParseInstance parses a static endpoint such as https://orders.internal:8443
+func ParseInstance(service, endpoint string) (out Instance, err error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	var u *url.URL
+	if u, err = url.Parse(endpoint); err != nil {
+		return out, errors.Wrapf(err, "invalid endpoint %q for %s", endpoint, service)
+	} else if u.Hostname() == "" {
+		return out, errors.Errorf("invalid endpoint %q for %s: missing host", endpoint, service)
+	}
+	out = Instance{ID: u.Host, Service: service, Scheme: u.Scheme, Address: u.Hostname()}
+	if port := u.Port(); port != "" {
+		if out.Port, err = strconv.Atoi(port); err != nil {
+			return out, errors.Wrapf(err, "invalid port in endpoint %q for %s", endpoint, service)
+		}
+	}
+	return
+}
@@ -13,6 +13,7 @@ import (
 	json "github.com/json-iterator/go"
 	"github.com/kod2ulz/gostart/api"
 	"github.com/kod2ulz/gostart/collections"
+	"github.com/kod2ulz/gostart/discovery"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -29,15 +30,16 @@ func Client[T any](log *logrus.Entry) (out *client[T]) {
 }
 
 type client[T any] struct {
-	baseUrl string
-	start   time.Time
-	out     *T
-	log     *logrus.Entry
-	body    any
-	session Session
-	timeout time.Duration
-	params  collections.Map[string, []string]
-	headers Headers
+	baseUrl   string
+	discovery discovery.URLResolver
+	start     time.Time
+	out       *T
+	log       *logrus.Entry
+	body      any
+	session   Session
+	timeout   time.Duration
+	params    collections.Map[string, []string]
+	headers   Headers
 }
 
 func (c *client[T]) Timeout(timeut time.Duration) *client[T] {
@@ -50,6 +52,12 @@ func (c *client[T]) BaseUrl(url string) *client[T] {
 	return c
 }
 
+// Discovery sets the resolver of consul://<service>/... urls. discovery.Default() is used otherwise
+func (c *client[T]) Discovery(resolver discovery.URLResolver) *client[T] {
+	c.discovery = resolver
+	return c
+}
+
 func (c *client[T]) Body(body any) *client[T] {
 	c.body = body
 	return c
@@ -126,6 +134,9 @@ func (c *client[T]) Request(ctx context.Context, method, path string) (out api.R
 	if parseErr != nil {
 		err = api.RequestLoadError[T](parseErr).WithMessage("failed to parse url")
 		return api.ErrorResponse[T](err)
+	} else if _url, parseErr = c.resolve(ctx, _url); parseErr != nil {
+		err = api.ServerError(errors.Wrap(parseErr, "service discovery failed"))
+		return api.ErrorResponse[T](err)
 	}
 	c.setOverrides(ctx)
 	setUrlQueryParams(_url, c.params)
@@ -162,6 +173,15 @@ func (c *client[T]) url(path string) string {
 	}), "/")
 }
 
+func (c *client[T]) resolve(ctx context.Context, _url *url.URL) (*url.URL, error) {
+	if _url.Scheme != discovery.Scheme {
+		return _url, nil
+	} else if c.discovery != nil {
+		return c.discovery.ResolveURL(ctx, _url)
+	}
+	return discovery.Default().ResolveURL(ctx, _url)
+}
+
 func (c *client[T]) setOverrides(ctx context.Context) {
 	var h = Headers{}
 	if ctx != nil {
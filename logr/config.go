@@ -15,13 +15,17 @@ const (
 	ENV_APP_HOST   = "HOST"
 )
 
+// Getenv is used to read the logger settings. app points it at the utils.Env
+// source chain so that values from config files, .env and consul are honoured
+var Getenv = os.Getenv
+
 func Config() (err error) {
 	log := logrus.New()
 	log.SetReportCaller(true)
 	log.SetLevel(logrus.TraceLevel)
 
 	var prettyPrint bool
-	if prettyPrint, err = strconv.ParseBool(os.Getenv(ENV_PRETTY_LOG)); err != nil {
+	if prettyPrint, err = strconv.ParseBool(Getenv(ENV_PRETTY_LOG)); err != nil {
 		log.WithError(err).Errorf("error parsing %s", ENV_PRETTY_LOG)
 		prettyPrint = true
 		log.Infof("defaulting to log.Formatter.PrettyPrint=%v", prettyPrint)
@@ -32,7 +36,7 @@ func Config() (err error) {
 	})
 
 	defaultFields := logrus.Fields{
-		"application": os.Getenv(ENV_APP_NAME),
+		"application": Getenv(ENV_APP_NAME),
 	}
 
 	if err = SetUpLogger(log.WithFields(defaultFields)); err != nil {
@@ -44,8 +48,19 @@ func Config() (err error) {
 }
 
 
+// SetLevel changes the level of the global logger and every logger derived from it
+func SetLevel(level string) {
+	if log != nil {
+		log.Logger.SetLevel(parseLogLevel(level))
+	}
+}
+
 func _getLogLevel() logrus.Level {
-	switch level := strings.ToLower(os.Getenv(ENV_LOG_LEVEL)); level {
+	return parseLogLevel(Getenv(ENV_LOG_LEVEL))
+}
+
+func parseLogLevel(level string) logrus.Level {
+	switch strings.ToLower(level) {
 	case "panic", "0":
 		return logrus.PanicLevel
 	case "fatal", "1":
@@ -69,5 +84,5 @@ func _getHost() string {
 	if err == nil {
 		return host
 	}
-	return os.Getenv(ENV_APP_HOST)
+	return Getenv(ENV_APP_HOST)
 }
\ No newline at end of file
@@ -26,7 +26,12 @@ func loggerCopy() *Logger {
 	}
 }
 
+// Log returns a copy of the global logger, configuring it from the env when Config
+// has not been called yet
 func Log() *Logger {
+	if log == nil {
+		Config()
+	}
 	return loggerCopy()
 }
 
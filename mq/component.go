@@ -0,0 +1,12 @@
+package mq
+
+import (
+	"github.com/kod2ulz/gostart/app"
+	"github.com/streadway/amqp"
+)
+
+// ProvideWorkerManager registers the manager of the workers of theme on exchange as a
+// component of a, named by its theme. the workers stop with the app context
+func ProvideWorkerManager(a *app.App, theme string, exchange Exchange[amqp.Delivery]) WorkerManager {
+	return app.Provide(a, ManageWithExchange(a.Log(), a.Context(), theme, exchange), app.Named(theme))
+}
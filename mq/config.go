@@ -10,9 +10,9 @@ import (
 
 type ExchangeConfig struct {
 	Name        string
-	BindingKeys ExchangeKeys
-	TempQueue   Name
-	ErrorKey    Name
+	BindingKeys ExchangeKeys `env:"BINDING_KEYS" default:"#"`
+	TempQueue   Name         `env:"TEMP_QUEUE_NAME"`
+	ErrorKey    Name         `env:"ERROR_KEY"`
 }
 
 type Name string
@@ -50,16 +50,16 @@ func (k ExchangeKeys) Empty() bool {
 }
 
 type Conf struct {
-	Host             string
-	Port             string
-	Vhost            string
-	Heartbeat        time.Duration
-	HeartbeatTimeout time.Duration
-	Username         string
-	Password         string
-	Protocol         string
-	ConsumerExchange ExchangeConfig
-	ProducerExchange ExchangeConfig
+	Host             string         `env:"HOST" default:"127.0.0.1"`
+	Port             string         `env:"PORT" default:"5672"`
+	Vhost            string         `env:"VHOST" default:"/"`
+	Heartbeat        time.Duration  `env:"HEARTBEAT_MILLISECONDS" default:"5000" unit:"ms"`
+	HeartbeatTimeout time.Duration  `env:"HEARTBEAT_TIMEOUT_MILLISECONDS" default:"20000" unit:"ms"`
+	Username         string         `env:"USERNAME" default:"guest"`
+	Password         string         `env:"PASSWORD" default:"guest"`
+	Protocol         string         `env:"PROTOCOL" default:"amqp"`
+	ConsumerExchange ExchangeConfig `envPrefix:"EXCHANGE_CONSUMER"`
+	ProducerExchange ExchangeConfig `envPrefix:"EXCHANGE_PRODUCER"`
 }
 
 const (
@@ -67,33 +67,13 @@ const (
 	DefaultHeartbeatTimeout = 20000
 )
 
-func Config(prefix ...string) *Conf {
+func Config(prefix ...string) (conf *Conf) {
 	env := utils.Env.Helper(prefix...).OrDefault("MQ")
-	heartbeat := env.Get("HEARTBEAT_MILLISECONDS", DefaultHeartbeat).Int()
-	heartbeatTimeout := env.Get("HEARTBEAT_TIMEOUT_MILLISECONDS", DefaultHeartbeatTimeout).Int()
-
-	return &Conf{
-		Host:             env.GetString("HOST", "127.0.0.1"),
-		Port:             env.GetString("PORT", "5672"),
-		Vhost:            env.GetString("VHOST", "/"),
-		Heartbeat:        time.Duration(heartbeat) * time.Millisecond,
-		HeartbeatTimeout: time.Duration(heartbeatTimeout) * time.Millisecond,
-		Username:         env.GetString("USERNAME", "guest"),
-		Password:         env.GetString("PASSWORD", "guest"),
-		Protocol:         env.GetString("PROTOCOL", "amqp"),
-		ConsumerExchange: ExchangeConfig{
-			Name:        env.GetString("EXCHANGE_CONSUMER", "exchange.in"),
-			BindingKeys: ExchangeKeys(env.GetString("EXCHANGE_CONSUMER_BINDING_KEYS", "#")),
-			ErrorKey:    Name(env.GetString("EXCHANGE_CONSUMER_ERROR_KEY", "")),
-			TempQueue:   Name(env.GetString("EXCHANGE_CONSUMER_TEMP_QUEUE_NAME", "")),
-		},
-		ProducerExchange: ExchangeConfig{
-			Name:        env.GetString("EXCHANGE_PRODUCER", "exchange.out"),
-			BindingKeys: ExchangeKeys(env.GetString("EXCHANGE_PRODUCER_BINDING_KEYS", "#")),
-			ErrorKey:    Name(env.GetString("EXCHANGE_PRODUCER_ERROR_KEY", "")),
-			TempQueue:   Name(env.GetString("EXCHANGE_PRODUCER_TEMP_QUEUE_NAME", "")),
-		},
-	}
+	conf = &Conf{}
+	utils.Env.MustLoad(env.Prefix(), conf)
+	conf.ConsumerExchange.Name = env.GetString("EXCHANGE_CONSUMER", "exchange.in")
+	conf.ProducerExchange.Name = env.GetString("EXCHANGE_PRODUCER", "exchange.out")
+	return
 }
 
 func (c *Conf) ConnectionString() string {
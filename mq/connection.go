@@ -56,6 +56,17 @@ func (c *rmqConn) Channel() *amqp.Channel {
 	return c.channel
 }
 
+func (c *rmqConn) healthy() error {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if c.connection == nil || c.connection.IsClosed() {
+		return errors.New("connection is closed")
+	} else if c.channel == nil {
+		return errors.New("channel is not open")
+	}
+	return nil
+}
+
 func (c *rmqConn) reconnect() (err error) {
 	c.connect()
 	if err = <-c.err; err != nil {
@@ -2,9 +2,9 @@ package mq
 
 import (
 	"context"
-	"os"
 
 	"github.com/kod2ulz/gostart/logr"
+	"github.com/kod2ulz/gostart/utils"
 )
 
 func Load(ctx context.Context, cnf *Conf, log *logr.Logger) *RMQ {
@@ -18,9 +18,9 @@ func Load(ctx context.Context, cnf *Conf, log *logr.Logger) *RMQ {
 }
 
 func _mqDriver() (d string) {
-	if d = os.Getenv("APP_MQ"); d != "" {
+	if d = utils.Env.GetOrDefault("APP_MQ", "").String(); d != "" {
 		return
-	} else if d = os.Getenv("APP_MQ_DRIVER"); d != "" {
+	} else if d = utils.Env.GetOrDefault("APP_MQ_DRIVER", "").String(); d != "" {
 		return
 	}
 	return "rabbitmq"
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/kod2ulz/gostart/logr"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/streadway/amqp"
@@ -74,6 +75,16 @@ func (q *RMQ) Close() error {
 	return nil
 }
 
+// HealthCheck verifies that both the publisher and consumer connections are open
+func (q *RMQ) HealthCheck(ctx context.Context) error {
+	for name, conn := range map[string]*rmqConn{"publisher": q.publisher, "consumer": q.consumer} {
+		if err := conn.healthy(); err != nil {
+			return errors.Wrapf(err, "%s connection to %s", name, q.conf.String())
+		}
+	}
+	return nil
+}
+
 func (q *RMQ) DeclareExchange(name, kind string, durable bool, autoDelete bool, internal bool, noWait bool, args amqp.Table) (exchange *rmqExchange) {
 	var ok bool
 	if exchange, ok = q.exchanges[name]; ok {
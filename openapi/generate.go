@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/query"
+)
+
+const (
+	jsonContent  = "application/json"
+	bearerScheme = "bearerAuth"
+)
+
+// operators documented for query.UrlFields.Comparable
+var searchComparisons = []struct {
+	op          query.CompareOperator
+	description string
+}{
+	{query.CompareGreaterThan, "greater than"},
+	{query.CompareGreaterThanOrEqual, "greater than or equal to"},
+	{query.CompareLessThan, "less than"},
+	{query.CompareLessThanOrEqual, "less than or equal to"},
+	{query.CompareNot, "not"},
+	{query.CompareNotEqual, "not equal to"},
+	{query.CompareEqualFold, "equal to, ignoring case"},
+}
+
+type generator struct {
+	doc     *Document
+	schemas *schemas
+	tags    map[string]bool
+}
+
+// Generate documents routes. request bodies and path parameters are derived from the
+// RequestParam of each route, and responses from its response type wrapped in the
+// api.Response envelope
+func Generate(info Info, routes ...api.Route) *Document {
+	g := &generator{
+		doc: &Document{
+			OpenAPI: Version, Info: info, Paths: make(map[string]*PathItem),
+			Components: Components{Responses: make(map[string]*Response)},
+		},
+		schemas: newSchemas(),
+		tags:    make(map[string]bool),
+	}
+	g.envelope()
+	for _, route := range routes {
+		g.route(route)
+	}
+	g.doc.Components.Schemas = g.schemas.defs
+	for tag := range g.tags {
+		g.doc.Tags = append(g.doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(g.doc.Tags, func(i, j int) bool { return g.doc.Tags[i].Name < g.doc.Tags[j].Name })
+	return g.doc
+}
+
+// envelope defines the Response, Metadata and ErrorModel schemas shared by every operation
+func (g *generator) envelope() {
+	g.schemas.of(reflect.TypeOf(api.Metadata{}))
+	errorModel := g.schemas.object(reflect.TypeOf(api.ErrorModel[any]{}))
+	errorModel.Properties["cause"] = ref("ErrorModel")
+	g.schemas.define(reflect.TypeOf(api.ErrorModel[any]{}), "ErrorModel", errorModel)
+	response := g.schemas.object(reflect.TypeOf(api.Response[any]{}))
+	response.Properties["error"] = ref("ErrorModel")
+	response.Required = []string{"success"}
+	g.schemas.define(reflect.TypeOf(api.Response[any]{}), "Response", response)
+	g.schemas.define(reflect.TypeOf(api.Problem{}), "Problem", &Schema{
+		Type: "object", Required: []string{"type", "title", "status"},
+		Properties: map[string]*Schema{
+			"type": {Type: "string", Format: "uri-reference"}, "title": {Type: "string"}, "status": {Type: "integer"},
+			"detail": {Type: "string"}, "instance": {Type: "string", Format: "uri-reference"}, "code": {Type: "string"},
+			"fieldErrors": {Type: "array", Items: g.schemas.of(reflect.TypeOf(api.FieldError{}))},
+		},
+		Description: "RFC 9457 problem details, served to requests accepting " + api.ContentTypeProblem,
+	})
+	g.doc.Components.Responses["Error"] = &Response{
+		Description: "request failed",
+		Content: map[string]*MediaType{
+			jsonContent:            {Schema: ref("Response")},
+			api.ContentTypeProblem: {Schema: ref("Problem")},
+		},
+	}
+}
+
+func (g *generator) route(r api.Route) {
+	path, params := pathOf(r.FullPath())
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		g.doc.Paths[path] = item
+	}
+	op := &Operation{
+		OperationID: operationID(r.Method, r.FullPath()), Summary: r.Summary, Tags: r.Tags,
+		Responses: map[string]*Response{"200": {Description: "OK", Content: map[string]*MediaType{jsonContent: {Schema: g.response(r)}}}},
+	}
+	(*item)[strings.ToLower(r.Method)] = op
+	for _, tag := range r.Tags {
+		g.tags[tag] = true
+	}
+
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: g.paramField(r.Param, name)})
+	}
+	if r.List {
+		op.Parameters = append(op.Parameters,
+			&Parameter{Name: "limit", In: "query", Description: "maximum number of items", Schema: &Schema{Type: "integer", Minimum: float(1)}},
+			&Parameter{Name: "offset", In: "query", Description: "number of items to skip", Schema: &Schema{Type: "integer", Minimum: float(0)}},
+			&Parameter{Name: "page", In: "query", Description: "page of limit items, used when offset is not set", Schema: &Schema{Type: "integer", Minimum: float(1)}},
+			&Parameter{Name: "cursor", In: "query", Description: "next_cursor or prev_cursor of a keyset paginated response, replaces offset and page", Schema: &Schema{Type: "string"}})
+	}
+	if r.Search != nil {
+		op.Parameters = append(op.Parameters, searchParameters(*r.Search)...)
+	}
+	if r.Param != nil && hasBody(r.Method) {
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{jsonContent: {Schema: g.schemas.of(r.Param)}}}
+	}
+
+	errorResponse := &Response{Ref: "#/components/responses/Error"}
+	op.Responses["500"] = errorResponse
+	if r.Param != nil {
+		op.Responses["400"] = errorResponse
+	}
+	if r.Auth {
+		op.Responses["401"] = errorResponse
+		op.Security = []map[string][]string{{bearerScheme: {}}}
+		g.security()
+	}
+	if len(r.Roles) > 0 {
+		op.Responses["403"] = errorResponse
+		op.Extensions = map[string]any{"x-roles": r.Roles}
+	}
+	if r.RateLimit != "" {
+		op.Responses["429"] = errorResponse
+		if op.Extensions == nil {
+			op.Extensions = make(map[string]any)
+		}
+		op.Extensions["x-rate-limit"] = r.RateLimit
+	}
+	if d := r.Deprecation; d != nil {
+		op.Deprecated = true
+		op.Description = deprecationNote(*d)
+	}
+}
+
+// response is the api.Response envelope with data of the route's response type
+func (g *generator) response(r api.Route) *Schema {
+	if r.Response == nil {
+		return ref("Response")
+	}
+	data := g.schemas.of(r.Response)
+	content := &Schema{Type: "object", Properties: map[string]*Schema{"data": data}}
+	if r.List {
+		content.Properties["data"] = &Schema{Type: "array", Items: data}
+		content.Required = []string{"meta"}
+	}
+	return &Schema{AllOf: []*Schema{ref("Response"), content}}
+}
+
+// paramField is the schema of the field of param bound to a path parameter, string when there is none
+func (g *generator) paramField(param reflect.Type, name string) *Schema {
+	for param != nil && param.Kind() == reflect.Pointer {
+		param = param.Elem()
+	}
+	if param == nil || param.Kind() != reflect.Struct {
+		return &Schema{Type: "string"}
+	}
+	field, ok := param.FieldByNameFunc(func(field string) bool { return strings.EqualFold(field, name) })
+	if !ok {
+		return &Schema{Type: "string"}
+	}
+	out := g.schemas.of(field.Type)
+	applyValidation(out, field.Type, field.Tag.Get("validate"))
+	return out
+}
+
+func (g *generator) security() {
+	if g.doc.Components.SecuritySchemes == nil {
+		g.doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+			bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		}
+	}
+}
+
+// searchParameters documents the query parameters read by query.SearchUrl for fields
+func searchParameters(fields query.UrlFields) (out []*Parameter) {
+	param := func(name, description string, schema *Schema) {
+		out = append(out, &Parameter{Name: name, In: "query", Description: description, Schema: schema})
+	}
+	for _, field := range fields.Lookup {
+		param(field, fmt.Sprintf("%s equal to", field), searchSchema(fields.Types[field]))
+	}
+	for _, field := range fields.Comparable {
+		param(field+"_null", fmt.Sprintf("%s is null", field), &Schema{Type: "boolean"})
+		for _, cmp := range searchComparisons {
+			param(fmt.Sprintf("%s_%s", field, cmp.op), fmt.Sprintf("%s %s", field, cmp.description), searchSchema(fields.Types[field]))
+		}
+		param(field+"_in", fmt.Sprintf("%s is one of the comma separated values", field), &Schema{Type: "string"})
+		param(field+"_between", fmt.Sprintf("%s is between the comma separated values, inclusive", field), &Schema{Type: "string"})
+		param("~"+field, fmt.Sprintf("%s ends with", field), &Schema{Type: "string"})
+		param("~"+field+"~", fmt.Sprintf("%s contains", field), &Schema{Type: "string"})
+		param(field+"~", fmt.Sprintf("%s starts with", field), &Schema{Type: "string"})
+	}
+	if len(fields.Comparable) > 0 {
+		param("case", "case of like comparisons, ignored by default", &Schema{Type: "string", Enum: []any{"sensitive", "insensitive"}})
+	}
+	if len(fields.Comparable)+len(fields.Lookup) > 0 {
+		param("or", "any of the | separated <param>:<value> searches e.g. name~:jo|email~:jo", &Schema{Type: "string"})
+	}
+	if len(fields.Text) > 0 {
+		param("q", fmt.Sprintf("words searched for in %s", strings.Join(fields.Text, ", ")), &Schema{Type: "string"})
+	}
+	for _, field := range fields.Sort {
+		param("sort_"+field, fmt.Sprintf("sort by %s", field), &Schema{Type: "string", Enum: []any{string(query.SortAsc), string(query.SortDesc)}})
+	}
+	return
+}
+
+func searchSchema(t query.FieldType) *Schema {
+	switch t {
+	case query.FieldInt:
+		return &Schema{Type: "integer", Format: "int64"}
+	case query.FieldFloat:
+		return &Schema{Type: "number", Format: "double"}
+	case query.FieldBool:
+		return &Schema{Type: "boolean"}
+	case query.FieldTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case query.FieldUUID:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	return &Schema{Type: "string"}
+}
+
+// pathOf converts gin style paths to openapi templates. /books/:id becomes /books/{id}
+func pathOf(path string) (out string, params []string) {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID names operations after their method and path e.g. getV1BooksById
+func operationID(method, path string) string {
+	words := []string{strings.ToLower(method)}
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			words = append(words, "by", s[1:])
+		} else if s != "" {
+			words = append(words, s)
+		}
+	}
+	return strcase.ToLowerCamel(strings.Join(words, " "))
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+func deprecationNote(d api.Deprecation) string {
+	notes := []string{"deprecated"}
+	if !d.Sunset.IsZero() {
+		notes = append(notes, "to be removed on "+d.Sunset.Format(time.DateOnly))
+	}
+	if d.Replacement != "" {
+		notes = append(notes, "use "+d.Replacement+" instead")
+	}
+	if d.Message != "" {
+		notes = append(notes, d.Message)
+	}
+	return strings.Join(notes, ". ")
+}
@@ -0,0 +1,48 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/router"
+)
+
+// SwaggerUIAssets is where the swagger ui page loads its scripts and styles from by default.
+// the assets are not embedded, so browsers opening the page need to reach the unpkg CDN
+// unless SwaggerUI is given the url of a self hosted copy of swagger-ui-dist
+var SwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@5"
+
+//go:embed swagger.html
+var swaggerPage string
+
+var swaggerTemplate = template.Must(template.New("swagger").Parse(swaggerPage))
+
+// Handler serves the document of the routes mounted on registry. it is generated on
+// every request so that routes mounted after the handler was created are included
+func Handler(info Info, registry *api.Registry) router.HandlerFunc {
+	return func(c router.Context) {
+		c.JSON(http.StatusOK, Generate(info, registry.Routes()...))
+	}
+}
+
+// SwaggerUI serves a swagger ui page for the document at specURL, loading swagger-ui-dist
+// from assets when given, else from SwaggerUIAssets
+func SwaggerUI(title, specURL string, assets ...string) router.HandlerFunc {
+	var page bytes.Buffer
+	source := SwaggerUIAssets
+	if len(assets) > 0 && assets[0] != "" {
+		source = strings.TrimSuffix(assets[0], "/")
+	}
+	err := swaggerTemplate.Execute(&page, map[string]string{"Title": title, "SpecURL": specURL, "Assets": source})
+	return func(c router.Context) {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, c.Error(err))
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	}
+}
@@ -0,0 +1,171 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/openapi"
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/router"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type Author struct {
+	Name  string  `json:"name" validate:"required,min=2,max=64"`
+	Books []*Book `json:"books,omitempty"`
+}
+
+type Book struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Genre     string    `json:"genre"`
+	Author    *Author   `json:"author,omitempty"`
+	Published time.Time `json:"published"`
+}
+
+type CreateBookRequest struct {
+	Title  string   `json:"title" validate:"required,gt=2"`
+	Genre  string   `json:"genre" validate:"omitempty,oneof=fiction poetry"`
+	Pages  int      `json:"pages" validate:"required,gte=10,lt=5000"`
+	Email  string   `json:"email" validate:"omitempty,email"`
+	Tags   []string `json:"tags"  validate:"max=5,dive,alphanum,max=12"`
+	Secret string   `json:"-"`
+	api.RequestModal[CreateBookRequest]
+}
+
+type BookRequest = api.ListRequestWithID[uuid.UUID]
+
+func book(context.Context) (out Book, err api.Error)    { return }
+func books(context.Context) (out []Book, err api.Error) { return }
+
+var routes = []api.Route{
+	api.ParamRoute[CreateBookRequest](http.MethodPost, "/books", book, api.WithVersion("v1"), api.WithTags("books"),
+		api.WithSummary("add a book"), api.Authenticated(), api.WithRateLimit("writes")),
+	api.ListRoute[api.ListRequest](http.MethodGet, "/books", books, api.WithVersion("v1"), api.WithTags("books"),
+		api.WithSearch(query.UrlFields{Comparable: []string{"pages"}, Lookup: []string{"genre"}, Sort: []string{"title"},
+			Text: []string{"title"}, Types: map[string]query.FieldType{"pages": query.FieldInt}})),
+	api.ParamRoute[BookRequest](http.MethodGet, "/books/:id", book, api.WithVersion("v1"), api.WithRoles("reader"),
+		api.WithDeprecation(api.Deprecation{Sunset: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), Replacement: "/v2/books/{id}"})),
+}
+
+// asMap round trips v through json to inspect the document as clients see it
+func asMap(v any) (out map[string]any) {
+	data, err := json.Marshal(v)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(json.Unmarshal(data, &out)).To(Succeed())
+	return
+}
+
+func at(m map[string]any, keys ...string) any {
+	var cur any = m
+	for _, k := range keys {
+		Expect(cur).To(HaveKey(k), "missing %s", k)
+		cur = cur.(map[string]any)[k]
+	}
+	return cur
+}
+
+var _ = Describe("OpenAPI", func() {
+	var doc map[string]any
+
+	BeforeEach(func() {
+		doc = asMap(openapi.Generate(openapi.Info{Title: "library", Version: "1.0.0"}, routes...))
+	})
+
+	It("documents every route under its versioned path", func() {
+		Expect(doc["openapi"]).To(Equal(openapi.Version))
+		Expect(doc["paths"]).To(HaveKey("/v1/books"))
+		Expect(doc["paths"]).To(HaveKey("/v1/books/{id}"))
+		Expect(at(doc, "paths", "/v1/books")).To(And(HaveKey("get"), HaveKey("post")))
+		Expect(at(doc, "paths", "/v1/books", "post", "operationId")).To(Equal("postV1Books"))
+		Expect(doc["tags"]).To(ConsistOf(map[string]any{"name": "books"}))
+	})
+
+	It("maps validator tags to schema constraints", func() {
+		schema := at(doc, "components", "schemas", "CreateBookRequest").(map[string]any)
+		Expect(schema["required"]).To(ConsistOf("title", "pages"))
+		Expect(at(schema, "properties", "title", "minLength")).To(BeEquivalentTo(3))
+		Expect(at(schema, "properties", "genre", "enum")).To(Equal([]any{"fiction", "poetry"}))
+		Expect(at(schema, "properties", "pages", "minimum")).To(BeEquivalentTo(10))
+		Expect(at(schema, "properties", "pages", "exclusiveMaximum")).To(BeEquivalentTo(5000))
+		Expect(at(schema, "properties", "email", "format")).To(Equal("email"))
+		Expect(at(schema, "properties", "tags", "maxItems")).To(BeEquivalentTo(5))
+		Expect(at(schema, "properties", "tags", "items", "maxLength")).To(BeEquivalentTo(12))
+		Expect(at(schema, "properties", "tags", "items", "pattern")).To(Equal("^[a-zA-Z0-9]+$"))
+		Expect(schema["properties"]).NotTo(HaveKey("Secret"))
+	})
+
+	It("uses the param as request body of writes", func() {
+		post := at(doc, "paths", "/v1/books", "post").(map[string]any)
+		Expect(at(post, "requestBody", "content", "application/json", "schema", "$ref")).To(Equal("#/components/schemas/CreateBookRequest"))
+		Expect(post["security"]).To(Equal([]any{map[string]any{"bearerAuth": []any{}}}))
+		Expect(post["responses"]).To(And(HaveKey("200"), HaveKey("400"), HaveKey("401"), HaveKey("429"), HaveKey("500")))
+		Expect(post["x-rate-limit"]).To(Equal("writes"))
+		Expect(at(doc, "components", "securitySchemes", "bearerAuth", "scheme")).To(Equal("bearer"))
+	})
+
+	It("wraps responses in the api.Response envelope", func() {
+		list := at(doc, "paths", "/v1/books", "get", "responses", "200", "content", "application/json", "schema").(map[string]any)
+		envelope := list["allOf"].([]any)
+		Expect(envelope[0]).To(Equal(map[string]any{"$ref": "#/components/schemas/Response"}))
+		Expect(at(envelope[1].(map[string]any), "properties", "data", "items", "$ref")).To(Equal("#/components/schemas/Book"))
+		Expect(at(doc, "components", "schemas", "Response", "properties", "meta", "$ref")).To(Equal("#/components/schemas/Metadata"))
+		Expect(at(doc, "components", "schemas", "Response", "properties", "error", "$ref")).To(Equal("#/components/schemas/ErrorModel"))
+		Expect(at(doc, "components", "schemas", "ErrorModel", "properties")).To(And(HaveKey("code"), HaveKey("status"), HaveKey("fields")))
+		Expect(at(doc, "components", "schemas", "Book", "properties", "published", "format")).To(Equal("date-time"))
+		Expect(at(doc, "components", "schemas", "Author", "properties", "books", "items", "$ref")).To(Equal("#/components/schemas/Book"))
+	})
+
+	It("documents list and url search parameters", func() {
+		var names []string
+		schemas := map[string]any{}
+		for _, p := range at(doc, "paths", "/v1/books", "get", "parameters").([]any) {
+			names = append(names, p.(map[string]any)["name"].(string))
+			schemas[names[len(names)-1]] = p.(map[string]any)["schema"]
+		}
+		Expect(names).To(ContainElements("limit", "offset", "page", "genre", "pages_null", "pages_gt", "pages_lte", "~pages~", "sort_title",
+			"pages_in", "pages_between", "pages_ieq", "case", "or", "q"))
+		Expect(schemas["pages_gt"]).To(Equal(map[string]any{"type": "integer", "format": "int64"}))
+	})
+
+	It("documents path parameters, roles and deprecation", func() {
+		get := at(doc, "paths", "/v1/books/{id}", "get").(map[string]any)
+		Expect(get["parameters"]).To(ContainElement(map[string]any{
+			"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string", "format": "uuid"},
+		}))
+		Expect(get).NotTo(HaveKey("requestBody"))
+		Expect(get["x-roles"]).To(Equal([]any{"reader"}))
+		Expect(get["deprecated"]).To(BeTrue())
+		Expect(get["description"]).To(ContainSubstring("2030-01-01"))
+	})
+
+	It("serves the document of mounted routes", func() {
+		registry := api.NewRegistry().WithAuthenticator(func(c router.Context) { c.Next() })
+		mux := router.NewMux()
+		Expect(registry.Mount(mux, routes...)).To(Succeed())
+		mux.GET("/openapi.json", openapi.Handler(openapi.Info{Title: "library"}, registry))
+		mux.GET("/docs", openapi.SwaggerUI("library", "/openapi.json"))
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var out map[string]any
+		Expect(json.Unmarshal(rec.Body.Bytes(), &out)).To(Succeed())
+		Expect(out["paths"]).To(HaveLen(2))
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+		Expect(rec.Body.String()).To(And(ContainSubstring("openapi.json"), ContainSubstring(openapi.SwaggerUIAssets+"/swagger-ui-bundle.js")))
+
+		mux.GET("/docs/local", openapi.SwaggerUI("library", "/openapi.json", "/static/swagger-ui/"))
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/local", nil))
+		Expect(rec.Body.String()).To(And(ContainSubstring(`"/static/swagger-ui/swagger-ui-bundle.js"`), Not(ContainSubstring("unpkg.com"))))
+	})
+})
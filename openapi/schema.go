@@ -0,0 +1,293 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	uuidType          = reflect.TypeOf(uuid.UUID{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// validator tags mapped to string formats
+var validateFormats = map[string]string{
+	"email": "email", "url": "uri", "uri": "uri", "http_url": "uri", "uuid": "uuid", "uuid3": "uuid",
+	"uuid4": "uuid", "uuid5": "uuid", "datetime": "date-time", "ip": "ip", "ipv4": "ipv4", "ipv6": "ipv6",
+	"hostname": "hostname", "hostname_rfc1123": "hostname", "fqdn": "hostname", "password": "password",
+	"base64": "byte",
+}
+
+// validator tags mapped to string patterns
+var validatePatterns = map[string]string{
+	"alpha": "^[a-zA-Z]+$", "alphanum": "^[a-zA-Z0-9]+$", "numeric": `^[-+]?[0-9]+(?:\.[0-9]+)?$`,
+	"number": "^[0-9]+$", "e164": `^\+[1-9]?[0-9]{7,14}$`, "lowercase": "^[^A-Z]*$", "uppercase": "^[^a-z]*$",
+}
+
+// schemas converts go types to schemas, collecting named struct types as components
+type schemas struct {
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// define registers out as the schema of t under name and returns a reference to it
+func (s *schemas) define(t reflect.Type, name string, out *Schema) *Schema {
+	s.names[t], s.defs[name] = name, out
+	return ref(name)
+}
+
+func (s *schemas) of(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "duration in nanoseconds"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", Format: "byte"}
+	case implements(t, jsonMarshalerType):
+		return &Schema{}
+	case implements(t, textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		} else if name, ok := s.names[t]; ok {
+			return ref(name)
+		}
+		name := s.name(t)
+		// registered before the fields are walked so that recursive types end up as references
+		s.names[t] = name
+		return s.define(t, name, s.object(t))
+	}
+	return &Schema{}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+var genericArgs = regexp.MustCompile(`\[(.*)\]`)
+
+// name derives a component name from t. type arguments of generic types are appended
+// e.g. ListRequestWithID[github.com/google/uuid.UUID] becomes ListRequestWithID_UUID
+func (s *schemas) name(t reflect.Type) (out string) {
+	out = t.Name()
+	if m := genericArgs.FindStringSubmatch(out); m != nil {
+		args := strings.Split(m[1], ",")
+		for i := range args {
+			arg := args[i][strings.LastIndex(args[i], "/")+1:]
+			args[i] = strings.Trim(arg[strings.LastIndex(arg, ".")+1:], "*[] ")
+		}
+		out = strings.Join(append([]string{out[:strings.Index(out, "[")]}, args...), "_")
+	}
+	if _, taken := s.defs[out]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		out = strings.ToUpper(pkg[:1]) + pkg[1:] + out
+	}
+	return
+}
+
+func (s *schemas) object(t reflect.Type) (out *Schema) {
+	out = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.fields(t, out)
+	return
+}
+
+func (s *schemas) fields(t reflect.Type, out *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" {
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, out)
+			}
+			continue
+		} else if !field.IsExported() {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		prop := s.of(field.Type)
+		if required := applyValidation(prop, ft, field.Tag.Get("validate")); required || field.Tag.Get("binding") == "required" {
+			out.Required = append(out.Required, name)
+		}
+		out.Properties[name] = prop
+	}
+}
+
+// applyValidation adds the constraints of go-playground/validator tags to a schema of t.
+// tags following dive apply to the items of slices and maps
+func applyValidation(schema *Schema, t reflect.Type, tag string) (required bool) {
+	if tag == "" || schema.Ref != "" {
+		return tag != "" && hasTag(tag, "required")
+	}
+	tags := strings.Split(tag, ",")
+	for i, rule := range tags {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			items, elem := schema.Items, t.Elem()
+			if t.Kind() == reflect.Map {
+				items = schema.AdditionalProperties
+			}
+			if items != nil {
+				applyValidation(items, elem, strings.Join(tags[i+1:], ","))
+			}
+			return
+		case "min", "gte":
+			setBound(schema, t, param, 0, false)
+		case "max", "lte":
+			setBound(schema, t, param, 0, true)
+		case "gt":
+			setBound(schema, t, param, 1, false)
+		case "lt":
+			setBound(schema, t, param, -1, true)
+		case "len":
+			setBound(schema, t, param, 0, false)
+			setBound(schema, t, param, 0, true)
+		case "eq":
+			schema.Const = enumValue(t, param)
+		case "oneof":
+			for _, val := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(t, strings.Trim(val, "'")))
+			}
+		case "startswith":
+			schema.Pattern = "^" + regexp.QuoteMeta(param)
+		case "endswith":
+			schema.Pattern = regexp.QuoteMeta(param) + "$"
+		case "contains":
+			schema.Pattern = regexp.QuoteMeta(param)
+		default:
+			if format, ok := validateFormats[name]; ok {
+				schema.Format = format
+			} else if pattern, ok := validatePatterns[name]; ok {
+				schema.Pattern = pattern
+			}
+		}
+	}
+	return
+}
+
+func hasTag(tag, name string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			return false
+		} else if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setBound sets the lower or upper bound of t. for strings, slices and maps the bound
+// is on their size and offset turns the exclusive gt and lt into inclusive bounds
+func setBound(schema *Schema, t reflect.Type, param string, offset int, upper bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	var size **int
+	switch t.Kind() {
+	case reflect.String:
+		size = pick(upper, &schema.MaxLength, &schema.MinLength)
+	case reflect.Slice, reflect.Array:
+		size = pick(upper, &schema.MaxItems, &schema.MinItems)
+	case reflect.Map:
+		size = pick(upper, &schema.MaxProperties, &schema.MinProperties)
+	default:
+		switch {
+		case offset != 0 && upper:
+			schema.ExclusiveMaximum = &n
+		case offset != 0:
+			schema.ExclusiveMinimum = &n
+		case upper:
+			schema.Maximum = &n
+		default:
+			schema.Minimum = &n
+		}
+		return
+	}
+	val := int(n) + offset
+	*size = &val
+}
+
+func pick[T any](first bool, a, b T) T {
+	if first {
+		return a
+	}
+	return b
+}
+
+func enumValue(t reflect.Type, val string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return i
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return val
+}
+
+func float(n float64) *float64 {
+	return &n
+}
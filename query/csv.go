@@ -54,11 +54,11 @@ func (h *csvHeaders) IsTitle() bool {
 }
 
 func (h *csvHeaders) Read(header string) (out string) {
-	var i int
 	if h.Empty() {
 		return
-	} else if i = h.Map[header]; i > h.data.Size() {
+	} else if i, ok := h.Map[header]; !ok || i >= h.data.Size() {
 		return
+	} else {
+		return h.data[i]
 	}
-	return h.data[i]
 }
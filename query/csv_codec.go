@@ -0,0 +1,583 @@
+package query
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+
+	"github.com/kod2ulz/gostart/utils"
+)
+
+// ErrInvalidCSV is matched by the errors of csv that does not decode, see CSVLineError
+var ErrInvalidCSV error = &inputError{"invalid csv"}
+
+const utf8BOM = "\ufeff"
+
+// CSVOption configures csv decoders and encoders
+type CSVOption func(*csvOptions)
+
+type csvOptions struct {
+	delimiter  rune
+	lazyQuotes bool
+	bom        bool
+	formulas   bool
+}
+
+// CSVDelimiter separates fields with r instead of commas e.g. ';' or '\t'
+func CSVDelimiter(r rune) CSVOption {
+	return func(o *csvOptions) { o.delimiter = r }
+}
+
+// CSVLazyQuotes decodes quotes appearing in unquoted fields and unescaped in quoted ones
+func CSVLazyQuotes() CSVOption {
+	return func(o *csvOptions) { o.lazyQuotes = true }
+}
+
+// CSVWithBOM starts encoded csv with a utf-8 byte order mark, for spreadsheets to read it as utf-8
+func CSVWithBOM() CSVOption {
+	return func(o *csvOptions) { o.bom = true }
+}
+
+// CSVEscapeFormulas prefixes cells starting with =, +, -, @, a tab or a carriage return with
+// a ' so that spreadsheets opening the csv show them as text instead of running them as
+// formulas. numbers are left as they are. the api csv encoder does this for its downloads
+func CSVEscapeFormulas() CSVOption {
+	return func(o *csvOptions) { o.formulas = true }
+}
+
+func csvOptionsOf(opts []CSVOption) (out csvOptions) {
+	out.delimiter = ','
+	for i := range opts {
+		opts[i](&out)
+	}
+	return
+}
+
+// CSVLineError is the error of a line of csv that did not decode, of the value of column
+// when it has one
+type CSVLineError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *CSVLineError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVLineError) Unwrap() error { return e.Err }
+
+func (e *CSVLineError) Is(target error) bool { return target == ErrInvalidCSV }
+
+// ErrorCode is the code of the api error the error is reported as
+func (e *CSVLineError) ErrorCode() string { return "ValidationError" }
+
+// CSVErrors are the errors of the lines left out by a decoder
+type CSVErrors []*CSVLineError
+
+func (e CSVErrors) Error() string {
+	lines := make([]string, 0, 3)
+	for i := 0; i < len(e) && i < cap(lines); i++ {
+		lines = append(lines, e[i].Error())
+	}
+	if len(e) > len(lines) {
+		lines = append(lines, fmt.Sprintf("and %d more", len(e)-len(lines)))
+	}
+	return fmt.Sprintf("%d invalid csv lines: %s", len(e), strings.Join(lines, "; "))
+}
+
+func (e CSVErrors) Unwrap() []error {
+	out := make([]error, len(e))
+	for i := range e {
+		out[i] = e[i]
+	}
+	return out
+}
+
+// csvField is a field of a struct read from and written to a csv column, named by the
+// first of the | separated names of its csv tag, else its json name or its name. the
+// other names are aliases decoders accept as headers e.g. csv:"name|Full Name,required"
+type csvField struct {
+	names    []string
+	index    []int
+	required bool
+}
+
+func csvFieldsOf(t reflect.Type) (out []csvField) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		names, opts, _ := strings.Cut(field.Tag.Get("csv"), ",")
+		if names == "" {
+			names, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+		if names == "-" {
+			continue
+		} else if ft := field.Type; field.Anonymous && names == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, f := range csvFieldsOf(ft) {
+					f.index = append([]int{i}, f.index...)
+					out = append(out, f)
+				}
+			}
+			continue
+		} else if !field.IsExported() {
+			continue
+		} else if names == "" {
+			names = field.Name
+		}
+		out = append(out, csvField{
+			names:    strings.Split(names, "|"),
+			index:    []int{i},
+			required: strings.Contains(","+opts+",", ",required,"),
+		})
+	}
+	return
+}
+
+// NewCSVDecoder reads csv with a header row into T, a struct with csv tags. see csvField.
+// rows with values that do not parse or fail validation are left out and reported by Errors
+//
+//	books := query.NewCSVDecoder[Book](file, query.CSVDelimiter(';'))
+//	for books.Next() {
+//		book := books.Value()
+//	}
+//	err, invalid := books.Err(), books.Errors()
+func NewCSVDecoder[T any](r io.Reader, opts ...CSVOption) *csvDecoder[T] {
+	o := csvOptionsOf(opts)
+	in := csv.NewReader(r)
+	in.Comma, in.LazyQuotes, in.FieldsPerRecord, in.ReuseRecord = o.delimiter, o.lazyQuotes, -1, true
+	return &csvDecoder[T]{in: in}
+}
+
+type csvDecoder[T any] struct {
+	in      *csv.Reader
+	headers CSV
+	fields  []csvField
+	columns []string // header of each field, empty when missing
+	line    int
+	value   T
+	errs    CSVErrors
+	err     error
+}
+
+// Next decodes the next valid row, false at the end of the csv or when it cannot be read
+func (d *csvDecoder[T]) Next() bool {
+	if d.err != nil {
+		return false
+	} else if d.headers == nil {
+		if d.err = d.readHeader(); d.err != nil {
+			return false
+		}
+	}
+	for {
+		record, err := d.in.Read()
+		if err == io.EOF {
+			return false
+		}
+		d.line, _ = d.in.FieldPos(0)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			d.errs = append(d.errs, &CSVLineError{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		} else if err != nil {
+			d.err = err
+			return false
+		} else if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if d.value, err = d.decode(record); err == nil {
+			return true
+		}
+	}
+}
+
+func (d *csvDecoder[T]) readHeader() error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("cannot decode csv into %s, expected a struct", t)
+	}
+	header, err := d.in.Read()
+	if err == io.EOF {
+		return errors.Wrap(ErrInvalidCSV, "missing header")
+	} else if err != nil {
+		return err
+	}
+	titles := make([]string, len(header))
+	for i := range header {
+		titles[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], utf8BOM)))
+	}
+	d.headers = Csv(titles...)
+	d.fields = csvFieldsOf(t)
+	d.columns = make([]string, len(d.fields))
+	titled := d.headers.Data(titles...)
+	for i, field := range d.fields {
+		for _, name := range field.names {
+			if name = strings.ToLower(name); titled.Read(name) == name {
+				d.columns[i] = name
+				break
+			}
+		}
+		if d.columns[i] == "" && field.required {
+			return errors.Wrapf(ErrInvalidCSV, "missing header %s", field.names[0])
+		}
+	}
+	return nil
+}
+
+// decode sets the fields of a value from record, recording the errors of the line
+func (d *csvDecoder[T]) decode(record []string) (out T, err error) {
+	d.headers.Data(record...)
+	row := reflect.ValueOf(&out).Elem()
+	errs := len(d.errs)
+	for i, field := range d.fields {
+		if d.columns[i] == "" {
+			continue
+		}
+		raw := strings.TrimSpace(d.headers.Read(d.columns[i]))
+		if e := setCSVValue(fieldByIndex(row, field.index), raw); e != nil {
+			d.errs = append(d.errs, &CSVLineError{Line: d.line, Column: field.names[0], Err: e})
+		}
+	}
+	if len(d.errs) == errs {
+		d.validate(out)
+	}
+	if len(d.errs) > errs {
+		return out, d.errs[len(d.errs)-1]
+	}
+	return
+}
+
+func (d *csvDecoder[T]) validate(value T) {
+	err := utils.Validate.Struct(value)
+	var invalid validator.ValidationErrors
+	if err == nil {
+		return
+	} else if !errors.As(err, &invalid) {
+		d.errs = append(d.errs, &CSVLineError{Line: d.line, Err: err})
+		return
+	}
+	for _, fe := range invalid {
+		column := fe.Field()
+		for _, field := range d.fields {
+			if f := reflect.TypeOf(value).FieldByIndex(field.index); f.Name == fe.StructField() {
+				column = field.names[0]
+				break
+			}
+		}
+		d.errs = append(d.errs, &CSVLineError{Line: d.line, Column: column, Err: errors.Errorf("failed on the %s rule", fe.Tag())})
+	}
+}
+
+// Value is the row decoded by the last call to Next
+func (d *csvDecoder[T]) Value() T {
+	return d.value
+}
+
+// Line is the line of the row decoded by the last call to Next
+func (d *csvDecoder[T]) Line() int {
+	return d.line
+}
+
+// Err is the error that stopped decoding, nil at the end of the csv
+func (d *csvDecoder[T]) Err() error {
+	return d.err
+}
+
+// Errors are the errors of the lines left out so far
+func (d *csvDecoder[T]) Errors() CSVErrors {
+	return d.errs
+}
+
+// DecodeCSV decodes the valid rows of csv, failing with CSVErrors when any is invalid
+func DecodeCSV[T any](r io.Reader, opts ...CSVOption) (out []T, err error) {
+	d := NewCSVDecoder[T](r, opts...)
+	for d.Next() {
+		out = append(out, d.Value())
+	}
+	if err = d.Err(); err == nil && len(d.Errors()) > 0 {
+		err = d.Errors()
+	}
+	return
+}
+
+// fieldByIndex is the field at index, allocating the nil pointers of embedded structs
+func fieldByIndex(val reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val
+}
+
+var (
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setCSVValue parses raw into val, leaving it zero when raw is empty
+func setCSVValue(val reflect.Value, raw string) (err error) {
+	if raw == "" {
+		return
+	} else if val.Kind() == reflect.Pointer {
+		ptr := reflect.New(val.Type().Elem())
+		if err = setCSVValue(ptr.Elem(), raw); err == nil {
+			val.Set(ptr)
+		}
+		return
+	}
+	switch ptr := val.Addr(); {
+	case val.Type() == timeType:
+		var t interface{}
+		if t, err = FieldTime.Parse(utils.Value(raw)); err == nil {
+			val.Set(reflect.ValueOf(t))
+		}
+		return
+	case ptr.Type().Implements(scannerType):
+		return ptr.Interface().(sql.Scanner).Scan(raw)
+	case ptr.Type().Implements(textUnmarshalerType):
+		return ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+	if set := val.Addr().MethodByName("Set"); set.IsValid() && set.Type().NumIn() == 1 && set.Type().NumOut() == 0 {
+		// optional.* values
+		arg := reflect.New(set.Type().In(0)).Elem()
+		if err = setCSVValue(arg, raw); err == nil {
+			set.Call([]reflect.Value{arg})
+		}
+		return
+	}
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(raw)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(raw); err == nil {
+			val.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(raw, 10, val.Type().Bits()); err == nil {
+			val.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(raw, 10, val.Type().Bits()); err == nil {
+			val.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(raw, val.Type().Bits()); err == nil {
+			val.SetFloat(f)
+		}
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		err = json.Unmarshal([]byte(raw), val.Addr().Interface())
+	default:
+		err = errors.Errorf("cannot decode csv into %s", val.Type())
+	}
+	if numErr := (*strconv.NumError)(nil); errors.As(err, &numErr) {
+		err = errors.Errorf("%q is not a valid %s", raw, val.Kind())
+	}
+	return
+}
+
+// NewCSVEncoder writes values of T, structs with csv tags, as csv rows after a header
+// row of the names of their fields. see csvField
+func NewCSVEncoder[T any](w io.Writer, opts ...CSVOption) *csvEncoder[T] {
+	return &csvEncoder[T]{out: NewCSVWriter(w, reflect.TypeOf((*T)(nil)).Elem(), opts...)}
+}
+
+type csvEncoder[T any] struct {
+	out *csvWriter
+}
+
+// Encode writes rows, after the header on the first call
+func (e *csvEncoder[T]) Encode(rows ...T) (err error) {
+	if err = e.out.WriteHeader(); err != nil {
+		return
+	}
+	for i := range rows {
+		if err = e.out.write(reflect.ValueOf(&rows[i]).Elem()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// EncodeStream writes the rows of a stream of the builder as they are read, leaving the
+// stream to be closed by the caller
+func (e *csvEncoder[T]) EncodeStream(rows RowStream[T]) (err error) {
+	if err = e.out.WriteHeader(); err != nil {
+		return
+	}
+	for rows.Next() {
+		value := rows.Value()
+		if err = e.out.write(reflect.ValueOf(&value).Elem()); err != nil {
+			return
+		}
+	}
+	return rows.Err()
+}
+
+// Flush writes buffered rows, returning any error of the writes so far
+func (e *csvEncoder[T]) Flush() error {
+	return e.out.Flush()
+}
+
+// NewCSVWriter writes values of t, a type only known at run time, like NewCSVEncoder does.
+// values that are not structs are written to a single value column
+func NewCSVWriter(w io.Writer, t reflect.Type, opts ...CSVOption) *csvWriter {
+	o := csvOptionsOf(opts)
+	out := csv.NewWriter(w)
+	out.Comma = o.delimiter
+	e := &csvWriter{w: w, out: out, bom: o.bom, formulas: o.formulas}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		e.fields = csvFieldsOf(t)
+	} else {
+		e.fields = []csvField{{names: []string{"value"}}}
+	}
+	e.record = make([]string, len(e.fields))
+	return e
+}
+
+type csvWriter struct {
+	w        io.Writer
+	out      *csv.Writer
+	bom      bool
+	formulas bool
+	fields   []csvField
+	record   []string
+	header   bool
+}
+
+// WriteHeader writes the header row unless it was written already
+func (e *csvWriter) WriteHeader() (err error) {
+	if e.header {
+		return
+	} else if e.header = true; e.bom {
+		if _, err = io.WriteString(e.w, utf8BOM); err != nil {
+			return
+		}
+	}
+	for i := range e.fields {
+		e.record[i] = e.fields[i].names[0]
+	}
+	return e.out.Write(e.record)
+}
+
+// Write writes value as a row, after the header when it was not written yet. nil values
+// are written as rows of empty cells
+func (e *csvWriter) Write(value any) (err error) {
+	if err = e.WriteHeader(); err != nil {
+		return
+	}
+	return e.write(reflect.ValueOf(value))
+}
+
+// Flush writes buffered rows, returning any error of the writes so far
+func (e *csvWriter) Flush() error {
+	e.out.Flush()
+	return e.out.Error()
+}
+
+func (e *csvWriter) write(row reflect.Value) (err error) {
+	for i, field := range e.fields {
+		val := row
+		for _, x := range field.index {
+			if val = reflect.Indirect(val); !val.IsValid() {
+				break
+			}
+			val = val.Field(x)
+		}
+		if e.record[i], err = csvString(val); err != nil {
+			return errors.Wrapf(err, "failed to encode %s", field.names[0])
+		} else if e.formulas {
+			e.record[i] = escapeFormula(e.record[i])
+		}
+	}
+	return e.out.Write(e.record)
+}
+
+// escapeFormula prefixes cells that spreadsheets would run as formulas with a ', leaving
+// numbers as they are e.g. -5
+func escapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	} else if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// csvString formats values as their text, times as RFC 3339 with their fractional seconds
+// and composite values as json.
+// nil, null and unset optional values are empty
+func csvString(val reflect.Value) (string, error) {
+	for val.IsValid() && (val.Kind() == reflect.Pointer || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			return "", nil
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() || (val.Kind() == reflect.Slice || val.Kind() == reflect.Map) && val.IsNil() {
+		return "", nil
+	}
+	if value, set := utils.Null.Value(val.Interface()); !set {
+		return "", nil
+	} else if valuer, ok := value.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err != nil || v == nil {
+			return "", err
+		} else {
+			return csvString(reflect.ValueOf(v))
+		}
+	} else if reflect.TypeOf(value) != val.Type() {
+		return csvString(reflect.ValueOf(value))
+	}
+	if val.Type() == timeType {
+		return val.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	} else if tm, ok := val.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch val.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		data, err := json.Marshal(val.Interface())
+		return string(data), err
+	}
+	return fmt.Sprint(val.Interface()), nil
+}
+
+// EncodeCSV writes rows as csv, with a header row even when there are none
+func EncodeCSV[T any](w io.Writer, rows []T, opts ...CSVOption) error {
+	e := NewCSVEncoder[T](w, opts...)
+	if err := e.Encode(rows...); err != nil {
+		return err
+	}
+	return e.Flush()
+}
@@ -0,0 +1,127 @@
+package query_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markphelps/optional"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+)
+
+type importedBook struct {
+	ID        int64          `csv:"id"`
+	Title     string         `csv:"title|Book Title,required" validate:"required"`
+	Author    string         `json:"author"`
+	Pages     optional.Int64 `csv:"pages|page count" validate:"omitempty"`
+	Published *time.Time     `csv:"published"`
+	Tags      []string       `csv:"tags"`
+	Secret    string         `csv:"-"`
+}
+
+func TestCSVRead(t *testing.T) {
+	row := query.Csv("id", "title", "author").Data("1", "Things Fall Apart")
+	assert.Equal(t, "Things Fall Apart", row.Read("title"))
+	assert.Equal(t, "", row.Read("author"), "header past the end of the row")
+	assert.Equal(t, "", row.Read("isbn"))
+}
+
+func TestCSVDecode(t *testing.T) {
+	in := "\ufeffID;Book Title;Author;Page Count;published;tags\n" +
+		"1;Things Fall Apart;Achebe;209;1958-06-17;\n" +
+		"2;\"Petals; of Blood\";Ngugi;;;\"[\"\"kenya\"\"]\"\n" +
+		"\n" +
+		"3;Arrow of God;Achebe;many;;\n" +
+		"4;;Achebe;230;;\n"
+	books := query.NewCSVDecoder[importedBook](strings.NewReader(in), query.CSVDelimiter(';'))
+	var out []importedBook
+	for books.Next() {
+		out = append(out, books.Value())
+	}
+	require.Nil(t, books.Err())
+	require.Len(t, out, 2)
+	assert.Equal(t, "Things Fall Apart", out[0].Title)
+	assert.Equal(t, optional.NewInt64(209), out[0].Pages)
+	assert.Equal(t, time.Date(1958, 6, 17, 0, 0, 0, 0, time.UTC), *out[0].Published)
+	assert.Equal(t, "Petals; of Blood", out[1].Title)
+	assert.False(t, out[1].Pages.Present())
+	assert.Nil(t, out[1].Published)
+	assert.Equal(t, []string{"kenya"}, out[1].Tags)
+
+	errs := books.Errors()
+	require.Len(t, errs, 2)
+	assert.Equal(t, 5, errs[0].Line)
+	assert.Equal(t, "pages", errs[0].Column)
+	assert.Equal(t, 6, errs[1].Line)
+	assert.Equal(t, "title", errs[1].Column)
+	assert.ErrorIs(t, errs[0], query.ErrInvalidCSV)
+}
+
+func TestDecodeCSV(t *testing.T) {
+	out, err := query.DecodeCSV[importedBook](strings.NewReader("id,author\n1,Achebe\n"))
+	assert.Nil(t, out)
+	assert.ErrorIs(t, err, query.ErrInvalidCSV, "missing required header")
+
+	out, err = query.DecodeCSV[importedBook](strings.NewReader("id,title\n1,\"Things \"Fall\" Apart\"\n2,Weep Not Child\n"), query.CSVLazyQuotes())
+	require.Nil(t, err)
+	assert.Equal(t, `Things "Fall" Apart`, out[0].Title)
+
+	out, err = query.DecodeCSV[importedBook](strings.NewReader("id,title\n1,Things Fall Apart\nx,Weep Not Child\n"))
+	assert.Len(t, out, 1)
+	var errs query.CSVErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "1 invalid csv lines: line 3: id: \"x\" is not a valid int64", err.Error())
+}
+
+func TestEncodeCSV(t *testing.T) {
+	published := time.Date(1958, 6, 17, 0, 0, 0, 0, time.UTC)
+	rows := []importedBook{
+		{ID: 1, Title: "Things Fall Apart", Author: "Achebe", Pages: optional.NewInt64(209), Published: &published, Secret: "x"},
+		{ID: 2, Title: "Petals, of Blood", Author: "Ngugi", Tags: []string{"kenya"}},
+	}
+	var buf bytes.Buffer
+	require.Nil(t, query.EncodeCSV(&buf, rows, query.CSVWithBOM()))
+	assert.Equal(t, "\ufeffid,title,author,pages,published,tags\n"+
+		"1,Things Fall Apart,Achebe,209,1958-06-17T00:00:00Z,\n"+
+		"2,\"Petals, of Blood\",Ngugi,,,\"[\"\"kenya\"\"]\"\n", buf.String())
+
+	out, err := query.DecodeCSV[importedBook](&buf)
+	require.Nil(t, err)
+	rows[0].Secret = ""
+	assert.Equal(t, rows, out)
+}
+
+func TestEncodeCSVFormulas(t *testing.T) {
+	published := time.Date(1958, 6, 17, 10, 30, 0, 500, time.UTC)
+	rows := []importedBook{{ID: -1, Title: "=HYPERLINK(\"http://x.io\")", Author: "@Achebe", Pages: optional.NewInt64(-209), Published: &published, Tags: []string{"-"}}}
+	var buf bytes.Buffer
+	require.Nil(t, query.EncodeCSV(&buf, rows, query.CSVEscapeFormulas()))
+	assert.Equal(t, "id,title,author,pages,published,tags\n"+
+		"-1,\"'=HYPERLINK(\"\"http://x.io\"\")\",'@Achebe,-209,1958-06-17T10:30:00.0000005Z,\"[\"\"-\"\"]\"\n", buf.String())
+
+	buf.Reset()
+	w := query.NewCSVWriter(&buf, reflect.TypeOf(""), query.CSVEscapeFormulas())
+	require.Nil(t, w.Write("+1 555"))
+	require.Nil(t, w.Write("+15"))
+	require.Nil(t, w.Flush())
+	assert.Equal(t, "value\n'+1 555\n+15\n", buf.String())
+}
+
+func TestEncodeCSVStream(t *testing.T) {
+	ctx := context.Background()
+	stream, err := query.SQLBuilder(booksDB(t), scanBook).Where(query.LessThanOrEqual("id", 2)).Order(query.Asc("id")).SelectStream(ctx, "books")
+	require.Nil(t, err)
+	defer stream.Close()
+
+	var buf bytes.Buffer
+	books := query.NewCSVEncoder[book](&buf, query.CSVDelimiter('\t'))
+	require.Nil(t, books.EncodeStream(stream))
+	require.Nil(t, books.Flush())
+	assert.Equal(t, "ID\tTitle\tAuthor\tPages\n1\tBook 1\tNgugi\t100\n2\tBook 2\tAchebe\t200\n", buf.String())
+}
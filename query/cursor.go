@@ -0,0 +1,292 @@
+package query
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidCursor error = &inputError{"invalid cursor"}
+
+var cursorSecret = struct {
+	mx  sync.RWMutex
+	key []byte
+	// random is set while the key is generated, warned about once cursors are signed
+	random bool
+	warn   sync.Once
+}{key: []byte(env.Get("CURSOR_SECRET", "").String())}
+
+func init() {
+	if len(cursorSecret.key) > 0 {
+		return
+	}
+	// cursors then only survive as long as the process
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.WithError(err).Error("failed to generate a cursor secret, cursors cannot be used until SQL_QUERY_BUILDER_CURSOR_SECRET is set")
+		return
+	}
+	cursorSecret.key, cursorSecret.random = key, true
+}
+
+// SetCursorSecret sets the key cursors are signed with. instances serving the same
+// clients must share it, e.g. through SQL_QUERY_BUILDER_CURSOR_SECRET
+func SetCursorSecret(secret []byte) {
+	cursorSecret.mx.Lock()
+	defer cursorSecret.mx.Unlock()
+	cursorSecret.key, cursorSecret.random = secret, false
+}
+
+// Cursor holds the sort key values of the row a keyset page starts after, or before
+// when Backward is set. Keys are the order clauses it was created for e.g. [created_at desc, id desc]
+type Cursor struct {
+	Keys     []string
+	Values   []any
+	Backward bool
+}
+
+type cursorValue struct {
+	Type  string          `json:"t,omitempty"`
+	Value json.RawMessage `json:"v"`
+}
+
+type cursorPayload struct {
+	Keys     []string      `json:"k"`
+	Values   []cursorValue `json:"v"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// Encode serialises c into an opaque url safe token signed with the cursor secret
+func (c Cursor) Encode() (out string, err error) {
+	payload := cursorPayload{Keys: c.Keys, Values: make([]cursorValue, len(c.Values)), Backward: c.Backward}
+	for i := range c.Values {
+		if payload.Values[i], err = encodeCursorValue(c.Values[i]); err != nil {
+			return "", errors.Wrapf(err, "failed to encode cursor value of %s", c.Keys[i])
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	sig, err := signCursor(data)
+	if err != nil {
+		return
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// DecodeCursor verifies and parses a token created by Cursor.Encode
+func DecodeCursor(token string) (out Cursor, err error) {
+	data64, sig64, ok := strings.Cut(token, ".")
+	if !ok {
+		return out, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(data64)
+	if err != nil {
+		return out, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sig64)
+	if err != nil {
+		return out, ErrInvalidCursor
+	}
+	expected, err := signCursor(data)
+	if err != nil {
+		return
+	} else if !hmac.Equal(sig, expected) {
+		return out, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err = json.Unmarshal(data, &payload); err != nil || len(payload.Keys) != len(payload.Values) {
+		return out, ErrInvalidCursor
+	}
+	out = Cursor{Keys: payload.Keys, Values: make([]any, len(payload.Values)), Backward: payload.Backward}
+	for i := range payload.Values {
+		if out.Values[i], err = payload.Values[i].decode(); err != nil {
+			return Cursor{}, errors.Wrap(ErrInvalidCursor, err.Error())
+		}
+	}
+	return
+}
+
+func signCursor(data []byte) ([]byte, error) {
+	cursorSecret.mx.RLock()
+	defer cursorSecret.mx.RUnlock()
+	if len(cursorSecret.key) == 0 {
+		return nil, errors.New("no cursor secret, set SQL_QUERY_BUILDER_CURSOR_SECRET")
+	} else if cursorSecret.random {
+		cursorSecret.warn.Do(func() {
+			log.Warn("SQL_QUERY_BUILDER_CURSOR_SECRET is not set, cursors are signed with a random key and only valid in this process")
+		})
+	}
+	mac := hmac.New(sha256.New, cursorSecret.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// encodeCursorValue tags values with their type so that they are decoded to what the
+// database driver expects rather than json's float64 and string
+func encodeCursorValue(v any) (out cursorValue, err error) {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return cursorValue{Value: json.RawMessage("null")}, nil
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return cursorValue{Value: json.RawMessage("null")}, nil
+	}
+	v = val.Interface()
+	switch t := v.(type) {
+	case time.Time:
+		out.Type = "time"
+		v = t.Format(time.RFC3339Nano)
+	case encoding.TextMarshaler:
+		text, e := t.MarshalText()
+		if e != nil {
+			return out, e
+		}
+		out.Type, v = "text", string(text)
+	default:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			out.Type = "int"
+		case reflect.Float32, reflect.Float64:
+			out.Type = "float"
+		}
+	}
+	out.Value, err = json.Marshal(v)
+	return
+}
+
+func (v cursorValue) decode() (out any, err error) {
+	dec := json.NewDecoder(bytes.NewReader(v.Value))
+	dec.UseNumber()
+	if err = dec.Decode(&out); err != nil || out == nil {
+		return
+	}
+	switch v.Type {
+	case "time":
+		if s, ok := out.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case "int":
+		if n, ok := out.(json.Number); ok {
+			return n.Int64()
+		}
+	case "float":
+		if n, ok := out.(json.Number); ok {
+			return n.Float64()
+		}
+	case "text", "":
+		if _, ok := out.(json.Number); !ok {
+			return
+		}
+	}
+	return nil, errors.Errorf("unexpected %s value %s", v.Type, v.Value)
+}
+
+type sortKey struct {
+	field string
+	sort  SortType
+	// column is field as written to queries
+	column string
+}
+
+func (k sortKey) String() string {
+	return k.field + " " + string(k.sort)
+}
+
+// keysetPredicate selects the rows after values in the order of keys, or before them
+// when backward. keys sorted in one direction compare as a row e.g. (a, b) > (?, ?)
+// while mixed directions expand to (a > ?) or (a = ? and b < ?), repeating values
+func keysetPredicate(keys []sortKey, values []any, backward bool) (string, []any) {
+	after := func(k sortKey) string {
+		if (k.sort == SortDesc) != backward {
+			return "<"
+		}
+		return ">"
+	}
+	placeholders, fields := make([]string, len(keys)), make([]string, len(keys))
+	uniform := true
+	for i := range keys {
+		placeholders[i] = ARG_PLACEHOLDER
+		fields[i] = keys[i].column
+		uniform = uniform && keys[i].sort == keys[0].sort
+	}
+	if uniform {
+		if len(keys) == 1 {
+			return fields[0] + " " + after(keys[0]) + " " + ARG_PLACEHOLDER, values
+		}
+		return "(" + strings.Join(fields, ", ") + ") " + after(keys[0]) + " (" + strings.Join(placeholders, ", ") + ")", values
+	}
+	terms, args := make([]string, len(keys)), make([]any, 0, len(keys)*(len(keys)+1)/2)
+	for i := range keys {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, fields[j]+" = "+ARG_PLACEHOLDER)
+			args = append(args, values[j])
+		}
+		parts = append(parts, fields[i]+" "+after(keys[i])+" "+ARG_PLACEHOLDER)
+		args = append(args, values[i])
+		terms[i] = "(" + strings.Join(parts, " and ") + ")"
+	}
+	return "(" + strings.Join(terms, " or ") + ")", args
+}
+
+// nullValue reports whether v is nil, a nil pointer or a valuer of NULL e.g. an invalid sql.NullTime
+func nullValue(v any) bool {
+	if v == nil {
+		return true
+	} else if val := reflect.ValueOf(v); val.Kind() == reflect.Pointer && val.IsNil() {
+		return true
+	} else if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		return err == nil && value == nil
+	}
+	return false
+}
+
+// keysetValuesOf reads the sort keys of row from the struct fields tagged or named after them
+func keysetValuesOf(row any, keys []sortKey) (out []any, err error) {
+	val := reflect.Indirect(reflect.ValueOf(row))
+	if val.Kind() != reflect.Struct {
+		return nil, errors.Errorf("cannot read sort keys of %T, set them with Keyset", row)
+	}
+	out = make([]any, len(keys))
+	for i, key := range keys {
+		column := key.field[strings.LastIndex(key.field, ".")+1:]
+		field, ok := keysetField(val.Type(), column)
+		if !ok {
+			return nil, errors.Errorf("%T has no field for sort key %s, set them with Keyset", row, key.field)
+		}
+		out[i] = val.FieldByIndex(field.Index).Interface()
+	}
+	return
+}
+
+func keysetField(t reflect.Type, column string) (reflect.StructField, bool) {
+	return t.FieldByNameFunc(func(name string) bool {
+		field, _ := t.FieldByName(name)
+		for _, tag := range []string{"db", "json"} {
+			if tagName, _, _ := strings.Cut(field.Tag.Get(tag), ","); tagName != "" {
+				return tagName == column
+			}
+		}
+		return strcase.ToSnake(name) == column
+	})
+}
@@ -0,0 +1,163 @@
+package query_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/kod2ulz/gostart/query"
+	"github.com/stretchr/testify/assert"
+)
+
+type post struct {
+	ID        int64
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// postsDB serves its posts to any select, recording the last one it was asked for
+type postsDB struct {
+	query string
+	args  []interface{}
+	posts []post
+}
+
+func (db *postsDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return nil, nil
+}
+
+func (db *postsDB) Query(_ context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	db.query, db.args = sql, args
+	return &postRows{posts: db.posts, index: -1}, nil
+}
+
+func (db *postsDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return nil
+}
+
+type postRows struct {
+	pgx.Rows
+	posts []post
+	index int
+}
+
+func (r *postRows) Next() bool { r.index++; return r.index < len(r.posts) }
+
+func (r *postRows) Err() error { return nil }
+
+func (r *postRows) Close() {}
+
+func (r *postRows) Scan(dest ...interface{}) error {
+	*dest[0].(*post) = r.posts[r.index]
+	return nil
+}
+
+func scanPost(rows pgx.Rows) (out post, err error) {
+	err = rows.Scan(&out)
+	return
+}
+
+func posts(ids ...int64) (out []post) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range ids {
+		out = append(out, post{ID: id, CreatedAt: day.AddDate(0, 0, int(id))})
+	}
+	return
+}
+
+func TestCursorEncoding(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 8, time.UTC)
+	token, err := query.Cursor{Keys: []string{"created_at desc", "id desc", "name asc"}, Values: []any{at, int32(42), "x"}}.Encode()
+	assert.Nil(t, err)
+
+	cursor, err := query.DecodeCursor(token)
+	assert.Nil(t, err)
+	assert.Equal(t, []any{at, int64(42), "x"}, cursor.Values)
+	assert.False(t, cursor.Backward)
+
+	_, err = query.DecodeCursor(strings.Replace(token, ".", "x.", 1))
+	assert.ErrorIs(t, err, query.ErrInvalidCursor)
+	_, err = query.DecodeCursor("garbage")
+	assert.ErrorIs(t, err, query.ErrInvalidCursor)
+}
+
+func TestKeysetPagination(t *testing.T) {
+	ctx := context.Background()
+	db := &postsDB{posts: posts(5, 4, 3)}
+	sb := query.SQLBuilder(db, scanPost).Where(query.Equal("author", "jane")).Order(query.Desc("created_at", "id")).Limit(2).Keyset()
+	_, out, err := sb.Select(ctx, "posts")
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from posts where author=$1 order by created_at desc, id desc limit 3", db.query)
+	assert.Equal(t, posts(5, 4), out)
+	next, prev := sb.Cursors()
+	assert.NotEmpty(t, next)
+	assert.Empty(t, prev)
+
+	db.posts = posts(3, 2)
+	sb = query.SQLBuilder(db, scanPost).Where(query.Equal("author", "jane")).Order(query.Desc("created_at", "id")).Limit(2).Cursor(next)
+	_, out, err = sb.Select(ctx, "posts")
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from posts where (author=$1) and (created_at, id) < ($2, $3) order by created_at desc, id desc limit 3", db.query)
+	assert.Equal(t, []interface{}{"jane", posts(4)[0].CreatedAt, int64(4)}, db.args)
+	assert.Equal(t, posts(3, 2), out)
+	next, prev = sb.Cursors()
+	assert.Empty(t, next)
+	assert.NotEmpty(t, prev)
+
+	// rows before the cursor are read in reverse and handed back in order
+	db.posts = posts(4, 5)
+	sb = query.SQLBuilder(db, scanPost).Order(query.Desc("created_at", "id")).Limit(2).Cursor(prev)
+	_, out, err = sb.Select(ctx, "posts")
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from posts where (created_at, id) > ($1, $2) order by created_at asc, id asc limit 3", db.query)
+	assert.Equal(t, posts(5, 4), out)
+	next, prev = sb.Cursors()
+	assert.NotEmpty(t, next)
+	assert.Empty(t, prev)
+}
+
+func TestKeysetMixedOrder(t *testing.T) {
+	db := &postsDB{}
+	token, _ := query.Cursor{Keys: []string{"created_at desc", "id asc"}, Values: []any{"2026-01-01", 7}}.Encode()
+	_, _, err := query.SQLBuilder(db, scanPost).Order(query.Desc("created_at"), query.Asc("id")).Cursor(token).Select(context.Background(), "posts")
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from posts where ((created_at < $1) or (created_at = $2 and id > $3)) order by created_at desc, id asc limit 21", db.query)
+
+	_, _, err = query.SQLBuilder(db, scanPost).Order(query.Asc("id")).Cursor(token).Select(context.Background(), "posts")
+	assert.True(t, errors.Is(err, query.ErrInvalidCursor))
+}
+
+func TestKeysetNullKeys(t *testing.T) {
+	db := &postsDB{posts: posts(5, 4)}
+	sb := query.SQLBuilder(db, scanPost).Order(query.Desc("created_at", "id")).Limit(1).Keyset(func(p post) []any {
+		if p.ID == 5 {
+			return []any{(*time.Time)(nil), p.ID}
+		}
+		return []any{p.CreatedAt, p.ID}
+	})
+	_, _, err := sb.Select(context.Background(), "posts")
+	assert.ErrorContains(t, err, "null created_at")
+
+	token, err := query.Cursor{Keys: []string{"created_at desc", "id desc"}, Values: []any{nil, 5}}.Encode()
+	assert.Nil(t, err)
+	_, _, err = query.SQLBuilder(db, scanPost).Order(query.Desc("created_at", "id")).Cursor(token).Select(context.Background(), "posts")
+	assert.ErrorIs(t, err, query.ErrInvalidCursor)
+}
+
+func TestCursorSecret(t *testing.T) {
+	t.Cleanup(func() { query.SetCursorSecret([]byte("cursor-test-secret")) })
+	query.SetCursorSecret([]byte("first"))
+	token, err := query.Cursor{Keys: []string{"id asc"}, Values: []any{1}}.Encode()
+	assert.Nil(t, err)
+
+	query.SetCursorSecret([]byte("second"))
+	_, err = query.DecodeCursor(token)
+	assert.ErrorIs(t, err, query.ErrInvalidCursor, "cursors of other secrets")
+
+	query.SetCursorSecret(nil)
+	_, err = query.Cursor{Keys: []string{"id asc"}, Values: []any{1}}.Encode()
+	assert.ErrorContains(t, err, "CURSOR_SECRET")
+}
@@ -0,0 +1,293 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Dialect writes the parts of queries that differ between databases
+type Dialect interface {
+	Name() string
+	// Placeholder is the n-th (from 1) bind parameter of a query
+	Placeholder(n int) string
+	// Quote quotes an identifier, quoting each part of qualified names e.g. schema.table
+	Quote(identifier string) string
+	// Like is the pattern matching operator, case insensitive or not
+	Like(caseInsensitive bool) string
+	// LimitOffset is the clause paging results, empty when neither is set
+	LimitOffset(limit, offset int64) string
+	Bool(b bool) string
+	// Upsert is the clause of inserts updating the columns in update when a row
+	// conflicting on the columns in conflict exists, ignoring the insert without update columns
+	Upsert(conflict, update []string) string
+	// TextSearch matches columns against the words of a single bind parameter
+	TextSearch(columns []string) string
+	// DistinctFrom compares field to a bind parameter treating nulls as values
+	DistinctFrom(field string, distinct bool) string
+}
+
+var (
+	Postgres Dialect = postgresDialect{}
+	MySQL    Dialect = mysqlDialect{}
+	SQLite   Dialect = sqliteDialect{}
+
+	// TEXT_SEARCH_CONFIG is the postgres text search configuration of TextSearch
+	TEXT_SEARCH_CONFIG = env.Get("TEXT_SEARCH_CONFIG", "simple").String()
+
+	// DefaultDialect is the dialect of builders on connections that do not tell their driver
+	// e.g. pgx pools
+	DefaultDialect = defaultDialect()
+
+	// ErrUnknownDialect is returned for drivers and database names without a dialect
+	ErrUnknownDialect = errors.New("unknown sql dialect")
+)
+
+// LookupDialect returns the dialect of a database/sql driver or database name
+func LookupDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "postgres", "postgresql", "pgx", "pgx/v4", "pgx/v5", "cloudsqlpostgres":
+		return Postgres, nil
+	case "mysql", "mariadb":
+		return MySQL, nil
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	}
+	return nil, errors.Wrapf(ErrUnknownDialect, "%q, set the dialect of builders with Dialect", name)
+}
+
+func defaultDialect() Dialect {
+	name := env.Get("DIALECT", "postgres").String()
+	d, err := LookupDialect(name)
+	if err != nil {
+		log.WithError(err).Errorf("SQL_QUERY_BUILDER_DIALECT is invalid, using postgres")
+		return Postgres
+	}
+	return d
+}
+
+// dialectOf picks the dialect of connections telling their driver e.g. from sqlc.FromSQL
+func dialectOf(dbtx any) (Dialect, error) {
+	if d, ok := dbtx.(interface{ DriverName() string }); ok {
+		return LookupDialect(d.DriverName())
+	}
+	return DefaultDialect, nil
+}
+
+// bindPlaceholders replaces the ARG_PLACEHOLDER of query with the bind parameters of d,
+// numbered from offset+1
+func bindPlaceholders(d Dialect, query string, offset int) string {
+	var out strings.Builder
+	for n := offset + 1; ; n++ {
+		i := strings.Index(query, ARG_PLACEHOLDER)
+		if i < 0 {
+			break
+		}
+		out.WriteString(query[:i])
+		out.WriteString(d.Placeholder(n))
+		query = query[i+len(ARG_PLACEHOLDER):]
+	}
+	out.WriteString(query)
+	return out.String()
+}
+
+// quoteIdentifier quotes each part of a qualified name. parts that are already quoted
+// are kept when their quotes are balanced, everything else is escaped
+func quoteIdentifier(identifier string, quote string) string {
+	parts := identifierParts(identifier, quote)
+	for i := range parts {
+		if parts[i] == "*" || quotedIdentifier(parts[i], quote) {
+			continue
+		}
+		parts[i] = quote + strings.ReplaceAll(parts[i], quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+// identifierParts splits identifier on the dots outside quotes
+func identifierParts(identifier string, quote string) (out []string) {
+	quoted, start := false, 0
+	for i := 0; i < len(identifier); i++ {
+		if strings.HasPrefix(identifier[i:], quote) {
+			quoted = !quoted
+		} else if identifier[i] == '.' && !quoted {
+			out, start = append(out, identifier[start:i]), i+1
+		}
+	}
+	return append(out, identifier[start:])
+}
+
+// quotedIdentifier reports whether part is a single quoted identifier with its inner
+// quotes doubled e.g. "a""b"
+func quotedIdentifier(part string, quote string) bool {
+	if len(part) < 2*len(quote) || !strings.HasPrefix(part, quote) || !strings.HasSuffix(part, quote) {
+		return false
+	}
+	return !strings.Contains(strings.ReplaceAll(part[len(quote):len(part)-len(quote)], quote+quote, ""), quote)
+}
+
+func limitOffset(limit, offset int64, unlimited string) string {
+	switch {
+	case limit > 0 && offset > 0:
+		return "limit " + strconv.FormatInt(limit, 10) + " offset " + strconv.FormatInt(offset, 10)
+	case limit > 0:
+		return "limit " + strconv.FormatInt(limit, 10)
+	case offset > 0 && unlimited != "":
+		return "limit " + unlimited + " offset " + strconv.FormatInt(offset, 10)
+	case offset > 0:
+		return "offset " + strconv.FormatInt(offset, 10)
+	}
+	return ""
+}
+
+// onConflict is the upsert clause of postgres and sqlite
+func onConflict(conflict, update []string, quote func(string) string) string {
+	target := make([]string, len(conflict))
+	for i := range conflict {
+		target[i] = quote(conflict[i])
+	}
+	out := "on conflict (" + strings.Join(target, ", ") + ") do "
+	if len(update) == 0 {
+		return out + "nothing"
+	}
+	set := make([]string, len(update))
+	for i := range update {
+		set[i] = quote(update[i]) + " = excluded." + quote(update[i])
+	}
+	return out + "update set " + strings.Join(set, ", ")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgresDialect) DefaultSchema() string { return "public" }
+
+func (postgresDialect) Quote(identifier string) string { return quoteIdentifier(identifier, `"`) }
+
+func (postgresDialect) Like(caseInsensitive bool) string {
+	if caseInsensitive {
+		return SELECT_LIKE
+	}
+	return "like"
+}
+
+func (postgresDialect) LimitOffset(limit, offset int64) string { return limitOffset(limit, offset, "") }
+
+func (postgresDialect) Bool(b bool) string { return strconv.FormatBool(b) }
+
+func (d postgresDialect) Upsert(conflict, update []string) string {
+	return onConflict(conflict, update, d.Quote)
+}
+
+func (postgresDialect) TextSearch(columns []string) string {
+	config := "'" + strings.ReplaceAll(TEXT_SEARCH_CONFIG, "'", "''") + "'"
+	return fmt.Sprintf("to_tsvector(%s, concat_ws(' ', %s)) @@ plainto_tsquery(%s, %s)", config, strings.Join(columns, ", "), config, ARG_PLACEHOLDER)
+}
+
+func (postgresDialect) DistinctFrom(field string, distinct bool) string {
+	if distinct {
+		return field + " is distinct from " + ARG_PLACEHOLDER
+	}
+	return field + " is not distinct from " + ARG_PLACEHOLDER
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+// DefaultSchema is empty for relations of the current database
+func (mysqlDialect) DefaultSchema() string { return "" }
+
+func (mysqlDialect) Quote(identifier string) string { return quoteIdentifier(identifier, "`") }
+
+// Like relies on the case insensitive collations mysql defaults to
+func (mysqlDialect) Like(caseInsensitive bool) string {
+	if caseInsensitive {
+		return "like"
+	}
+	return "like binary"
+}
+
+// LimitOffset uses the largest limit for offsets without limit, which mysql does not support
+func (mysqlDialect) LimitOffset(limit, offset int64) string {
+	return limitOffset(limit, offset, "18446744073709551615")
+}
+
+func (mysqlDialect) Bool(b bool) string { return strings.ToUpper(strconv.FormatBool(b)) }
+
+// Upsert updates on conflicts with any unique key as mysql does not take a conflict target.
+// inserts are ignored by setting the first conflict column to itself
+func (d mysqlDialect) Upsert(conflict, update []string) string {
+	set := make([]string, 0, len(update))
+	for i := range update {
+		set = append(set, d.Quote(update[i])+" = values("+d.Quote(update[i])+")")
+	}
+	if len(set) == 0 && len(conflict) > 0 {
+		set = append(set, d.Quote(conflict[0])+" = "+d.Quote(conflict[0]))
+	}
+	return "on duplicate key update " + strings.Join(set, ", ")
+}
+
+// TextSearch needs a fulltext index on exactly the columns
+func (mysqlDialect) TextSearch(columns []string) string {
+	return "match (" + strings.Join(columns, ", ") + ") against (" + ARG_PLACEHOLDER + " in natural language mode)"
+}
+
+// DistinctFrom uses the null safe equality of mysql
+func (mysqlDialect) DistinctFrom(field string, distinct bool) string {
+	if distinct {
+		return "not (" + field + " <=> " + ARG_PLACEHOLDER + ")"
+	}
+	return field + " <=> " + ARG_PLACEHOLDER
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) Placeholder(n int) string { return "?" + strconv.Itoa(n) }
+
+func (sqliteDialect) DefaultSchema() string { return "main" }
+
+func (sqliteDialect) Quote(identifier string) string { return quoteIdentifier(identifier, `"`) }
+
+// Like is always like, which sqlite matches case insensitively for ascii only
+func (sqliteDialect) Like(bool) string { return "like" }
+
+func (sqliteDialect) LimitOffset(limit, offset int64) string { return limitOffset(limit, offset, "-1") }
+
+func (sqliteDialect) Bool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (d sqliteDialect) Upsert(conflict, update []string) string {
+	return onConflict(conflict, update, d.Quote)
+}
+
+// TextSearch matches the text as a phrase of the columns, as sqlite has no full text search
+// outside of fts tables
+func (sqliteDialect) TextSearch(columns []string) string {
+	concat := make([]string, len(columns))
+	for i := range columns {
+		concat[i] = "coalesce(" + columns[i] + ", '')"
+	}
+	return "(" + strings.Join(concat, " || ' ' || ") + ") like '%' || " + ARG_PLACEHOLDER + " || '%'"
+}
+
+func (sqliteDialect) DistinctFrom(field string, distinct bool) string {
+	if distinct {
+		return field + " is not " + ARG_PLACEHOLDER
+	}
+	return field + " is " + ARG_PLACEHOLDER
+}
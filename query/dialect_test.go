@@ -0,0 +1,188 @@
+package query_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/sqlc"
+)
+
+type book struct {
+	ID     int64
+	Title  string
+	Author string
+	Pages  int64
+}
+
+func scanBook(rows pgx.Rows) (out book, err error) {
+	err = rows.Scan(&out.ID, &out.Title, &out.Author, &out.Pages)
+	return
+}
+
+func booksDB(t *testing.T) sqlc.DBTX {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	dbtx := sqlc.FromSQL(db, "sqlite3")
+	ctx := context.Background()
+	_, err = dbtx.Exec(ctx, "create table books (id integer primary key, title text not null, author text not null, pages integer not null)")
+	require.Nil(t, err)
+	for i := 1; i <= 10; i++ {
+		tag, err := dbtx.Exec(ctx, "insert into books (id, title, author, pages) values (?, ?, ?, ?)",
+			i, fmt.Sprintf("Book %d", i), []string{"Achebe", "Ngugi"}[i%2], i*100)
+		require.Nil(t, err)
+		assert.True(t, tag.Insert())
+		assert.EqualValues(t, 1, tag.RowsAffected())
+	}
+	return dbtx
+}
+
+func TestSQLiteSelect(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+
+	count, out, err := query.SQLBuilder(db, scanBook).
+		Where(query.Equal("author", "Ngugi"), query.Or(query.GreaterThan("pages", 500), query.Like("title", "book 1"))).
+		Order(query.Desc("pages")).Limit(2).Offset(1).Count().
+		Select(ctx, "books", "id", "title", "author", "pages")
+	require.Nil(t, err)
+	assert.EqualValues(t, 3, count)
+	assert.Equal(t, []int64{7, 1}, ids(out))
+
+	_, out, err = query.SQLBuilder(db, scanBook).Where(query.In("id", 2, 4, 6)).Offset(1).Select(ctx, "books")
+	require.Nil(t, err)
+	assert.Equal(t, []int64{4, 6}, ids(out))
+
+	var title string
+	assert.Equal(t, pgx.ErrNoRows, db.QueryRow(ctx, "select title from books where id = ?", 42).Scan(&title))
+}
+
+func TestSQLiteKeysetPagination(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	page := func(cursor string) ([]int64, string, string) {
+		sb := query.SQLBuilder(db, scanBook).Where(query.NotEqual("id", 5)).Order(query.Asc("author"), query.Desc("id")).Limit(3).Keyset().Cursor(cursor)
+		_, out, err := sb.Select(ctx, "books")
+		require.Nil(t, err)
+		next, prev := sb.Cursors()
+		return ids(out), next, prev
+	}
+
+	first, next, _ := page("")
+	assert.Equal(t, []int64{10, 8, 6}, first)
+	second, next, prev := page(next)
+	assert.Equal(t, []int64{4, 2, 9}, second)
+	third, next, _ := page(next)
+	assert.Equal(t, []int64{7, 3, 1}, third)
+	assert.Empty(t, next)
+
+	back, _, prev := page(prev)
+	assert.Equal(t, first, back)
+	assert.Empty(t, prev)
+}
+
+func TestSQLiteStream(t *testing.T) {
+	var stream query.RowStream[book]
+	stream, err := query.SQLBuilder(booksDB(t), scanBook).Order(query.Asc("id")).Limit(4).Count().SelectStream(context.Background(), "books")
+	require.Nil(t, err)
+	var read []book
+	for stream.Next() {
+		read = append(read, stream.Value())
+	}
+	stream.Close()
+	require.Nil(t, stream.Err())
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids(read))
+	total, err := stream.Total(context.Background())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 10, total)
+}
+
+func TestDialects(t *testing.T) {
+	for _, tc := range []struct {
+		dialect           query.Dialect
+		criteria, quoted  string
+		paging, upsert    string
+		upsertWithoutSets string
+	}{
+		{
+			dialect:           query.Postgres,
+			criteria:          "(id=$1) and (title " + query.SELECT_LIKE + " $2)",
+			quoted:            `"public"."books"`,
+			paging:            "offset 20",
+			upsert:            `on conflict ("id") do update set "title" = excluded."title"`,
+			upsertWithoutSets: `on conflict ("id") do nothing`,
+		},
+		{
+			dialect:           query.MySQL,
+			criteria:          "(id=?) and (title like ?)",
+			quoted:            "`public`.`books`",
+			paging:            "limit 18446744073709551615 offset 20",
+			upsert:            "on duplicate key update `title` = values(`title`)",
+			upsertWithoutSets: "on duplicate key update `id` = `id`",
+		},
+		{
+			dialect:           query.SQLite,
+			criteria:          "(id=?1) and (title like ?2)",
+			quoted:            `"public"."books"`,
+			paging:            "limit -1 offset 20",
+			upsert:            `on conflict ("id") do update set "title" = excluded."title"`,
+			upsertWithoutSets: `on conflict ("id") do nothing`,
+		},
+	} {
+		criteria, _ := query.SQLBuilder[any](nil, nil).Dialect(tc.dialect).Where(query.Equal("id", 1), query.Like("title", "go")).Criteria()
+		assert.Equal(t, tc.criteria, criteria.String(), tc.dialect.Name())
+		assert.Equal(t, tc.quoted, tc.dialect.Quote("public.books"), tc.dialect.Name())
+		assert.Equal(t, tc.paging, tc.dialect.LimitOffset(0, 20), tc.dialect.Name())
+		assert.Equal(t, tc.upsert, tc.dialect.Upsert([]string{"id"}, []string{"title"}), tc.dialect.Name())
+		assert.Equal(t, tc.upsertWithoutSets, tc.dialect.Upsert([]string{"id"}, nil), tc.dialect.Name())
+	}
+}
+
+func ids(books []book) (out []int64) {
+	for i := range books {
+		out = append(out, books[i].ID)
+	}
+	return
+}
+
+func TestQuoteIdentifiers(t *testing.T) {
+	for identifier, quoted := range map[string]string{
+		`books.*`:              `"books".*`,
+		`"my.schema".books`:    `"my.schema"."books"`,
+		`"a""b"`:               `"a""b"`,
+		`"books" where 1=1 --`: `"""books"" where 1=1 --"`,
+		`"books`:               `"""books"`,
+		`"x"y"`:                `"""x""y"""`,
+	} {
+		assert.Equal(t, quoted, query.Postgres.Quote(identifier), identifier)
+	}
+	assert.Equal(t, "`a``b`", query.MySQL.Quote("a`b"))
+}
+
+func TestLookupDialect(t *testing.T) {
+	d, err := query.LookupDialect("pgx")
+	assert.Nil(t, err)
+	assert.Equal(t, query.Postgres, d)
+
+	_, err = query.LookupDialect("oracle")
+	assert.ErrorIs(t, err, query.ErrUnknownDialect)
+
+	conn, err := sql.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	db := sqlc.FromSQL(conn, "sqlite-custom")
+	_, _, err = query.SQLBuilder(db, scanBook).Select(context.Background(), "sqlite_master", "1", "'', '', 0")
+	assert.ErrorIs(t, err, query.ErrUnknownDialect)
+	_, _, err = query.SQLBuilder(db, scanBook).Dialect(query.SQLite).Select(context.Background(), "sqlite_master", "1", "'', '', 0")
+	assert.Nil(t, err)
+}
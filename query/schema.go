@@ -0,0 +1,270 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownIdentifier is matched by the errors of queries referencing relations or
+// fields their schema does not allow
+var ErrUnknownIdentifier = errors.New("unknown identifier")
+
+type UnknownIdentifierError struct {
+	Kind     string // relation or field
+	Name     string
+	Relation string
+}
+
+func (e *UnknownIdentifierError) Error() string {
+	if e.Relation == "" {
+		return fmt.Sprintf("unknown %s %s", e.Kind, e.Name)
+	}
+	return fmt.Sprintf("unknown %s %s of %s", e.Kind, e.Name, e.Relation)
+}
+
+func (e *UnknownIdentifierError) Unwrap() error {
+	return ErrUnknownIdentifier
+}
+
+// ErrorCode is the code of the api error the error is reported as
+func (e *UnknownIdentifierError) ErrorCode() string {
+	return "UnknownField"
+}
+
+// inputError is an error of the input of queries e.g. from urls, which clients can correct
+type inputError struct {
+	message string
+}
+
+func (e *inputError) Error() string {
+	return e.message
+}
+
+// ErrorCode is the code of the api error the error is reported as
+func (e *inputError) ErrorCode() string {
+	return "ValidationError"
+}
+
+// NewSchema whitelists the relations and columns builders may query, DEFAULT_SCHEMA or
+// the default schema of the dialect of each query when name is empty. builders given a
+// schema validate and quote every identifier, which makes fields taken from urls e.g.
+// sort_<field> safe to use
+//
+//	books := query.NewSchema("").Relation("books", "id", "title", "created_at").Alias("createdAt", "created_at")
+func NewSchema(name string) *schema {
+	if name == "" {
+		name = DEFAULT_SCHEMA
+	}
+	return &schema{name: name, relations: make(map[string]*relation)}
+}
+
+type schema struct {
+	name      string
+	relations map[string]*relation
+}
+
+// nameFor is the name of the schema in queries for d, empty to leave relations unqualified
+func (s *schema) nameFor(d Dialect) string {
+	if s.name != "" {
+		return s.name
+	} else if d, ok := d.(interface{ DefaultSchema() string }); ok {
+		return d.DefaultSchema()
+	}
+	return ""
+}
+
+// Relation allows the columns of the table or view called name, returning it to add aliases
+func (s *schema) Relation(name string, columns ...string) *relation {
+	r, ok := s.relations[name]
+	if !ok {
+		r = &relation{schema: s, name: name, columns: make(map[string]string)}
+		s.relations[name] = r
+	}
+	for i := range columns {
+		r.columns[columns[i]] = columns[i]
+	}
+	return r
+}
+
+func (s *schema) lookup(d Dialect, name string) (*relation, error) {
+	if schemaName, relationName, ok := strings.Cut(name, "."); ok {
+		if schemaName != s.nameFor(d) {
+			return nil, &UnknownIdentifierError{Kind: "relation", Name: name}
+		}
+		name = relationName
+	}
+	if r, ok := s.relations[name]; ok {
+		return r, nil
+	}
+	return nil, &UnknownIdentifierError{Kind: "relation", Name: name}
+}
+
+type relation struct {
+	schema  *schema
+	name    string
+	columns map[string]string
+}
+
+// Alias allows column to be referenced as alias e.g. by the json name of a field
+func (r *relation) Alias(alias, column string) *relation {
+	r.columns[alias] = column
+	return r
+}
+
+// Relation continues with another relation of the schema
+func (r *relation) Relation(name string, columns ...string) *relation {
+	return r.schema.Relation(name, columns...)
+}
+
+// Schema is the schema of the relation, to give to builders
+func (r *relation) Schema() *schema {
+	return r.schema
+}
+
+func (r *relation) quoted(d Dialect) string {
+	if r.schema == nil {
+		return d.Quote(r.name)
+	} else if name := r.schema.nameFor(d); name != "" {
+		return d.Quote(name) + "." + d.Quote(r.name)
+	}
+	return d.Quote(r.name)
+}
+
+// columnName is the column field refers to, by name or alias, optionally qualified by the relation
+func (r *relation) columnName(field string) (string, error) {
+	name := field
+	if qualifier, column, ok := strings.Cut(field, "."); ok && qualifier == r.name {
+		name = column
+	}
+	if column, ok := r.columns[name]; ok {
+		return column, nil
+	}
+	return "", &UnknownIdentifierError{Kind: "field", Name: field, Relation: r.name}
+}
+
+// column quotes the column field refers to, by name or alias, optionally qualified by the relation
+func (r *relation) column(d Dialect, field string) (string, error) {
+	if field == "*" || strings.HasSuffix(field, r.name+".*") {
+		return "*", nil
+	}
+	column, err := r.columnName(field)
+	if err != nil {
+		return "", err
+	}
+	return d.Quote(column), nil
+}
+
+func (r *relation) columnList(d Dialect, fields []string) (out []string, err error) {
+	out = make([]string, len(fields))
+	for i := range fields {
+		if out[i], err = r.column(d, fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// statement holds the identifiers of a select as they are written to the query, checked
+// against and quoted for the schema of the builder if it has one
+type statement struct {
+	rel      *relation
+	relation string
+	joins    []join
+	with     []sqlFragment
+	fields   []string
+	count    []string
+	group    []string
+	sortKeys []sortKey
+	where    *WhereCriteria
+	having   *WhereCriteria
+}
+
+// columns quotes the columns of fields when there is a schema. without one the fields
+// are written as they are and so must be plain identifiers
+func (st statement) columns(d Dialect, fields []string) ([]string, error) {
+	if st.rel == nil {
+		return fields, plainColumns(fields)
+	}
+	return st.rel.columnList(d, fields)
+}
+
+// columnNames maps fields to their columns when there is a schema, without quoting them
+func (st statement) columnNames(fields []string) (out []string, err error) {
+	if st.rel == nil {
+		return fields, plainColumns(fields)
+	}
+	out = make([]string, len(fields))
+	for i := range fields {
+		if out[i], err = st.rel.columnName(fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// plainColumns checks that columns written without a schema are unquoted identifiers
+func plainColumns(columns []string) error {
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column) {
+			return &UnknownIdentifierError{Kind: "field", Name: column}
+		}
+	}
+	return nil
+}
+
+func (st statement) orderBy() []string {
+	out := make([]string, len(st.sortKeys))
+	for i := range st.sortKeys {
+		out[i] = st.sortKeys[i].column + " " + string(st.sortKeys[i].sort)
+	}
+	return out
+}
+
+// resolve copies the criteria with identifiers resolved in s, as they are without it, and
+// subqueries rendered for d
+func (wc *WhereCriteria) resolve(d Dialect, s *scope) (out *WhereCriteria, err error) {
+	if wc == nil {
+		return
+	}
+	out = &WhereCriteria{constraint: wc.constraint, operator: wc.operator, field: wc.field, value: wc.value, null: wc.null, leaf: wc.leaf, order: wc.order}
+	if wc.leaf {
+		switch val := wc.value.(type) {
+		case columnRef:
+			var column string
+			column, err = s.column(string(val))
+			out.value = columnRef(column)
+		case *subquery:
+			var fragment sqlFragment
+			fragment.sql, fragment.args, err = val.render(d, s)
+			out.value = fragment
+		case invalidExpr:
+			err = val.err
+		}
+		switch {
+		case err != nil, wc.operator == CompareExists, wc.operator == CompareNotExists, wc.operator == CompareRaw:
+		case wc.operator == CompareTextSearch:
+			columns := strings.Split(wc.field, ",")
+			for i := range columns {
+				if columns[i], err = s.column(columns[i]); err != nil {
+					return
+				}
+			}
+			out.field = strings.Join(columns, ",")
+		default:
+			out.field, err = s.column(wc.field)
+		}
+		return
+	}
+	out.criteria = make(map[Constraint][]*WhereCriteria, len(wc.criteria))
+	for cs := range wc.criteria {
+		out.criteria[cs] = make([]*WhereCriteria, len(wc.criteria[cs]))
+		for i := range wc.criteria[cs] {
+			if out.criteria[cs][i], err = wc.criteria[cs][i].resolve(d, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return
+}
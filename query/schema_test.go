@@ -0,0 +1,87 @@
+package query_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+var library = query.NewSchema("main").Relation("books", "id", "title", "author", "pages").Alias("writer", "author").Schema()
+
+func TestSchemaSelect(t *testing.T) {
+	ctx := context.Background()
+	count, out, err := query.SQLBuilder(booksDB(t), scanBook).Schema(library).
+		Where(query.Equal("writer", "Achebe"), query.GreaterThan("books.pages", 400)).
+		Order(query.Desc("id")).Group("id").Limit(2).Count("id").
+		Select(ctx, "books", "id", "title", "writer", "pages")
+	require.Nil(t, err)
+	assert.EqualValues(t, 3, count)
+	assert.Equal(t, []int64{10, 8}, ids(out))
+}
+
+func TestSchemaRejectsUnknownIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	for name, sb := range map[string]func() (int64, []book, error){
+		"relation": func() (int64, []book, error) {
+			return query.SQLBuilder(db, scanBook).Schema(library).Select(ctx, "sqlite_master")
+		},
+		"qualified relation": func() (int64, []book, error) {
+			return query.SQLBuilder(db, scanBook).Schema(library).Select(ctx, "temp.books")
+		},
+		"field": func() (int64, []book, error) {
+			return query.SQLBuilder(db, scanBook).Schema(library).Select(ctx, "books", "id", "title", "author", "pages", "1")
+		},
+		"condition": func() (int64, []book, error) {
+			return query.SQLBuilder(db, scanBook).Schema(library).Where(query.Or(query.Equal("id", 1), query.Equal("1=1 or id", 1))).Select(ctx, "books")
+		},
+		"sort": func() (int64, []book, error) {
+			return query.SQLBuilder(db, scanBook).Schema(library).Order(query.Asc("(select 1)")).Select(ctx, "books")
+		},
+		"group": func() (int64, []book, error) {
+			return query.SQLBuilder(db, scanBook).Schema(library).Group("title; drop table books").Select(ctx, "books")
+		},
+	} {
+		_, _, err := sb()
+		var unknown *query.UnknownIdentifierError
+		assert.True(t, errors.As(err, &unknown), name)
+		assert.ErrorIs(t, err, query.ErrUnknownIdentifier, name)
+	}
+}
+
+func TestSchemaQuoting(t *testing.T) {
+	users := query.NewSchema("").Relation("users", "id", "order").Schema()
+	db := &postsDB{}
+	_, _, err := query.SQLBuilder(db, scanPost).Dialect(query.Postgres).Schema(users).
+		Where(query.Equal("order", 1)).Order(query.Asc("id")).Select(context.Background(), "users", "id", "order")
+	require.Nil(t, err)
+	assert.Equal(t, `select "id", "order" from "public"."users" where "order"=$1 order by "id" asc limit 20`, db.query)
+
+	// the default schema follows the dialect
+	for d, from := range map[query.Dialect]string{query.SQLite: `"main"."users"`, query.MySQL: "`users`"} {
+		_, _, err = query.SQLBuilder(db, scanPost).Dialect(d).Schema(users).Select(context.Background(), "users", "id")
+		require.Nil(t, err)
+		assert.Contains(t, db.query, " from "+from+" ", d.Name())
+	}
+}
+
+func TestUrlIdentifiers(t *testing.T) {
+	db := &postsDB{}
+	for name, search := range map[string]query.URLSearchParam{
+		"field": query.WithField(query.SearchUrl(urlParams(nil)), "id = 1 or 1", utils.Value("1")),
+		"sort":  query.WithSort(query.SearchUrl(urlParams(nil)), "id; drop table posts", query.SortAsc),
+	} {
+		_, _, err := query.SQLBuilder(db, scanPost).FromUrlParams(search).Select(context.Background(), "posts")
+		assert.ErrorIs(t, err, query.ErrUnknownIdentifier, name)
+	}
+	search := query.WithSort(query.WithField(query.SearchUrl(urlParams(nil)), "posts.author", utils.Value("jane")), "created_at", query.SortDesc)
+	_, _, err := query.SQLBuilder(db, scanPost).Where(query.UrlFieldParams(search)).Order(query.UrlFieldSort(search)).Select(context.Background(), "posts")
+	require.Nil(t, err)
+	assert.Equal(t, "select * from posts where posts.author=$1 order by created_at desc limit 20", db.query)
+}
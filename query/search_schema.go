@@ -0,0 +1,104 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iancoleman/strcase"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// SearchSchemaOf declares the url search of T from the search tags of its fields, listing
+// how a field may be searched: lookup, compare, sort and text for full text search.
+// fields are named in urls as in json and compared by their db column, or their snake
+// cased name, with values parsed to the type of the field
+//
+//	type Book struct {
+//		Title     string    `json:"title" search:"lookup,compare,sort,text"`
+//		CreatedAt time.Time `json:"createdAt" db:"created_at" search:"compare,sort"`
+//	}
+//
+//	var bookSearch = query.SearchSchemaOf[Book]()
+func SearchSchemaOf[T any]() (out UrlFields) {
+	out.Types, out.Columns = make(map[string]FieldType), make(map[string]string)
+	searchFieldsOf(reflect.TypeOf((*T)(nil)).Elem(), &out)
+	return
+}
+
+func searchFieldsOf(t reflect.Type, out *UrlFields) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("search")
+		if field.Anonymous && !ok {
+			searchFieldsOf(field.Type, out)
+			continue
+		} else if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		column, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+		if column == "" || column == "-" {
+			column = strcase.ToSnake(field.Name)
+		}
+		if column != name {
+			out.Columns[name] = column
+		}
+		out.Types[name] = fieldTypeOf(field.Type)
+		for _, opt := range strings.Split(tag, ",") {
+			switch strings.TrimSpace(opt) {
+			case "lookup":
+				out.Lookup = append(out.Lookup, name)
+			case "compare":
+				out.Comparable = append(out.Comparable, name)
+			case "sort":
+				out.Sort = append(out.Sort, name)
+			case "text":
+				out.Text = append(out.Text, column)
+			}
+		}
+	}
+}
+
+// fieldTypeOf is the FieldType of values of t, looking through pointers, optional.* and sql.Null*
+func fieldTypeOf(t reflect.Type) FieldType {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return FieldTime
+	case t == uuidType:
+		return FieldUUID
+	case t.Kind() == reflect.Struct:
+		if get, ok := t.MethodByName("Get"); ok && get.Type.NumOut() == 2 {
+			return fieldTypeOf(get.Type.Out(0))
+		} else if _, ok := t.FieldByName("Valid"); ok && t.NumField() == 2 {
+			return fieldTypeOf(t.Field(0).Type)
+		}
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return FieldInt
+	case reflect.Float32, reflect.Float64:
+		return FieldFloat
+	case reflect.Bool:
+		return FieldBool
+	}
+	return FieldString
+}
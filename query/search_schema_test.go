@@ -0,0 +1,56 @@
+package query_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markphelps/optional"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+)
+
+type audited struct {
+	CreatedAt time.Time `json:"createdAt" db:"created_at" search:"compare,sort"`
+}
+
+type listedBook struct {
+	ID        int64          `json:"id" search:"lookup,sort"`
+	Title     string         `json:"title" search:"compare,text"`
+	Author    string         `json:"writer" db:"author" search:"lookup,compare,text"`
+	PageCount optional.Int64 `json:"pageCount" db:"pages" search:"compare,sort"`
+	Owner     uuid.UUID      `json:"owner" search:"lookup"`
+	Isbn      sql.NullString `json:"isbn"`
+	audited
+}
+
+func TestSearchSchemaOf(t *testing.T) {
+	fields := query.SearchSchemaOf[listedBook]()
+	assert.Equal(t, []string{"title", "writer", "pageCount", "createdAt"}, []string(fields.Comparable))
+	assert.Equal(t, []string{"id", "writer", "owner"}, []string(fields.Lookup))
+	assert.Equal(t, []string{"id", "pageCount", "createdAt"}, []string(fields.Sort))
+	assert.Equal(t, []string{"title", "author"}, []string(fields.Text))
+	assert.Equal(t, map[string]string{"writer": "author", "pageCount": "pages", "createdAt": "created_at"}, fields.Columns)
+	assert.Equal(t, map[string]query.FieldType{
+		"id": query.FieldInt, "title": query.FieldString, "writer": query.FieldString, "pageCount": query.FieldInt,
+		"owner": query.FieldUUID, "createdAt": query.FieldTime,
+	}, fields.Types)
+}
+
+func TestSearchSchemaSelect(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	fields := query.SearchSchemaOf[listedBook]()
+	search := fields.SearchParams(ctx, urlParams(map[string]string{"writer": "Ngugi", "pageCount_gte": "500", "sort_pageCount": "desc"}))
+	_, out, err := query.SQLBuilder(db, scanBook).FromUrlParams(search).Select(ctx, "books")
+	require.Nil(t, err)
+	assert.Equal(t, []int64{9, 7, 5}, ids(out))
+
+	search = fields.SearchParams(ctx, urlParams(map[string]string{"pageCount_gt": "five hundred"}))
+	_, _, err = query.SQLBuilder(db, scanBook).FromUrlParams(search).Select(ctx, "books")
+	assert.ErrorIs(t, err, query.ErrInvalidSearch)
+}
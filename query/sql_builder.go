@@ -18,13 +18,15 @@ var (
 	SELECT_FIELDS       = env.Get("SELECT_FIELDS", "*").StringList()
 	SELECT_LIKE         = env.Get("SELECT_LIKE", "ilike").String()
 	SELECT_LIMIT        = env.Get("SELECT_LIMIT", "20").Int64()
-	DEFAULT_SCHEMA      = env.Get("DEFAULT_SCHEMA", "public").String()
+	// DEFAULT_SCHEMA is the schema of NewSchema(""), else that of the dialect of the query
+	// e.g. public on postgres, main on sqlite and the current database on mysql
+	DEFAULT_SCHEMA = env.Get("DEFAULT_SCHEMA", "").String()
 )
 
 type RowScanFunc[T any] func(pgx.Rows) (T, error)
 
 func SQLBuilder[T any](dbtx sqlc.DBTX, rowScanner RowScanFunc[T]) *sqlBuilder[T] {
-	return &sqlBuilder[T]{
+	sb := &sqlBuilder[T]{
 		selectFields: SELECT_FIELDS,
 		countFields:  SELECT_COUNT_FIELDS,
 		orderBy:      []string{},
@@ -34,6 +36,10 @@ func SQLBuilder[T any](dbtx sqlc.DBTX, rowScanner RowScanFunc[T]) *sqlBuilder[T]
 		rowScanner:   rowScanner,
 		dbtx:         dbtx,
 	}
+	if sb.dialect, sb.err = dialectOf(dbtx); sb.err != nil {
+		sb.dialect = DefaultDialect
+	}
+	return sb
 }
 
 type sqlBuilder[T any] struct {
@@ -42,11 +48,52 @@ type sqlBuilder[T any] struct {
 	groupBy      []string
 	orderBy      []string
 	limit        int64
+	limited      bool
 	offset       int64
 	count        bool
 	rowScanner   RowScanFunc[T]
 	where        *WhereCriteria
 	dbtx         sqlc.DBTX
+	dialect      Dialect
+	schema       *schema
+
+	// composition. see Join, Having and With
+	joins  []join
+	having *WhereCriteria
+	ctes   []cte
+
+	// writes. see Insert, Update and Delete
+	returning      []string
+	conflict       []string
+	conflictUpdate []string
+
+	// keyset pagination. see Keyset and Cursor
+	sortKeys     []sortKey
+	keyset       bool
+	keysetValues func(T) []any
+	cursor       *Cursor
+	nextCursor   string
+	prevCursor   string
+	err          error
+}
+
+// Dialect writes queries for d instead of the dialect of the driver of the connection.
+// builders on drivers of unknown dialects fail until it is set
+func (sb *sqlBuilder[T]) Dialect(d Dialect) *sqlBuilder[T] {
+	if d != nil {
+		sb.dialect = d
+		if errors.Is(sb.err, ErrUnknownDialect) {
+			sb.err = nil
+		}
+	}
+	return sb
+}
+
+// Schema restricts queries to the relations and columns of s, quoting every identifier.
+// queries referencing others fail with an UnknownIdentifierError
+func (sb *sqlBuilder[T]) Schema(s *schema) *sqlBuilder[T] {
+	sb.schema = s
+	return sb
 }
 
 func (sb *sqlBuilder[T]) Count(fields ...string) *sqlBuilder[T] {
@@ -59,7 +106,7 @@ func (sb *sqlBuilder[T]) Count(fields ...string) *sqlBuilder[T] {
 
 func (sb *sqlBuilder[T]) Limit(limit int64) *sqlBuilder[T] {
 	if limit > 0 {
-		sb.limit = limit
+		sb.limit, sb.limited = limit, true
 	}
 	return sb
 }
@@ -92,7 +139,46 @@ func (sb *sqlBuilder[T]) Group(groups ...string) *sqlBuilder[T] {
 }
 
 func (sb *sqlBuilder[T]) FromUrlParams(p URLSearchParam) *sqlBuilder[T] {
-	return sb.Where(UrlFieldParams(p)).Order(UrlFieldSort(p)).Limit(p.GetLimit()).Offset(p.GetOffset()).Count()
+	sb.Where(UrlFieldParams(p)).Order(UrlFieldSort(p)).Limit(p.GetLimit()).Offset(p.GetOffset()).Count()
+	if cp, ok := p.(URLCursorParam); ok && cp.GetCursor() != "" {
+		sb.Cursor(cp.GetCursor())
+	}
+	return sb
+}
+
+// Keyset pages through rows by the values of their sort keys instead of an offset, making
+// Cursors available after Select. the keys of a row are read by values when given, else
+// from the fields of T tagged db or json, or named, after the ordered columns. the order
+// should end with a unique column e.g. Desc("created_at", "id") and sort keys must not be
+// null, Select fails rather than skip rows when a page ends on a null key
+func (sb *sqlBuilder[T]) Keyset(values ...func(T) []any) *sqlBuilder[T] {
+	sb.keyset = true
+	if len(values) > 0 {
+		sb.keysetValues = values[0]
+	}
+	return sb
+}
+
+// Cursor continues keyset pagination from a cursor returned by Cursors. it must have
+// been created for the same order, else Select fails with ErrInvalidCursor
+func (sb *sqlBuilder[T]) Cursor(cursor string) *sqlBuilder[T] {
+	sb.keyset = true
+	if cursor == "" {
+		return sb
+	}
+	c, err := DecodeCursor(cursor)
+	if err != nil {
+		sb.err = err
+		return sb
+	}
+	sb.cursor = &c
+	return sb
+}
+
+// Cursors are the cursors of the pages after and before the rows of the last keyset Select,
+// empty when there are none
+func (sb *sqlBuilder[T]) Cursors() (next, prev string) {
+	return sb.nextCursor, sb.prevCursor
 }
 
 func (sb *sqlBuilder[T]) Where(conditions ...Condition) *sqlBuilder[T] {
@@ -106,23 +192,30 @@ func (sb *sqlBuilder[T]) Where(conditions ...Condition) *sqlBuilder[T] {
 	return sb
 }
 
+// Criteria writes the conditions of the builder for its dialect. nothing is written once
+// the builder has failed e.g. on invalid url fields or conditions that do not render,
+// which is then reported by Err
 func (sb *sqlBuilder[T]) Criteria() (b strings.Builder, args []interface{}) {
-	if sb.where != nil {
-		return sb.where.Build(true)
+	if sb.err != nil || sb.where == nil {
+		return
+	}
+	var err error
+	if b, args, err = sb.where.build(sb.dialect, true); err != nil {
+		sb.err = err
+		return strings.Builder{}, nil
 	}
 	return
 }
 
+// Err is the first error of the builder, failing its queries
+func (sb *sqlBuilder[T]) Err() error {
+	return sb.err
+}
+
 func (sb *sqlBuilder[T]) Select(ctx context.Context, relation string, fields ...string) (count int64, out []T, err error) {
 	var rows pgx.Rows
-	if sb.rowScanner == nil {
-		return 0, nil, errors.New("rowScanner func was undefined")
-	}
-	if len(fields) > 0 {
-		sb.selectFields = fields
-	}
-	where, args := sb.Criteria()
-	if rows, err = sb.dbtx.Query(ctx, sb.selectQueryString(relation, sb.selectFields, where), args...); err != nil {
+	var st statement
+	if rows, st, err = sb.query(ctx, relation, fields, sb.keyset); err != nil {
 		return
 	}
 	defer rows.Close()
@@ -137,36 +230,241 @@ func (sb *sqlBuilder[T]) Select(ctx context.Context, relation string, fields ...
 	if err = rows.Err(); err != nil {
 		return
 	}
+	if sb.keyset {
+		if out, err = sb.keysetPage(out); err != nil {
+			return
+		}
+	}
 	if !sb.count {
 		return int64(len(out)), out, nil
 	}
-	countQuery := fmt.Sprintf("select count(%s) from %s", strings.Join(sb.countFields, ", "), relation)
-	if where.Len() > 0 {
-		countQuery += " where " + where.String()
+	count, err = sb.countRows(ctx, st)
+	return
+}
+
+// SelectStream is Select yielding rows as they are read instead of loading them all.
+// the stream must be closed, which cancels the query when rows are left unread
+func (sb *sqlBuilder[T]) SelectStream(ctx context.Context, relation string, fields ...string) (out RowStream[T], err error) {
+	if sb.cursor != nil && sb.cursor.Backward {
+		return nil, errors.New("cannot stream backwards from a cursor")
+	}
+	stream := &rowStream[T]{scan: sb.rowScanner}
+	stream.ctx, stream.cancel = context.WithCancel(ctx)
+	var st statement
+	if stream.rows, st, err = sb.query(stream.ctx, relation, fields, false); err != nil {
+		stream.cancel()
+		return nil, err
+	}
+	if sb.count {
+		stream.total = func(ctx context.Context) (int64, error) {
+			return sb.countRows(ctx, st)
+		}
+	}
+	return stream, nil
+}
+
+// statement checks the identifiers of a select against the schema of the builder and
+// quotes them, or takes them as they are without a schema. subqueries resolve the
+// identifiers they do not know in the scope of the query they are nested in
+func (sb *sqlBuilder[T]) statement(d Dialect, from string, fields []string, outer *scope) (st statement, err error) {
+	if sb.err != nil {
+		return st, sb.err
 	}
-	err = sb.dbtx.QueryRow(ctx, countQuery, args...).Scan(&count)
+	st = statement{relation: from, fields: fields, count: sb.countFields, group: sb.groupBy, sortKeys: sb.sortKeys, joins: append([]join(nil), sb.joins...)}
+	ctes := make(map[string]*relation, len(sb.ctes))
+	for _, c := range sb.ctes {
+		query, args, e := c.sub.render(d, nil)
+		if e != nil {
+			return st, fmt.Errorf("with %s: %w", c.name, e)
+		}
+		name := c.name
+		if sb.schema != nil {
+			if !identifierPattern.MatchString(name) {
+				return st, &UnknownIdentifierError{Kind: "relation", Name: name}
+			}
+			rel := &relation{name: name, columns: make(map[string]string)}
+			for _, column := range c.sub.outputs() {
+				rel.columns[column] = column
+			}
+			ctes[name], name = rel, rel.quoted(d)
+		}
+		st.with = append(st.with, sqlFragment{sql: name + " as (" + query + ")", args: args})
+	}
+	var s *scope
+	if sb.schema != nil {
+		s = &scope{d: d, outer: outer}
+		add := func(from string) (string, error) {
+			name, alias := splitRelation(from)
+			rel, ok := ctes[name]
+			if !ok {
+				if rel, err = sb.schema.lookup(d, name); err != nil {
+					return "", err
+				}
+			}
+			if st.rel == nil {
+				st.rel = rel
+			}
+			return s.add(rel, alias)
+		}
+		if st.relation, err = add(from); err != nil {
+			return
+		}
+		for i := range st.joins {
+			if st.joins[i].relation, err = add(st.joins[i].relation); err != nil {
+				return
+			}
+		}
+		if st.fields, err = s.columns(fields); err != nil {
+			return
+		} else if st.count, err = s.columns(sb.countFields); err != nil {
+			return
+		} else if st.group, err = s.columns(sb.groupBy); err != nil {
+			return
+		}
+		st.sortKeys = make([]sortKey, len(sb.sortKeys))
+		for i, key := range sb.sortKeys {
+			if key.column, err = s.column(key.field); err != nil {
+				return
+			}
+			st.sortKeys[i] = key
+		}
+	}
+	for i := range st.joins {
+		if st.joins[i].on, err = st.joins[i].on.resolve(d, s); err != nil {
+			return
+		}
+	}
+	if st.where, err = sb.where.resolve(d, s); err != nil {
+		return
+	}
+	st.having, err = sb.having.resolve(d, s)
+	return
+}
+
+// query runs the select, returning the statement rows are counted by. probe fetches a row
+// beyond the limit of keyset pages to find out whether there are more
+func (sb *sqlBuilder[T]) query(ctx context.Context, relation string, fields []string, probe bool) (rows pgx.Rows, st statement, err error) {
+	if sb.rowScanner == nil {
+		err = errors.New("rowScanner func was undefined")
+		return
+	}
+	if len(fields) > 0 {
+		sb.selectFields = fields
+	}
+	if st, err = sb.statement(sb.dialect, relation, sb.selectFields, nil); err != nil {
+		return
+	}
+	var predicate sqlFragment
+	orderBy, limit, offset := st.orderBy(), sb.limit, sb.offset
+	if sb.keyset {
+		if len(st.sortKeys) == 0 {
+			err = errors.New("keyset pagination requires an order")
+			return
+		}
+		offset = 0
+		if probe && limit > 0 {
+			limit++
+		}
+		if sb.cursor != nil {
+			if predicate, err = sb.cursorPredicate(st.sortKeys); err != nil {
+				return
+			}
+			if sb.cursor.Backward {
+				for i := range orderBy {
+					if st.sortKeys[i].sort == SortDesc {
+						orderBy[i] = st.sortKeys[i].column + " " + string(SortAsc)
+					} else {
+						orderBy[i] = st.sortKeys[i].column + " " + string(SortDesc)
+					}
+				}
+			}
+		}
+	}
+	var w sqlWriter
+	st.writeSelect(&w, sb.dialect, predicate, orderBy, limit, offset)
+	rows, err = sb.dbtx.Query(ctx, w.bound(sb.dialect), w.args...)
+	return
+}
+
+func (sb *sqlBuilder[T]) cursorPredicate(keys []sortKey) (out sqlFragment, err error) {
+	if len(sb.cursor.Keys) != len(keys) {
+		return out, fmt.Errorf("%w: cursor does not match the order", ErrInvalidCursor)
+	}
+	for i := range keys {
+		if sb.cursor.Keys[i] != keys[i].String() {
+			return out, fmt.Errorf("%w: cursor does not match the order", ErrInvalidCursor)
+		}
+	}
+	for i := range keys {
+		if nullValue(sb.cursor.Values[i]) {
+			return out, fmt.Errorf("%w: null value of %s", ErrInvalidCursor, keys[i].field)
+		}
+	}
+	out.sql, out.args = keysetPredicate(keys, sb.cursor.Values, sb.cursor.Backward)
 	return
 }
 
-func (sb *sqlBuilder[T]) selectQueryString(relation string, fields []string, where strings.Builder) string {
-	var query strings.Builder
-	query.WriteString(fmt.Sprintf("select %s from %s", strings.Join(fields, ", "), relation))
-	if where.Len() > 0 {
-		query.WriteString(" where " + where.String())
+// keysetPage trims the row probed beyond the limit, restores the order of rows read
+// backwards and sets the cursors of the pages around them
+func (sb *sqlBuilder[T]) keysetPage(rows []T) (out []T, err error) {
+	sb.nextCursor, sb.prevCursor = "", ""
+	backward := sb.cursor != nil && sb.cursor.Backward
+	more := sb.limit > 0 && int64(len(rows)) > sb.limit
+	if out = rows; more {
+		out = rows[:sb.limit]
 	}
-	if len(sb.groupBy) > 0 {
-		query.WriteString(" group by " + strings.Join(sb.groupBy, ", "))
+	if backward {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
 	}
-	if len(sb.orderBy) > 0 {
-		query.WriteString(" order by " + strings.Join(sb.orderBy, ", "))
+	if len(out) == 0 {
+		return
+	}
+	if more || backward {
+		if sb.nextCursor, err = sb.cursorOf(out[len(out)-1], false); err != nil {
+			return
+		}
 	}
-	if sb.limit > 0 {
-		query.WriteString(" limit " + fmt.Sprint(sb.limit))
+	if (more && backward) || (sb.cursor != nil && !backward) {
+		sb.prevCursor, err = sb.cursorOf(out[0], true)
 	}
-	if sb.offset > 0 {
-		query.WriteString(" offset " + fmt.Sprint(sb.offset))
+	return
+}
+
+func (sb *sqlBuilder[T]) cursorOf(row T, backward bool) (string, error) {
+	c := Cursor{Keys: make([]string, len(sb.sortKeys)), Backward: backward}
+	for i := range sb.sortKeys {
+		c.Keys[i] = sb.sortKeys[i].String()
+	}
+	var err error
+	if sb.keysetValues != nil {
+		c.Values = sb.keysetValues(row)
+	} else if c.Values, err = keysetValuesOf(row, sb.sortKeys); err != nil {
+		return "", err
+	}
+	if len(c.Values) != len(c.Keys) {
+		return "", fmt.Errorf("keyset values of %d sort keys, expected %d", len(c.Values), len(c.Keys))
+	}
+	for i := range c.Values {
+		if nullValue(c.Values[i]) {
+			return "", fmt.Errorf("cannot page after a null %s, keyset pagination needs sort keys that are not null", sb.sortKeys[i].field)
+		}
+	}
+	return c.Encode()
+}
+
+func (sb *sqlBuilder[T]) countRows(ctx context.Context, st statement) (count int64, err error) {
+	var w sqlWriter
+	st.writeCount(&w, sb.dialect)
+	err = sb.dbtx.QueryRow(ctx, w.bound(sb.dialect), w.args...).Scan(&count)
+	return
+}
+
+func (sb *sqlBuilder[T]) sortFailed(err error) {
+	if sb.err == nil {
+		sb.err = err
 	}
-	return query.String()
 }
 
 func (sb *sqlBuilder[T]) addFieldSort(sort SortType, fields ...string) {
@@ -175,6 +473,7 @@ func (sb *sqlBuilder[T]) addFieldSort(sort SortType, fields ...string) {
 	}
 	for i := range fields {
 		sb.orderBy = append(sb.orderBy, fields[i]+" "+string(sort))
+		sb.sortKeys = append(sb.sortKeys, sortKey{field: fields[i], sort: sort, column: fields[i]})
 	}
 }
 
@@ -0,0 +1,356 @@
+package query
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// fieldPattern matches columns optionally qualified by their relation e.g. books.title
+	fieldPattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	aggregatePattern = regexp.MustCompile(`^(?i)(count|sum|avg|min|max)\((distinct )?([^()]+)\)(?: as ([A-Za-z_][A-Za-z0-9_]*))?$`)
+	aliasPattern     = regexp.MustCompile(`^([^ ]+) (?i:as) ([A-Za-z_][A-Za-z0-9_]*)$`)
+	jsonPathPattern  = regexp.MustCompile(`^(->>?('([^']|'')*'|[0-9]+))+$`)
+	// dollarTagPattern matches the opening tag of postgres dollar quoted strings e.g. $body$
+	dollarTagPattern = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// Count, Sum, Avg, Min and Max select aggregates of field, named alias when given
+// e.g. Select(ctx, "books", "author_id", Count("id", "books"))
+func Count(field string, alias ...string) string { return aggregate("count", field, alias) }
+func Sum(field string, alias ...string) string   { return aggregate("sum", field, alias) }
+func Avg(field string, alias ...string) string   { return aggregate("avg", field, alias) }
+func Min(field string, alias ...string) string   { return aggregate("min", field, alias) }
+func Max(field string, alias ...string) string   { return aggregate("max", field, alias) }
+
+func aggregate(fn, field string, alias []string) string {
+	if len(alias) > 0 && alias[0] != "" {
+		return fn + "(" + field + ") as " + alias[0]
+	}
+	return fn + "(" + field + ")"
+}
+
+// columnRef is a column compared to in place of a value
+type columnRef string
+
+// Column compares to another column instead of a value e.g. in the conditions of joins
+// and correlated subqueries: Equal("books.author_id", Column("authors.id"))
+func Column(name string) columnRef {
+	return columnRef(name)
+}
+
+// sqlFragment is written sql with ARG_PLACEHOLDER for its args
+type sqlFragment struct {
+	sql  string
+	args []interface{}
+}
+
+// subquery is a select nested in another query, see Subquery
+type subquery struct {
+	outputs func() []string
+	render  func(d Dialect, outer *scope) (string, []interface{}, error)
+}
+
+// Subquery selects the fields of relation as sb would, for conditions e.g. InQuery and
+// Exists or With. it is limited only when sb is given a Limit
+func Subquery[T any](sb *sqlBuilder[T], relation string, fields ...string) *subquery {
+	if len(fields) == 0 {
+		fields = sb.selectFields
+	}
+	return &subquery{
+		render: func(d Dialect, outer *scope) (string, []interface{}, error) {
+			st, err := sb.statement(d, relation, fields, outer)
+			if err != nil {
+				return "", nil, err
+			}
+			var w sqlWriter
+			limit := int64(0)
+			if sb.limited {
+				limit = sb.limit
+			}
+			st.writeSelect(&w, d, sqlFragment{}, st.orderBy(), limit, sb.offset)
+			return w.String(), w.args, nil
+		},
+		outputs: func() (out []string) {
+			for _, field := range fields {
+				if m := aggregatePattern.FindStringSubmatch(field); m != nil && m[4] != "" {
+					out = append(out, m[4])
+				} else if m != nil {
+					out = append(out, strings.ToLower(m[1]))
+				} else if m := aliasPattern.FindStringSubmatch(field); m != nil {
+					out = append(out, m[2])
+				} else if field == "*" && sb.schema != nil {
+					if rel, err := sb.schema.lookup(sb.dialect, relationName(relation)); err == nil {
+						for column := range rel.columns {
+							out = append(out, column)
+						}
+					}
+				} else {
+					out = append(out, field[strings.LastIndex(field, ".")+1:])
+				}
+			}
+			return
+		},
+	}
+}
+
+type join struct {
+	kind     string
+	relation string
+	on       *WhereCriteria
+}
+
+// Join selects from relation, optionally aliased e.g. "authors a", with the rows matching on
+func (sb *sqlBuilder[T]) Join(relation string, on ...Condition) *sqlBuilder[T] {
+	return sb.join("join", relation, on)
+}
+
+// LeftJoin is Join keeping the rows without a match in relation
+func (sb *sqlBuilder[T]) LeftJoin(relation string, on ...Condition) *sqlBuilder[T] {
+	return sb.join("left join", relation, on)
+}
+
+func (sb *sqlBuilder[T]) join(kind, relation string, on []Condition) *sqlBuilder[T] {
+	sb.joins = append(sb.joins, join{kind: kind, relation: relation, on: conditionsOf(on)})
+	return sb
+}
+
+// Having filters the groups of Group e.g. Having(GreaterThan(Count("id"), 3))
+func (sb *sqlBuilder[T]) Having(conditions ...Condition) *sqlBuilder[T] {
+	if len(conditions) == 0 {
+		return sb
+	} else if sb.having == nil {
+		sb.having = &WhereCriteria{constraint: WhereAnd}
+	}
+	for i := range conditions {
+		conditions[i](sb.having)
+	}
+	return sb
+}
+
+type cte struct {
+	name string
+	sub  *subquery
+}
+
+// With names a subquery the query can select from and join like a relation
+func (sb *sqlBuilder[T]) With(name string, sub *subquery) *sqlBuilder[T] {
+	sb.ctes = append(sb.ctes, cte{name: name, sub: sub})
+	return sb
+}
+
+func conditionsOf(conditions []Condition) *WhereCriteria {
+	if len(conditions) == 0 {
+		return nil
+	}
+	out := &WhereCriteria{constraint: WhereAnd}
+	for i := range conditions {
+		conditions[i](out)
+	}
+	return out
+}
+
+// relationName and splitRelation read relations written with an alias e.g. "books b"
+func relationName(relation string) string {
+	name, _ := splitRelation(relation)
+	return name
+}
+
+func splitRelation(relation string) (name, alias string) {
+	parts := strings.Fields(relation)
+	switch {
+	case len(parts) == 3 && strings.EqualFold(parts[1], "as"):
+		return parts[0], parts[2]
+	case len(parts) == 2:
+		return parts[0], parts[1]
+	}
+	return relation, ""
+}
+
+// scope resolves the identifiers of a query against the relations it selects from,
+// qualifying columns by the name or alias of their relation when there are several.
+// subqueries fall back to the scope of the query they are nested in
+type scope struct {
+	d         Dialect
+	relations []scoped
+	outer     *scope
+}
+
+type scoped struct {
+	rel  *relation
+	name string
+}
+
+func (s *scope) add(rel *relation, alias string) (string, error) {
+	if alias == "" {
+		s.relations = append(s.relations, scoped{rel: rel, name: rel.name})
+		return rel.quoted(s.d), nil
+	} else if !identifierPattern.MatchString(alias) {
+		return "", &UnknownIdentifierError{Kind: "alias", Name: alias, Relation: rel.name}
+	}
+	s.relations = append(s.relations, scoped{rel: rel, name: alias})
+	return rel.quoted(s.d) + " " + s.d.Quote(alias), nil
+}
+
+// column resolves field, a column, aggregate or either aliased with as. nil scopes
+// take fields as they are
+func (s *scope) column(field string) (string, error) {
+	if s == nil {
+		return field, nil
+	} else if m := aggregatePattern.FindStringSubmatch(field); m != nil {
+		inner := m[3]
+		if inner != "*" {
+			var err error
+			if inner, err = s.column(inner); err != nil {
+				return "", err
+			}
+		}
+		out := strings.ToLower(m[1]) + "(" + strings.ToLower(m[2]) + inner + ")"
+		if m[4] != "" {
+			out += " as " + s.d.Quote(m[4])
+		}
+		return out, nil
+	} else if m := aliasPattern.FindStringSubmatch(field); m != nil {
+		column, err := s.column(m[1])
+		return column + " as " + s.d.Quote(m[2]), err
+	} else if field == "*" {
+		return field, nil
+	} else if i := strings.Index(field, "->"); i > 0 && jsonPathPattern.MatchString(field[i:]) {
+		column, err := s.column(field[:i])
+		return column + field[i:], err
+	}
+	return s.lookup(field, len(s.relations) > 1)
+}
+
+func (s *scope) lookup(field string, qualify bool) (string, error) {
+	name := field
+	qualifier, column, qualified := strings.Cut(field, ".")
+	if qualified {
+		name = column
+	}
+	for _, rs := range s.relations {
+		if qualified && rs.name != qualifier {
+			continue
+		} else if name == "*" && qualify {
+			return s.d.Quote(rs.name) + ".*", nil
+		} else if name == "*" {
+			return name, nil
+		} else if column, ok := rs.rel.columns[name]; ok && qualify {
+			return s.d.Quote(rs.name) + "." + s.d.Quote(column), nil
+		} else if ok {
+			return s.d.Quote(column), nil
+		}
+	}
+	if s.outer != nil {
+		return s.outer.lookup(field, true)
+	}
+	return "", &UnknownIdentifierError{Kind: "field", Name: field, Relation: s.relations[0].rel.name}
+}
+
+func (s *scope) columns(fields []string) (out []string, err error) {
+	out = make([]string, len(fields))
+	for i := range fields {
+		if out[i], err = s.column(fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// sqlWriter accumulates a query written with ARG_PLACEHOLDER and its args in the order
+// they appear so that placeholders are numbered once the whole query is written
+type sqlWriter struct {
+	strings.Builder
+	args []interface{}
+}
+
+func (w *sqlWriter) write(sql string, args ...interface{}) {
+	w.WriteString(sql)
+	w.args = append(w.args, args...)
+}
+
+// criteria writes prefix and the criteria and extra predicate joined by and, false when
+// there are neither
+func (w *sqlWriter) criteria(prefix string, d Dialect, wc *WhereCriteria, extra sqlFragment) bool {
+	var sql string
+	var args []interface{}
+	if wc != nil {
+		// resolved criteria have their subqueries rendered already, so they build without errors
+		criteria, criteriaArgs, _ := wc.build(d, false)
+		sql, args = criteria.String(), criteriaArgs
+	}
+	if extra.sql != "" && sql != "" {
+		sql, args = "("+sql+") and "+extra.sql, append(args, extra.args...)
+	} else if extra.sql != "" {
+		sql, args = extra.sql, extra.args
+	}
+	if sql == "" {
+		return false
+	}
+	w.write(prefix+sql, args...)
+	return true
+}
+
+// bound is the query with its placeholders numbered for d
+func (w *sqlWriter) bound(d Dialect) string {
+	return bindPlaceholders(d, w.String(), 0)
+}
+
+func (st statement) writeWith(w *sqlWriter) {
+	for i := range st.with {
+		if i == 0 {
+			w.write("with ")
+		} else {
+			w.write(", ")
+		}
+		w.write(st.with[i].sql, st.with[i].args...)
+	}
+	if len(st.with) > 0 {
+		w.write(" ")
+	}
+}
+
+// writeFrom writes the relations and conditions of the statement, with the keyset predicate if any
+func (st statement) writeFrom(w *sqlWriter, d Dialect, predicate sqlFragment) {
+	w.write(" from " + st.relation)
+	for _, j := range st.joins {
+		w.write(" " + j.kind + " " + j.relation)
+		w.criteria(" on ", d, j.on, sqlFragment{})
+	}
+	w.criteria(" where ", d, st.where, predicate)
+}
+
+func (st statement) writeGroups(w *sqlWriter, d Dialect) {
+	if len(st.group) > 0 {
+		w.write(" group by " + strings.Join(st.group, ", "))
+	}
+	w.criteria(" having ", d, st.having, sqlFragment{})
+}
+
+func (st statement) writeSelect(w *sqlWriter, d Dialect, predicate sqlFragment, orderBy []string, limit, offset int64) {
+	st.writeWith(w)
+	w.write("select " + strings.Join(st.fields, ", "))
+	st.writeFrom(w, d, predicate)
+	st.writeGroups(w, d)
+	if len(orderBy) > 0 {
+		w.write(" order by " + strings.Join(orderBy, ", "))
+	}
+	if paging := d.LimitOffset(limit, offset); paging != "" {
+		w.write(" " + paging)
+	}
+}
+
+// writeCount counts the rows of the statement, or its groups when grouped
+func (st statement) writeCount(w *sqlWriter, d Dialect) {
+	st.writeWith(w)
+	if len(st.group) == 0 && st.having == nil {
+		w.write("select count(" + strings.Join(st.count, ", ") + ")")
+		st.writeFrom(w, d, sqlFragment{})
+		return
+	}
+	w.write("select count(*) from (select 1")
+	st.writeFrom(w, d, sqlFragment{})
+	st.writeGroups(w, d)
+	w.write(") as grouped")
+}
@@ -0,0 +1,140 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/sqlc"
+)
+
+var shelf = query.NewSchema("main").
+	Relation("books", "id", "title", "author", "pages").
+	Relation("authors", "id", "name", "country").Schema()
+
+type tally struct {
+	Name  string
+	Books int64
+}
+
+func scanTally(rows pgx.Rows) (out tally, err error) {
+	err = rows.Scan(&out.Name, &out.Books)
+	return
+}
+
+// shelfDB is booksDB with the authors of the books and one without any
+func shelfDB(t *testing.T) sqlc.DBTX {
+	db := booksDB(t)
+	_, err := db.Exec(context.Background(), "create table authors (id integer primary key, name text not null, country text not null)")
+	require.Nil(t, err)
+	_, err = db.Exec(context.Background(), "insert into authors (id, name, country) values (1, 'Achebe', 'Nigeria'), (2, 'Ngugi', 'Kenya'), (3, 'Adichie', 'Nigeria')")
+	require.Nil(t, err)
+	return db
+}
+
+func TestJoin(t *testing.T) {
+	ctx := context.Background()
+	db := shelfDB(t)
+	count, out, err := query.SQLBuilder(db, scanTally).Schema(shelf).
+		LeftJoin("books b", query.Equal("b.author", query.Column("a.name")), query.GreaterThan("b.pages", 200)).
+		Where(query.Equal("a.country", "Nigeria")).Group("a.name").Having(query.LessThan(query.Count("b.id"), 5)).
+		Order(query.Asc("a.name")).Count().
+		Select(ctx, "authors a", "a.name", query.Count("b.id", "books"))
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, count)
+	assert.Equal(t, []tally{{"Achebe", 4}, {"Adichie", 0}}, out)
+
+	count, _, err = query.SQLBuilder(db, scanBook).Schema(shelf).
+		Join("authors", query.Equal("books.author", query.Column("authors.name"))).Where(query.Equal("country", "Kenya")).Count().
+		Select(ctx, "books", "books.id", "title", "author", "pages")
+	require.Nil(t, err)
+	assert.EqualValues(t, 5, count)
+
+	_, _, err = query.SQLBuilder(db, scanBook).Schema(shelf).
+		Join("authors a", query.Equal("b.author", query.Column("a.nationality"))).Select(ctx, "books b")
+	assert.ErrorIs(t, err, query.ErrUnknownIdentifier)
+}
+
+func TestSubqueries(t *testing.T) {
+	ctx := context.Background()
+	db := shelfDB(t)
+	kenyan := query.Subquery(query.SQLBuilder[any](db, nil).Where(query.Equal("country", "Kenya")), "authors", "name")
+	_, out, err := query.SQLBuilder(db, scanBook).
+		Where(query.GreaterThan("pages", 500), query.InQuery("author", kenyan)).Order(query.Asc("id")).
+		Select(ctx, "books", "id", "title", "author", "pages")
+	require.Nil(t, err)
+	assert.Equal(t, []int64{7, 9}, ids(out))
+
+	long := query.SQLBuilder[any](db, nil).Schema(shelf).Where(query.Equal("author", query.Column("authors.name")), query.GreaterThan("pages", 900))
+	names := func(condition query.Condition) []string {
+		_, out, err := query.SQLBuilder(db, func(rows pgx.Rows) (name string, err error) {
+			err = rows.Scan(&name)
+			return
+		}).Schema(shelf).Where(condition).Order(query.Asc("name")).Select(ctx, "authors", "name")
+		require.Nil(t, err)
+		return out
+	}
+	assert.Equal(t, []string{"Achebe"}, names(query.Exists(query.Subquery(long, "books", "id"))))
+	assert.Equal(t, []string{"Adichie", "Ngugi"}, names(query.NotExists(query.Subquery(long, "books", "id"))))
+
+	_, _, err = query.SQLBuilder(db, scanBook).Schema(shelf).
+		Where(query.Exists(query.Subquery(query.SQLBuilder[any](db, nil).Schema(shelf), "publishers", "id"))).Select(ctx, "books")
+	assert.ErrorIs(t, err, query.ErrUnknownIdentifier)
+	qb := query.SQLBuilder[any](nil, nil).
+		Where(query.InQuery("author", query.Subquery(query.SQLBuilder[any](nil, nil).Schema(shelf), "writers", "name")))
+	criteria, _ := qb.Criteria()
+	assert.Empty(t, criteria.String())
+	assert.ErrorIs(t, qb.Err(), query.ErrUnknownIdentifier)
+}
+
+func TestAggregates(t *testing.T) {
+	ctx := context.Background()
+	count, out, err := query.SQLBuilder(booksDB(t), scanTally).Schema(shelf).
+		Where(query.GreaterThan("pages", 100)).Group("author").Having(query.GreaterThan(query.Sum("pages"), 2600)).Count().
+		Select(ctx, "books", "author", query.Count("id", "books"))
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, count)
+	assert.Equal(t, []tally{{"Achebe", 5}}, out)
+
+	_, _, err = query.SQLBuilder(booksDB(t), scanTally).Schema(shelf).Group("author").
+		Select(ctx, "books", "author", query.Max("isbn", "latest"))
+	assert.ErrorIs(t, err, query.ErrUnknownIdentifier)
+}
+
+func TestWith(t *testing.T) {
+	ctx := context.Background()
+	long := query.Subquery(query.SQLBuilder[any](nil, nil).Where(query.GreaterThan("pages", 600)), "books", "id", "author")
+	count, out, err := query.SQLBuilder(shelfDB(t), scanTally).Schema(shelf).With("long", long).
+		Join("authors a", query.Equal("a.name", query.Column("l.author"))).Where(query.Equal("a.country", "Kenya")).
+		Group("a.name").Count().Select(ctx, "long l", "a.name", query.Count("l.id", "books"))
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, count)
+	assert.Equal(t, []tally{{"Ngugi", 2}}, out)
+}
+
+func TestComposedPlaceholders(t *testing.T) {
+	for _, tc := range []struct {
+		dialect query.Dialect
+		query   string
+	}{
+		{query.Postgres, `with "recent" as (select "id" from "main"."books" where "pages">$1) select "b"."title" from "main"."books" "b" join "recent" "r" on "r"."id"="b"."id" where ("b"."author"=$2) and ("b"."id" in (select "id" from "main"."books" where "pages"<$3)) limit 20`},
+		{query.MySQL, "with `recent` as (select `id` from `main`.`books` where `pages`>?) select `b`.`title` from `main`.`books` `b` join `recent` `r` on `r`.`id`=`b`.`id` where (`b`.`author`=?) and (`b`.`id` in (select `id` from `main`.`books` where `pages`<?)) limit 20"},
+		{query.SQLite, `with "recent" as (select "id" from "main"."books" where "pages">?1) select "b"."title" from "main"."books" "b" join "recent" "r" on "r"."id"="b"."id" where ("b"."author"=?2) and ("b"."id" in (select "id" from "main"."books" where "pages"<?3)) limit 20`},
+	} {
+		t.Run(tc.dialect.Name(), func(t *testing.T) {
+			db := &postsDB{}
+			_, _, err := query.SQLBuilder(db, scanPost).Dialect(tc.dialect).Schema(shelf).
+				With("recent", query.Subquery(query.SQLBuilder[any](nil, nil).Schema(shelf).Where(query.GreaterThan("pages", 500)), "books", "id")).
+				Join("recent r", query.Equal("r.id", query.Column("b.id"))).
+				Where(query.Equal("b.author", "Achebe"), query.InQuery("b.id", query.Subquery(query.SQLBuilder[any](nil, nil).Schema(shelf).Where(query.LessThan("pages", 900)), "books", "id"))).
+				Select(context.Background(), "books b", "b.title")
+			require.Nil(t, err)
+			assert.Equal(t, tc.query, db.query)
+			assert.Equal(t, []interface{}{500, "Achebe", 900}, db.args)
+		})
+	}
+}
@@ -2,8 +2,12 @@ package query
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/kod2ulz/gostart/utils"
 )
 
@@ -19,8 +23,26 @@ const (
 	CompareNotEqual           CompareOperator = "neq"
 	CompareNil                CompareOperator = "nil"
 	CompareLike               CompareOperator = "lyk"
+	CompareLikeCase           CompareOperator = "clyk" // like, case sensitive
+	CompareEqualFold          CompareOperator = "ieq"  // equal, case insensitive
+	CompareTextSearch         CompareOperator = "q"
 	CompareIn                 CompareOperator = "in"
 	CompareRaw                CompareOperator = "-"
+	CompareExists             CompareOperator = "exists"
+	CompareNotExists          CompareOperator = "nexists"
+	CompareDistinct           CompareOperator = "distinct"
+	CompareNotDistinct        CompareOperator = "ndistinct"
+
+	// postgres operators of jsonb, arrays and ranges
+	CompareContains    CompareOperator = "@>"
+	CompareContainedBy CompareOperator = "<@"
+	CompareOverlaps    CompareOperator = "&&"
+	CompareAdjacent    CompareOperator = "-|-"
+	CompareHasKey      CompareOperator = "?"
+	CompareHasAnyKey   CompareOperator = "?|"
+	CompareHasAllKeys  CompareOperator = "?&"
+	CompareJSONPath    CompareOperator = "@?"
+	CompareAny         CompareOperator = "any"
 )
 
 func (op CompareOperator) Eval(field string, argCount int) string {
@@ -39,6 +61,18 @@ func (op CompareOperator) Eval(field string, argCount int) string {
 		return field + "!=" + ARG_PLACEHOLDER
 	case CompareLike:
 		return field + " " + SELECT_LIKE + " " + ARG_PLACEHOLDER
+	case CompareLikeCase:
+		return field + " like " + ARG_PLACEHOLDER
+	case CompareEqualFold:
+		return "lower(" + field + ")=lower(" + ARG_PLACEHOLDER + ")"
+	case CompareTextSearch:
+		return Postgres.TextSearch(strings.Split(field, ","))
+	case CompareDistinct, CompareNotDistinct:
+		return Postgres.DistinctFrom(field, op == CompareDistinct)
+	case CompareAny:
+		return ARG_PLACEHOLDER + " = any(" + field + ")"
+	case CompareJSONPath:
+		return field + " @? " + ARG_PLACEHOLDER + "::jsonpath"
 	case CompareIn:
 		args := make([]string, argCount)
 		for i := 0; i < argCount; i++ {
@@ -50,6 +84,19 @@ func (op CompareOperator) Eval(field string, argCount int) string {
 	}
 }
 
+// evalFor is Eval with the operators of dialect d
+func (op CompareOperator) evalFor(d Dialect, field string, argCount int) string {
+	switch op {
+	case CompareLike, CompareLikeCase:
+		return field + " " + d.Like(op == CompareLike) + " " + ARG_PLACEHOLDER
+	case CompareTextSearch:
+		return d.TextSearch(strings.Split(field, ","))
+	case CompareDistinct, CompareNotDistinct:
+		return d.DistinctFrom(field, op == CompareDistinct)
+	}
+	return op.Eval(field, argCount)
+}
+
 type Constraint string
 
 const (
@@ -114,6 +161,12 @@ func Equal(field string, value interface{}) Condition {
 func Like(field string, value interface{}) Condition {
 	return Condition(doLeafCompare(CompareLike, field, value))
 }
+func LikeCase(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareLikeCase, field, value))
+}
+func EqualFold(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareEqualFold, field, value))
+}
 func NotEqual(field string, value interface{}) Condition {
 	return Condition(doLeafCompare(CompareNotEqual, field, value))
 }
@@ -132,14 +185,199 @@ func GreaterThanOrEqual(field string, value interface{}) Condition {
 func In[T any](field string, values ...T) Condition {
 	return Condition(doLeafCompare(CompareIn, field, values))
 }
-// func Raw(queryStr string) Condition {
-// 	return Condition(doLeafCompare(CompareIn, field, values))
-// }
+func Between(field string, from, to interface{}) Condition {
+	return And(GreaterThanOrEqual(field, from), LessThanOrEqual(field, to))
+}
+
+// TextSearch matches the words of text in columns, with the full text search of the
+// dialect e.g. tsvector on postgres
+func TextSearch(text string, columns ...string) Condition {
+	return Condition(doLeafCompare(CompareTextSearch, strings.Join(columns, ","), text))
+}
+
+// InQuery matches field against the rows of a subquery e.g. InQuery("author_id", Subquery(authors, "authors", "id"))
+func InQuery(field string, sub *subquery) Condition {
+	return Condition(doLeafCompare(CompareIn, field, sub))
+}
+
+// Exists matches when the subquery returns rows, usually correlated through Column
+func Exists(sub *subquery) Condition {
+	return Condition(doLeafCompare(CompareExists, "", sub))
+}
+
+func NotExists(sub *subquery) Condition {
+	return Condition(doLeafCompare(CompareNotExists, "", sub))
+}
+
+// DistinctFrom compares field to value treating nulls as values, unlike NotEqual
+func DistinctFrom(field string, value interface{}) Condition {
+	if value == nil {
+		return NotNull(field)
+	}
+	return Condition(doLeafCompare(CompareDistinct, field, value))
+}
+
+func NotDistinctFrom(field string, value interface{}) Condition {
+	if value == nil {
+		return Null(field)
+	}
+	return Condition(doLeafCompare(CompareNotDistinct, field, value))
+}
+
+// Contains matches jsonb, array or range fields containing value e.g. Contains("tags", []string{"go"})
+func Contains(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareContains, field, value))
+}
+
+// ContainedBy matches jsonb, array or range fields contained in value
+func ContainedBy(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareContainedBy, field, value))
+}
+
+// Overlaps matches array or range fields with elements in common with value
+func Overlaps(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareOverlaps, field, value))
+}
+
+// Adjacent matches range fields adjacent to the range value
+func Adjacent(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareAdjacent, field, value))
+}
+
+// Any matches array fields with an element equal to value
+func Any(field string, value interface{}) Condition {
+	return Condition(doLeafCompare(CompareAny, field, value))
+}
+
+// HasKey matches jsonb fields with the top level key
+func HasKey(field string, key string) Condition {
+	return Condition(doLeafCompare(CompareHasKey, field, key))
+}
+
+func HasAnyKey(field string, keys ...string) Condition {
+	return Condition(doLeafCompare(CompareHasAnyKey, field, keys))
+}
+
+func HasAllKeys(field string, keys ...string) Condition {
+	return Condition(doLeafCompare(CompareHasAllKeys, field, keys))
+}
+
+// JSONPathExists matches jsonb fields in which the jsonpath finds items e.g. $.tags[*] ? (@ == "go")
+func JSONPathExists(field string, path string) Condition {
+	return Condition(doLeafCompare(CompareJSONPath, field, path))
+}
+
+// JSON is the jsonb at the path of keys in field, to compare with e.g. Contains(JSON("data", "address"), ...)
+func JSON(field string, keys ...string) string {
+	return jsonPath(field, keys, "->")
+}
+
+// JSONText is the text at the path of keys in field, to compare with e.g. Equal(JSONText("data", "address", "city"), "Kampala")
+func JSONText(field string, keys ...string) string {
+	return jsonPath(field, keys, "->>")
+}
+
+func jsonPath(field string, keys []string, last string) string {
+	var sb strings.Builder
+	sb.WriteString(field)
+	for i, key := range keys {
+		if i == len(keys)-1 {
+			sb.WriteString(last)
+		} else {
+			sb.WriteString("->")
+		}
+		if _, err := strconv.Atoi(key); err == nil {
+			sb.WriteString(key)
+		} else {
+			sb.WriteString("'" + strings.ReplaceAll(key, "'", "''") + "'")
+		}
+	}
+	return sb.String()
+}
+
+// Expr is a condition written in sql with ? for each of its args, renumbered for the
+// dialect and position in the query. ?? writes a ? e.g. for jsonb operators, and ? within
+// quotes is left as is. the sql is not checked against schemas, never build it from input
+//
+//	query.Expr("date_trunc('day', created_at) = ?", day)
+func Expr(sql string, args ...interface{}) Condition {
+	expr, n := exprOf(sql)
+	if n != len(args) {
+		return Condition(doLeafCompare(CompareRaw, "", invalidExpr{errors.Errorf("expression %q has %d placeholders for %d args", sql, n, len(args))}))
+	}
+	return Condition(doLeafCompare(CompareRaw, "", sqlFragment{sql: expr, args: args}))
+}
+
+// invalidExpr fails the queries of an Expr with args not matching its placeholders
+type invalidExpr struct {
+	err error
+}
+
+// exprOf replaces the placeholders of Expr sql with ARG_PLACEHOLDER, counting them. ? is
+// left as is within 'strings', "identifiers" and $tag$ dollar quoted strings $tag$
+func exprOf(sql string) (string, int) {
+	var sb strings.Builder
+	var n int
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				sb.WriteString(sql[i:])
+				return sb.String(), n
+			}
+			sb.WriteString(sql[i : i+end+2])
+			i += end + 1
+		case c == '$':
+			var tag string
+			if i == 0 || !identifierByte(sql[i-1]) {
+				tag = dollarTagPattern.FindString(sql[i:])
+			}
+			if tag == "" {
+				sb.WriteByte(c)
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				sb.WriteString(sql[i:])
+				return sb.String(), n
+			}
+			sb.WriteString(sql[i : i+len(tag)+end+len(tag)])
+			i += len(tag) + end + len(tag) - 1
+		case c == '?' && i+1 < len(sql) && sql[i+1] == '?':
+			sb.WriteByte(c)
+			i++
+		case c == '?':
+			sb.WriteString(ARG_PLACEHOLDER)
+			n++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), n
+}
+
+// identifierByte reports whether c may be part of an unquoted identifier, where $ does not
+// open a dollar quoted string
+func identifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
 
 func And(conditions ...Condition) Condition { return doNode(WhereAnd, conditions...) }
 func Or(conditions ...Condition) Condition  { return doNode(WhereOr, conditions...) }
 
+// UrlFieldParams matches the fields of p, failing queries on field names that are not
+// identifiers as they may come from urls and on searches with values that do not parse
 func UrlFieldParams(p URLSearchParam) Condition {
+	if err := urlFieldsValid(p); err != nil {
+		return Condition(doLeafCompare(CompareRaw, "", invalidExpr{err}))
+	} else if cp, ok := p.(URLConditionParam); ok {
+		conditions, err := cp.GetConditions()
+		if err != nil {
+			return Condition(doLeafCompare(CompareRaw, "", invalidExpr{err}))
+		}
+		return And(conditions...)
+	}
 	conditions := make([]Condition, 0)
 	if len(p.GetFieldValues()) > 0 {
 		for field, value := range p.GetFieldValues() {
@@ -158,6 +396,10 @@ func UrlFieldParams(p URLSearchParam) Condition {
 	if len(p.GetFieldComparisons()) > 0 {
 		for field, compare := range p.GetFieldComparisons() {
 			for op, val := range compare {
+				if op == CompareIn {
+					conditions = append(conditions, In(field, val.StringList(",")...))
+					continue
+				}
 				conditions = append(conditions, doLeafCompare(op, field, val))
 			}
 		}
@@ -165,6 +407,27 @@ func UrlFieldParams(p URLSearchParam) Condition {
 	return And(conditions...)
 }
 
+// urlFieldsValid checks the fields of the values, nullables and comparisons of p
+func urlFieldsValid(p URLSearchParam) error {
+	fields := make([]string, 0)
+	for field := range p.GetFieldValues() {
+		fields = append(fields, field)
+	}
+	for field := range p.GetFieldNullables() {
+		fields = append(fields, field)
+	}
+	for field := range p.GetFieldComparisons() {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	for _, field := range fields {
+		if !fieldPattern.MatchString(field) {
+			return &UnknownIdentifierError{Kind: "field", Name: field}
+		}
+	}
+	return nil
+}
+
 type WhereCriteria struct {
 	constraint Constraint
 	operator   CompareOperator
@@ -174,38 +437,67 @@ type WhereCriteria struct {
 	leaf       bool
 
 	criteria map[Constraint][]*WhereCriteria
+	// order of the constraints of criteria, in which they are built
+	order []Constraint
 }
 
 func (wc *WhereCriteria) Append(cs Constraint, cr *WhereCriteria) {
 	if len(wc.criteria) == 0 {
 		wc.criteria = make(map[Constraint][]*WhereCriteria)
 	}
+	if _, ok := wc.criteria[cs]; !ok {
+		wc.order = append(wc.order, cs)
+	}
 	if len(wc.criteria[cs]) == 0 {
 		wc.criteria[cs] = make([]*WhereCriteria, 0)
 	}
 	wc.criteria[cs] = append(wc.criteria[cs], cr)
 }
 
-func (wc *WhereCriteria) finalise(do bool, sb *strings.Builder, val string, args ...interface{}) {
+// constraints lists the constraints of criteria in the order they were appended,
+// followed by those added to the map directly
+func (wc *WhereCriteria) constraints() (out []Constraint) {
+	out = append(out, wc.order...)
+	var rest []Constraint
+	for cs := range wc.criteria {
+		if !slices.Contains(wc.order, cs) {
+			rest = append(rest, cs)
+		}
+	}
+	slices.Sort(rest)
+	return append(out, rest...)
+}
+
+func (wc *WhereCriteria) finalise(d Dialect, do bool, sb *strings.Builder, val string, args ...interface{}) {
 	if !do || val == "" || len(args) == 0 {
 		sb.WriteString(val)
 		return
 	}
-	var out string = val
-	for i := range args {
-		out = strings.Replace(out, ARG_PLACEHOLDER, fmt.Sprintf("$%d", i+1), 1)
-	}
-	sb.WriteString(out)
+	sb.WriteString(bindPlaceholders(d, val, 0))
 }
 
+// Build writes the criteria for postgres, with numbered placeholders when finalised. it
+// writes nothing when a subquery of the criteria cannot be rendered or a condition is
+// invalid e.g. an Expr with args not matching its placeholders, see sqlBuilder.Err
 func (wc *WhereCriteria) Build(finalise bool) (sb strings.Builder, args []interface{}) {
+	sb, args, _ = wc.build(Postgres, finalise)
+	return
+}
+
+// BuildFor writes the criteria with the operators and placeholders of dialect d, see Build
+func (wc *WhereCriteria) BuildFor(d Dialect) (sb strings.Builder, args []interface{}) {
+	sb, args, _ = wc.build(d, true)
+	return
+}
+
+func (wc *WhereCriteria) build(d Dialect, finalise bool) (sb strings.Builder, args []interface{}, err error) {
 	if !wc.leaf {
 		if len(wc.criteria) == 0 {
 			return
 		}
 		args = make([]interface{}, 0)
 		queries0 := make([]string, 0)
-		for cs := range wc.criteria {
+		for _, cs := range wc.constraints() {
 			if len(wc.criteria[cs]) == 0 {
 				continue
 			}
@@ -214,8 +506,10 @@ func (wc *WhereCriteria) Build(finalise bool) (sb strings.Builder, args []interf
 				if wc.criteria[cs][i] == nil {
 					continue
 				}
-				sub_sb, ar := wc.criteria[cs][i].Build(false)
-				if sub_sb.Len() == 0 {
+				sub_sb, ar, e := wc.criteria[cs][i].build(d, false)
+				if e != nil {
+					return sb, nil, e
+				} else if sub_sb.Len() == 0 {
 					continue
 				}
 				queries1 = append(queries1, sub_sb.String())
@@ -234,9 +528,9 @@ func (wc *WhereCriteria) Build(finalise bool) (sb strings.Builder, args []interf
 		case 0:
 			return
 		case 1:
-			wc.finalise(finalise, &sb, queries0[0], args...)
+			wc.finalise(d, finalise, &sb, queries0[0], args...)
 		default:
-			wc.finalise(finalise, &sb, "("+strings.Join(queries0, fmt.Sprintf(") %s (", wc.constraint))+")", args...)
+			wc.finalise(d, finalise, &sb, "("+strings.Join(queries0, fmt.Sprintf(") %s (", wc.constraint))+")", args...)
 		}
 		return
 	}
@@ -248,11 +542,43 @@ func (wc *WhereCriteria) Build(finalise bool) (sb strings.Builder, args []interf
 		}
 		return
 	}
+	switch val := wc.value.(type) {
+	case columnRef:
+		sb.WriteString(strings.Replace(wc.operator.evalFor(d, wc.field, 1), ARG_PLACEHOLDER, string(val), 1))
+		return
+	case *subquery:
+		query, subArgs, e := val.render(d, nil)
+		if e != nil {
+			return sb, nil, e
+		}
+		wc.writeSubquery(&sb, d, query)
+		return sb, subArgs, nil
+	case sqlFragment:
+		wc.writeSubquery(&sb, d, val.sql)
+		return sb, val.args, nil
+	case invalidExpr:
+		return sb, nil, val.err
+	}
 	if wc.operator == CompareIn {
 		utils.StructCopy(wc.value, &args)
 	} else {
 		args = []interface{}{wc.value}
 	}
-	sb.WriteString(wc.operator.Eval(wc.field, len(args)))
+	sb.WriteString(wc.operator.evalFor(d, wc.field, len(args)))
 	return
 }
+
+func (wc *WhereCriteria) writeSubquery(sb *strings.Builder, d Dialect, query string) {
+	switch wc.operator {
+	case CompareExists:
+		sb.WriteString("exists (" + query + ")")
+	case CompareNotExists:
+		sb.WriteString("not exists (" + query + ")")
+	case CompareIn:
+		sb.WriteString(wc.field + " in (" + query + ")")
+	case CompareRaw:
+		sb.WriteString("(" + query + ")")
+	default:
+		sb.WriteString(strings.Replace(wc.operator.evalFor(d, wc.field, 1), ARG_PLACEHOLDER, "("+query+")", 1))
+	}
+}
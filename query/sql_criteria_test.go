@@ -1,11 +1,13 @@
 package query_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/kod2ulz/gostart/query"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func arg(name string, num int, op ...string) string {
@@ -69,6 +71,21 @@ var criteria_test_cases = []struct {
 		expected:   fmt.Sprintf("((%s is not null) or (%s is not null) or (%s is not null)) %s (%s)", "age", "dob", "country", query.WhereAnd, arg("lname", 1, query.SELECT_LIKE)),
 		args:       []interface{}{"doe"},
 	},
+	{
+		conditions: []query.Condition{query.Expr("lower(email) = lower(?)", "Jo@x.io"), query.Expr("data ?? 'a?' and tags && ?", []string{"go"})},
+		expected:   "((lower(email) = lower($1))) and ((data ? 'a?' and tags && $2))",
+		args:       []interface{}{"Jo@x.io", []string{"go"}},
+	},
+	{
+		conditions: []query.Condition{query.Contains("data", `{"a":1}`), query.Overlaps("tags", []string{"go"}), query.Any("tags", "go"), query.HasAnyKey("data", "a", "b")},
+		expected:   "(data @> $1) and (tags && $2) and ($3 = any(tags)) and (data ?| $4)",
+		args:       []interface{}{`{"a":1}`, []string{"go"}, "go", []string{"a", "b"}},
+	},
+	{
+		conditions: []query.Condition{query.Equal(query.JSONText("data", "address", "0", "it's"), "x"), query.JSONPathExists("data", "$.tags"), query.DistinctFrom("age", 3), query.NotDistinctFrom("dob", nil)},
+		expected:   "(data->'address'->0->>'it''s'=$1) and (data @? $2::jsonpath) and (age is distinct from $3) and (dob is null)",
+		args:       []interface{}{"x", "$.tags", 3},
+	},
 }
 
 func TestWhereConditionBuilder(t *testing.T) {
@@ -79,3 +96,25 @@ func TestWhereConditionBuilder(t *testing.T) {
 		assert.Equal(t, tc.args, args)
 	}
 }
+
+func TestExpr(t *testing.T) {
+	ctx := context.Background()
+	_, out, err := query.SQLBuilder(booksDB(t), scanBook).
+		Where(query.GreaterThan("pages", 100), query.Expr("(pages / ?) % ? = 0 and title != '?'", 100, 3), query.DistinctFrom("author", "Ngugi")).
+		Order(query.Asc("id")).Select(ctx, "books")
+	require.Nil(t, err)
+	assert.Equal(t, []int64{6}, ids(out))
+
+	_, _, err = query.SQLBuilder(booksDB(t), scanBook).Where(query.Expr("pages > ? and pages < ?", 100)).Select(ctx, "books")
+	assert.ErrorContains(t, err, "2 placeholders for 1 args")
+	qb := query.SQLBuilder[any](nil, nil).Where(query.Equal("id", 1), query.Expr("pages > ?"))
+	qb.Criteria()
+	assert.ErrorContains(t, qb.Err(), "1 placeholders for 0 args")
+
+	qb = query.SQLBuilder[any](nil, nil).Dialect(query.Postgres).
+		Where(query.Expr(`"why?" = ? and body = $q$is it?$q$ and note != 'it''s ?' and pages > ? and $$?$$ != ''`, 1, 2))
+	criteria, args := qb.Criteria()
+	require.Nil(t, qb.Err())
+	assert.Equal(t, `("why?" = $1 and body = $q$is it?$q$ and note != 'it''s ?' and pages > $2 and $$?$$ != '')`, criteria.String())
+	assert.Equal(t, []interface{}{1, 2}, args)
+}
@@ -1,5 +1,7 @@
 package query
 
+import "sort"
+
 type SortType string
 
 const (
@@ -18,6 +20,8 @@ func sortTypeValid(str string) bool {
 
 type SortConsumer interface {
 	addFieldSort(sort SortType, fields ...string)
+	// sortFailed fails the queries of the consumer e.g. on sorts by invalid fields
+	sortFailed(err error)
 }
 
 type SortFunc func(SortConsumer)
@@ -35,8 +39,21 @@ func UrlFieldSort(p URLSearchParam) SortFunc {
 		if len(p.GetFieldSort()) == 0 {
 			return
 		}
-		for field, sort := range p.GetFieldSort() {
-			sc.addFieldSort(sort, field)
+		// fields are sorted by name so that keyset cursors keep matching the order
+		fields := make([]string, 0, len(p.GetFieldSort()))
+		for field := range p.GetFieldSort() {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if !fieldPattern.MatchString(field) {
+				sc.sortFailed(&UnknownIdentifierError{Kind: "field", Name: field})
+				return
+			} else if cp, ok := p.(URLConditionParam); ok {
+				sc.addFieldSort(p.GetFieldSort()[field], cp.GetFieldColumn(field))
+				continue
+			}
+			sc.addFieldSort(p.GetFieldSort()[field], field)
 		}
 	}
 }
@@ -0,0 +1,88 @@
+package query
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// RowStream iterates over the rows of SelectStream
+//
+//	for stream.Next() {
+//		item := stream.Value()
+//	}
+//	stream.Close()
+//	err := stream.Err()
+type RowStream[T any] interface {
+	Next() bool
+	Value() T
+	Err() error
+	Close()
+	Total(ctx context.Context) (int64, error)
+}
+
+type rowStream[T any] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	rows   pgx.Rows
+	scan   RowScanFunc[T]
+	total  func(context.Context) (int64, error)
+
+	value T
+	read  int64
+	done  bool
+	err   error
+}
+
+// Next scans the next row, false once the rows are exhausted, the context is done or scanning fails
+func (s *rowStream[T]) Next() bool {
+	if s.done {
+		return false
+	} else if s.err = s.ctx.Err(); s.err != nil || !s.rows.Next() {
+		s.finish()
+		return false
+	} else if s.value, s.err = s.scan(s.rows); s.err != nil {
+		s.finish()
+		return false
+	}
+	s.read++
+	return true
+}
+
+func (s *rowStream[T]) finish() {
+	if s.done {
+		return
+	}
+	s.done = true
+	if s.err == nil {
+		s.err = s.rows.Err()
+	}
+}
+
+// Value is the row scanned by the last call to Next
+func (s *rowStream[T]) Value() T {
+	return s.value
+}
+
+func (s *rowStream[T]) Err() error {
+	return s.err
+}
+
+// Close releases the rows, cancelling the query if some were left unread
+func (s *rowStream[T]) Close() {
+	if !s.done {
+		s.done = true
+		s.cancel()
+	}
+	s.rows.Close()
+	s.cancel()
+}
+
+// Total counts the rows matching the criteria when the builder counts them and the
+// rows read otherwise. it queries the database so it should be called after Close
+func (s *rowStream[T]) Total(ctx context.Context) (int64, error) {
+	if s.total == nil {
+		return s.read, nil
+	}
+	return s.total(ctx)
+}
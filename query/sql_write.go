@@ -0,0 +1,246 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"github.com/kod2ulz/gostart/utils"
+)
+
+// ErrUnconditionalWrite is returned by Update and Delete without conditions, which would
+// write every row of the relation. Where(NotNull("id")) says so explicitly
+var ErrUnconditionalWrite = errors.New("refusing to write without conditions")
+
+// Returning scans the fields of the rows written by Insert, Upsert, Update and Delete into T
+func (sb *sqlBuilder[T]) Returning(fields ...string) *sqlBuilder[T] {
+	sb.returning = fields
+	return sb
+}
+
+// OnConflict turns inserts into upserts when rows conflict on columns, updating the columns
+// given to DoUpdate or ignoring the rows without them
+func (sb *sqlBuilder[T]) OnConflict(columns ...string) *sqlBuilder[T] {
+	sb.conflict = columns
+	return sb
+}
+
+// DoUpdate sets the columns updated by upserts. see OnConflict
+func (sb *sqlBuilder[T]) DoUpdate(columns ...string) *sqlBuilder[T] {
+	sb.conflictUpdate = columns
+	return sb
+}
+
+// Insert writes rows, structs with fields tagged db, else named after their snake cased
+// names, or maps of columns to values. all rows must have the same columns. unset
+// optional.* and sql.Null* values are inserted as null and fields tagged db:",omitempty"
+// are left out when zero e.g. generated ids
+func (sb *sqlBuilder[T]) Insert(ctx context.Context, relation string, rows ...interface{}) (affected int64, out []T, err error) {
+	return sb.insert(ctx, relation, sb.conflictUpdate, rows)
+}
+
+// insert writes rows, updating the update columns of rows conflicting on sb.conflict
+func (sb *sqlBuilder[T]) insert(ctx context.Context, relation string, update []string, rows []interface{}) (affected int64, out []T, err error) {
+	if len(rows) == 0 {
+		return
+	}
+	var columns []string
+	values := make([]interface{}, 0)
+	for i := range rows {
+		rowColumns, rowValues, e := writeValuesOf(rows[i], false)
+		if e != nil {
+			return 0, nil, errors.Wrapf(e, "failed to read row %d", i)
+		} else if i > 0 && strings.Join(rowColumns, ",") != strings.Join(columns, ",") {
+			return 0, nil, errors.Errorf("row %d has columns %v, expected %v", i, rowColumns, columns)
+		}
+		columns, values = rowColumns, append(values, rowValues...)
+	}
+	if len(columns) == 0 {
+		return 0, nil, errors.Errorf("no columns to insert into %s", relation)
+	}
+	st, err := sb.writeStatement(relation)
+	if err != nil {
+		return
+	}
+	quoted, err := st.columns(sb.dialect, columns)
+	if err != nil {
+		return
+	}
+	var query strings.Builder
+	query.WriteString(fmt.Sprintf("insert into %s (%s) values ", st.relation, strings.Join(quoted, ", ")))
+	for i := range rows {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(" + strings.Repeat(ARG_PLACEHOLDER+", ", len(columns)-1) + ARG_PLACEHOLDER + ")")
+	}
+	if len(sb.conflict) > 0 {
+		var conflict []string
+		if conflict, err = st.columnNames(sb.conflict); err != nil {
+			return
+		} else if update, err = st.columnNames(update); err != nil {
+			return
+		}
+		query.WriteString(" " + sb.dialect.Upsert(conflict, update))
+	}
+	return sb.write(ctx, st, bindPlaceholders(sb.dialect, query.String(), 0), values)
+}
+
+// Upsert inserts rows, updating those conflicting on the columns given to OnConflict with
+// the columns given to DoUpdate, else every inserted column outside the conflict target
+func (sb *sqlBuilder[T]) Upsert(ctx context.Context, relation string, rows ...interface{}) (affected int64, out []T, err error) {
+	if len(sb.conflict) == 0 {
+		return 0, nil, errors.New("upserts require OnConflict columns")
+	}
+	update := sb.conflictUpdate
+	if len(update) == 0 && len(rows) > 0 {
+		var columns []string
+		if columns, _, err = writeValuesOf(rows[0], false); err != nil {
+			return
+		}
+		conflict := strings.Join(sb.conflict, ",")
+		for i := range columns {
+			if !strings.Contains(","+conflict+",", ","+columns[i]+",") {
+				update = append(update, columns[i])
+			}
+		}
+	}
+	return sb.insert(ctx, relation, update, rows)
+}
+
+// Update sets the columns of values, a struct or map like the rows of Insert, on the rows
+// matching the conditions of the builder. unset optional.*, sql.Null* and nil pointer
+// values are skipped so that partial updates only write what was given
+func (sb *sqlBuilder[T]) Update(ctx context.Context, relation string, values interface{}) (affected int64, out []T, err error) {
+	columns, args, err := writeValuesOf(values, true)
+	if err != nil {
+		return
+	} else if len(columns) == 0 {
+		return 0, nil, errors.Errorf("no values to update %s with", relation)
+	}
+	st, err := sb.writeStatement(relation)
+	if err != nil {
+		return
+	}
+	quoted, err := st.columns(sb.dialect, columns)
+	if err != nil {
+		return
+	}
+	set := make([]string, len(quoted))
+	for i := range quoted {
+		set[i] = quoted[i] + " = " + ARG_PLACEHOLDER
+	}
+	var w sqlWriter
+	w.write(fmt.Sprintf("update %s set %s", st.relation, strings.Join(set, ", ")), args...)
+	if !w.criteria(" where ", sb.dialect, st.where, sqlFragment{}) {
+		return 0, nil, errors.Wrap(ErrUnconditionalWrite, relation)
+	}
+	return sb.write(ctx, st, w.bound(sb.dialect), w.args)
+}
+
+// Delete removes the rows matching the conditions of the builder
+func (sb *sqlBuilder[T]) Delete(ctx context.Context, relation string) (affected int64, out []T, err error) {
+	st, err := sb.writeStatement(relation)
+	if err != nil {
+		return
+	}
+	var w sqlWriter
+	w.write("delete from " + st.relation)
+	if !w.criteria(" where ", sb.dialect, st.where, sqlFragment{}) {
+		return 0, nil, errors.Wrap(ErrUnconditionalWrite, relation)
+	}
+	return sb.write(ctx, st, w.bound(sb.dialect), w.args)
+}
+
+// writeStatement resolves the relation, conditions and returned fields of a write
+func (sb *sqlBuilder[T]) writeStatement(relation string) (st statement, err error) {
+	return sb.statement(sb.dialect, relation, sb.returning, nil)
+}
+
+// write runs query, scanning the returned rows into T when the builder has Returning fields
+func (sb *sqlBuilder[T]) write(ctx context.Context, st statement, query string, args []interface{}) (affected int64, out []T, err error) {
+	if len(sb.returning) == 0 {
+		tag, e := sb.dbtx.Exec(ctx, query, args...)
+		return tag.RowsAffected(), nil, e
+	} else if sb.rowScanner == nil {
+		return 0, nil, errors.New("rowScanner func was undefined")
+	}
+	var rows pgx.Rows
+	if rows, err = sb.dbtx.Query(ctx, query+" returning "+strings.Join(st.fields, ", "), args...); err != nil {
+		return
+	}
+	defer rows.Close()
+	out = make([]T, 0)
+	for rows.Next() {
+		var i T
+		if i, err = sb.rowScanner(rows); err != nil {
+			return
+		}
+		out = append(out, i)
+	}
+	// the tag is only complete once the rows are closed
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return rows.CommandTag().RowsAffected(), out, nil
+}
+
+// writeValuesOf lists the columns and values of a struct or map, sorting the columns of maps.
+// partial skips unset values
+func writeValuesOf(row interface{}, partial bool) (columns []string, values []interface{}, err error) {
+	val := reflect.Indirect(reflect.ValueOf(row))
+	switch val.Kind() {
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return nil, nil, errors.Errorf("cannot write %T, map keys must be strings", row)
+		}
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			value, set := utils.Null.Value(val.MapIndex(key).Interface())
+			if !set && partial {
+				continue
+			}
+			columns, values = append(columns, key.String()), append(values, value)
+		}
+		return
+	case reflect.Struct:
+		structValues(val, partial, &columns, &values)
+		return
+	}
+	return nil, nil, errors.Errorf("cannot write %T, expected a struct or map", row)
+}
+
+func structValues(val reflect.Value, partial bool, columns *[]string, values *[]interface{}) {
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("db"), ",")
+		if name == "-" {
+			continue
+		} else if ft := field.Type; field.Anonymous && name == "" {
+			if fv := reflect.Indirect(val.Field(i)); ft.Kind() == reflect.Struct || (ft.Kind() == reflect.Pointer && fv.Kind() == reflect.Struct) {
+				structValues(fv, partial, columns, values)
+			}
+			continue
+		} else if !field.IsExported() {
+			continue
+		} else if name == "" {
+			name = strcase.ToSnake(field.Name)
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && val.Field(i).IsZero() {
+			continue
+		}
+		value, set := utils.Null.Value(val.Field(i).Interface())
+		if !set && partial {
+			continue
+		}
+		*columns, *values = append(*columns, name), append(*values, value)
+	}
+}
@@ -0,0 +1,115 @@
+package query_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/markphelps/optional"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+)
+
+type newBook struct {
+	ID     int64  `db:"id,omitempty"`
+	Title  string `db:"title"`
+	Author string
+	Pages  int64
+	Secret string `db:"-"`
+}
+
+type bookChanges struct {
+	Title  optional.String `db:"title"`
+	Author sql.NullString  `db:"author"`
+	Pages  *int64          `db:"pages"`
+}
+
+func TestInsert(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	affected, out, err := query.SQLBuilder(db, scanBook).Returning("id", "title", "author", "pages").
+		Insert(ctx, "books", newBook{Title: "Arrow of God", Author: "Achebe", Pages: 230, Secret: "x"}, &newBook{ID: 20, Title: "Devil on the Cross", Author: "Ngugi", Pages: 254})
+	require.NotNil(t, err, "rows of different columns")
+
+	affected, out, err = query.SQLBuilder(db, scanBook).Returning("id", "title", "author", "pages").
+		Insert(ctx, "books", newBook{ID: 11, Title: "Arrow of God", Author: "Achebe", Pages: 230}, &newBook{ID: 20, Title: "Devil on the Cross", Author: "Ngugi", Pages: 254})
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, affected)
+	assert.Equal(t, []book{{11, "Arrow of God", "Achebe", 230}, {20, "Devil on the Cross", "Ngugi", 254}}, out)
+
+	affected, _, err = query.SQLBuilder(db, scanBook).Insert(ctx, "books", map[string]interface{}{"title": "Petals of Blood", "author": "Ngugi", "pages": 345})
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, affected)
+
+	count, _, err := query.SQLBuilder(db, scanBook).Count().Limit(1).Select(ctx, "books")
+	require.Nil(t, err)
+	assert.EqualValues(t, 13, count)
+
+	_, _, err = query.SQLBuilder(db, scanBook).Insert(ctx, "books", map[string]interface{}{"title) values (1); drop table books; --": "x"})
+	assert.ErrorIs(t, err, query.ErrUnknownIdentifier)
+	_, _, err = query.SQLBuilder(db, scanBook).Where(query.Equal("id", 1)).Update(ctx, "books", map[string]interface{}{`"title"`: "x"})
+	assert.ErrorIs(t, err, query.ErrUnknownIdentifier)
+}
+
+func TestUpsert(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	_, out, err := query.SQLBuilder(db, scanBook).Schema(library).OnConflict("id").Returning("id", "title", "writer", "pages").
+		Upsert(ctx, "books", newBook{ID: 1, Title: "Things Fall Apart", Author: "Achebe", Pages: 209}, newBook{ID: 30, Title: "Weep Not, Child", Author: "Ngugi", Pages: 136})
+	require.Nil(t, err)
+	assert.Equal(t, []book{{1, "Things Fall Apart", "Achebe", 209}, {30, "Weep Not, Child", "Ngugi", 136}}, out)
+
+	affected, _, err := query.SQLBuilder(db, scanBook).OnConflict("id").Insert(ctx, "books", newBook{ID: 30, Title: "ignored", Author: "Ngugi", Pages: 1})
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, affected)
+
+	// the update columns inferred from the rows of one upsert are not kept by the builder
+	_, err = db.Exec(ctx, "create table notes (id integer primary key, title text, pages integer)")
+	require.Nil(t, err)
+	upsert := query.SQLBuilder(db, scanBook).OnConflict("id")
+	_, _, err = upsert.Upsert(ctx, "notes", map[string]interface{}{"id": 1, "title": "draft"})
+	require.Nil(t, err)
+	_, _, err = upsert.Upsert(ctx, "notes", map[string]interface{}{"id": 1, "pages": 7})
+	require.Nil(t, err)
+	var title string
+	require.Nil(t, db.QueryRow(ctx, "select title from notes where id = 1").Scan(&title))
+	assert.Equal(t, "draft", title)
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	pages := int64(1)
+	_, out, err := query.SQLBuilder(db, scanBook).Where(query.In("id", 1, 2)).Returning("id", "title", "author", "pages").
+		Update(ctx, "books", bookChanges{Title: optional.NewString("Renamed"), Pages: &pages})
+	require.Nil(t, err)
+	assert.Equal(t, []book{{1, "Renamed", "Ngugi", 1}, {2, "Renamed", "Achebe", 1}}, out)
+
+	affected, _, err := query.SQLBuilder(db, scanBook).Schema(library).Where(query.Equal("writer", "Ngugi")).
+		Update(ctx, "books", map[string]interface{}{"writer": sql.NullString{String: "wa Thiong'o", Valid: true}, "pages": optional.Int64{}})
+	require.Nil(t, err)
+	assert.EqualValues(t, 5, affected)
+
+	_, _, err = query.SQLBuilder(db, scanBook).Update(ctx, "books", map[string]interface{}{"pages": 0})
+	assert.ErrorIs(t, err, query.ErrUnconditionalWrite)
+	_, _, err = query.SQLBuilder(db, scanBook).Schema(library).Where(query.Equal("id", 1)).Update(ctx, "books", map[string]interface{}{"isbn": "x"})
+	assert.ErrorIs(t, err, query.ErrUnknownIdentifier)
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	affected, out, err := query.SQLBuilder(db, scanBook).Where(query.GreaterThan("pages", 800)).Returning("id", "title", "author", "pages").Delete(ctx, "books")
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, affected)
+	assert.Equal(t, []int64{9, 10}, ids(out))
+
+	affected, _, err = query.SQLBuilder(db, scanBook).Where(query.LessThan("pages", 300)).Delete(ctx, "books")
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, affected)
+
+	_, _, err = query.SQLBuilder(db, scanBook).Delete(ctx, "books")
+	assert.ErrorIs(t, err, query.ErrUnconditionalWrite)
+}
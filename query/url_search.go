@@ -3,13 +3,69 @@ package query
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/iancoleman/strcase"
-	"github.com/kod2ulz/gostart/object"
 	"github.com/kod2ulz/gostart/utils"
+	"github.com/pkg/errors"
 )
 
+// ErrInvalidSearch is matched by the errors of searches with values that do not parse as
+// the types of their fields or params that do not follow the grammar
+var ErrInvalidSearch error = &inputError{"invalid search"}
+
+// FieldType is the type search values of a field are parsed to. see UrlFields.Types
+type FieldType string
+
+const (
+	FieldString FieldType = "string"
+	FieldInt    FieldType = "int"
+	FieldFloat  FieldType = "float"
+	FieldBool   FieldType = "bool"
+	FieldTime   FieldType = "time"
+	FieldUUID   FieldType = "uuid"
+)
+
+// Parse reads v as a value of the type, strings when the type is not known. times are
+// RFC 3339, or dates and times without a zone taken as UTC
+func (t FieldType) Parse(v utils.Value) (out interface{}, err error) {
+	str := strings.TrimSpace(v.String())
+	switch t {
+	case FieldInt:
+		out, err = strconv.ParseInt(str, 10, 64)
+	case FieldFloat:
+		out, err = strconv.ParseFloat(str, 64)
+	case FieldBool:
+		out, err = strconv.ParseBool(str)
+	case FieldUUID:
+		out, err = uuid.Parse(str)
+	case FieldTime:
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if out, err = time.Parse(layout, str); err == nil {
+				break
+			}
+		}
+	default:
+		return v.String(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not a valid %s", ErrInvalidSearch, str, t)
+	}
+	return
+}
+
+// urlComparisons are the operators of <field>_<operator> params, besides <field>_between
+var urlComparisons = []CompareOperator{
+	CompareGreaterThan, CompareGreaterThanOrEqual, CompareLessThan, CompareLessThanOrEqual,
+	CompareNot, CompareNotEqual, CompareIn, CompareEqualFold}
+
+// likeFormats are the params of like comparisons, ending with, containing or starting with values
+var likeFormats = []string{"~%s", "~%s~", "%s~"}
+
 type UrlFieldReader func(ctx context.Context, name string, _default ...string) (out utils.Value)
 
 type URLSearchParam interface {
@@ -22,12 +78,27 @@ type URLSearchParam interface {
 	HasFieldParams() bool
 }
 
+// URLCursorParam is implemented by search params carrying a keyset pagination cursor
+type URLCursorParam interface {
+	GetCursor() string
+}
+
+// URLConditionParam is implemented by search params building their own conditions, with
+// values parsed to the types of their fields and conditions beyond the maps of URLSearchParam
+type URLConditionParam interface {
+	GetConditions() ([]Condition, error)
+	// GetFieldColumn is the column of a field, the field itself unless mapped
+	GetFieldColumn(field string) string
+}
+
 type URLSearchLoader interface {
 	Load(ctx context.Context, fields ...string) URLSearchLoader
 	LoadBoundaries(ctx context.Context) URLSearchLoader
 	LoadFieldSort(ctx context.Context, fields ...string) URLSearchLoader
 	LoadFieldLookups(ctx context.Context, fields ...string) URLSearchLoader
 	LoadFieldComparisons(ctx context.Context, fields ...string) URLSearchLoader
+	LoadOrGroups(ctx context.Context, fields ...string) URLSearchLoader
+	LoadTextSearch(ctx context.Context, columns ...string) URLSearchLoader
 }
 
 func SearchUrl(queryReader UrlFieldReader) *urlSearch {
@@ -37,18 +108,64 @@ func SearchUrl(queryReader UrlFieldReader) *urlSearch {
 type urlSearch struct {
 	limit       int64
 	offset      int64
+	cursor      string
 	fields      map[string]utils.Value
 	sort        map[string]SortType
 	null        map[string]bool
 	comparisons map[string]map[CompareOperator]utils.Value
+	or          []urlTerm
+	text        string
+	textColumns []string
+	types       map[string]FieldType
+	columns     map[string]string
+	caseful     bool
+	err         error
 	query       UrlFieldReader
 }
 
+// urlTerm is a comparison read from a param e.g. in or groups
+type urlTerm struct {
+	field string
+	op    CompareOperator
+	value utils.Value
+}
+
 func (s *urlSearch) Load(ctx context.Context, fields ...string) *urlSearch {
 	return s.LoadBoundaries(ctx).
 		LoadFieldSort(ctx, fields...).
 		LoadFieldLookups(ctx, fields...).
-		LoadFieldComparisons(ctx, fields...)
+		LoadFieldComparisons(ctx, fields...).
+		LoadOrGroups(ctx, fields...)
+}
+
+// Types parses the values of fields to their types instead of taking them as strings
+func (s *urlSearch) Types(types map[string]FieldType) *urlSearch {
+	if s.types == nil {
+		s.types = make(map[string]FieldType)
+	}
+	for field, t := range types {
+		s.types[field] = t
+	}
+	return s
+}
+
+// Columns maps fields to the columns they are compared and sorted by
+func (s *urlSearch) Columns(columns map[string]string) *urlSearch {
+	if s.columns == nil {
+		s.columns = make(map[string]string)
+	}
+	for field, column := range columns {
+		s.columns[field] = column
+	}
+	return s
+}
+
+// param reads the param of field formatted by format, or of field camel cased
+func (s *urlSearch) param(ctx context.Context, format, field string) (val utils.Value) {
+	if val = s.query(ctx, fmt.Sprintf(format, field)); !val.Valid() {
+		val = s.query(ctx, fmt.Sprintf(format, strcase.ToCamel(field)))
+	}
+	return
 }
 
 func (s *urlSearch) LoadFieldSort(ctx context.Context, fields ...string) *urlSearch {
@@ -68,7 +185,9 @@ func (s *urlSearch) LoadFieldSort(ctx context.Context, fields ...string) *urlSea
 func (s *urlSearch) LoadBoundaries(ctx context.Context) *urlSearch {
 	s.limit = s.query(ctx, "limit", fmt.Sprint(SELECT_LIMIT)).Int64()
 	s.offset = s.query(ctx, "offset", "0").Int64()
-	if s.offset == 0 {
+	if s.cursor = s.query(ctx, "cursor").String(); s.cursor != "" {
+		s.offset = 0
+	} else if s.offset == 0 {
 		if page := s.query(ctx, "page", "0").Int64(); page > 1 {
 			s.offset = (page - 1) * s.limit
 		}
@@ -92,6 +211,10 @@ func (s *urlSearch) LoadFieldLookups(ctx context.Context, fields ...string) *url
 	return s
 }
 
+// LoadFieldComparisons reads the comparisons of fields: <field>_null=true|false,
+// <field>_<gt|gte|lt|lte|not|neq|ieq>=<value>, <field>_in=<value>,<value>,...,
+// <field>_between=<from>,<to> and like comparisons ~<field>, ~<field>~ and <field>~, which
+// ignore case unless the search has case=sensitive
 func (s *urlSearch) LoadFieldComparisons(ctx context.Context, fields ...string) *urlSearch {
 	if len(fields) == 0 {
 		return s
@@ -102,30 +225,30 @@ func (s *urlSearch) LoadFieldComparisons(ctx context.Context, fields ...string)
 	if s.null == nil {
 		s.null = make(map[string]bool)
 	}
+	s.caseful = strings.EqualFold(s.query(ctx, "case").String(), "sensitive")
 	for i := range fields {
-		if val := s.query(ctx, fmt.Sprintf("%s_null", fields[i])); val.Valid() {
+		if val := s.param(ctx, "%s_null", fields[i]); val.Valid() {
 			s.null[fields[i]] = val.Bool()
-		} else if val = s.query(ctx, fmt.Sprintf("%s_null", strcase.ToCamel(fields[i]))); val.Valid() {
-			s.fields[fields[i]] = val
 		}
 		if _, ok := s.comparisons[fields[i]]; !ok {
 			s.comparisons[fields[i]] = make(map[CompareOperator]utils.Value)
 		}
-		for _, cp := range []CompareOperator{
-			CompareGreaterThan, CompareGreaterThanOrEqual, CompareLessThan, CompareGreaterThanOrEqual, CompareNot, CompareNotEqual} {
-			if val := s.query(ctx, fmt.Sprintf("%s_%s", fields[i], string(cp))); val.Valid() {
+		for _, cp := range urlComparisons {
+			if val := s.param(ctx, "%s_"+string(cp), fields[i]); val.Valid() {
 				s.comparisons[fields[i]][cp] = val
-			} else if val = s.query(ctx, fmt.Sprintf("%s_%s", strcase.ToCamel(fields[i]), string(cp))); val.Valid() {
-				s.fields[fields[i]] = val
 			}
 		}
-		for _, field := range object.String(fields[i]).Variations("~%s", "~%s~", "%s~") {
-			var val utils.Value
-			if val = s.query(ctx, field); !val.Valid() {
-				val = s.query(ctx, strcase.ToCamel(field))
+		if val := s.param(ctx, "%s_between", fields[i]); val.Valid() {
+			if from, to, ok := strings.Cut(val.String(), ","); ok {
+				s.comparisons[fields[i]][CompareGreaterThanOrEqual] = utils.Value(from)
+				s.comparisons[fields[i]][CompareLessThanOrEqual] = utils.Value(to)
+			} else {
+				s.fail(fmt.Errorf("%w: %s_between takes <from>,<to>", ErrInvalidSearch, fields[i]))
 			}
-			if val.Valid() {
-				s.comparisons[fields[i]][CompareLike] = utils.Value(strings.Replace(strings.ReplaceAll(field, "~", "%"), fields[i], val.String(), 1))
+		}
+		for _, format := range likeFormats {
+			if val := s.param(ctx, format, fields[i]); val.Valid() {
+				s.comparisons[fields[i]][s.likeOperator()] = likePattern(format, val)
 				break
 			}
 		}
@@ -133,8 +256,165 @@ func (s *urlSearch) LoadFieldComparisons(ctx context.Context, fields ...string)
 	return s
 }
 
+// LoadOrGroups reads or=<param>:<value>|<param>:<value>|..., matching rows meeting any of
+// the params, which are written as for the lookups and comparisons of fields e.g.
+// or=name~:jo|email~:jo
+func (s *urlSearch) LoadOrGroups(ctx context.Context, fields ...string) *urlSearch {
+	val := s.query(ctx, "or")
+	if len(fields) == 0 || !val.Valid() {
+		return s
+	}
+	for _, item := range strings.Split(val.String(), "|") {
+		param, value, _ := strings.Cut(item, ":")
+		term, ok := s.termOf(param, utils.Value(value), fields)
+		if !ok {
+			s.fail(fmt.Errorf("%w: or param %s is not a search of %s", ErrInvalidSearch, param, strings.Join(fields, ", ")))
+			return s
+		}
+		s.or = append(s.or, term)
+	}
+	return s
+}
+
+// LoadTextSearch reads q=<words>, searching columns for them. see TextSearch
+func (s *urlSearch) LoadTextSearch(ctx context.Context, columns ...string) *urlSearch {
+	if len(columns) == 0 {
+		return s
+	}
+	if val := s.query(ctx, "q"); val.Valid() {
+		s.text, s.textColumns = strings.TrimSpace(val.String()), columns
+	}
+	return s
+}
+
+func (s *urlSearch) termOf(param string, value utils.Value, fields []string) (urlTerm, bool) {
+	for _, field := range fields {
+		switch param {
+		case field, strcase.ToCamel(field):
+			return urlTerm{field: field, op: CompareEqual, value: value}, true
+		case field + "_null", strcase.ToCamel(field) + "_null":
+			return urlTerm{field: field, op: CompareNil, value: value}, true
+		}
+		for _, cp := range urlComparisons {
+			if param == field+"_"+string(cp) || param == strcase.ToCamel(field)+"_"+string(cp) {
+				return urlTerm{field: field, op: cp, value: value}, true
+			}
+		}
+		for _, format := range likeFormats {
+			if param == fmt.Sprintf(format, field) || param == fmt.Sprintf(format, strcase.ToCamel(field)) {
+				return urlTerm{field: field, op: s.likeOperator(), value: likePattern(format, value)}, true
+			}
+		}
+	}
+	return urlTerm{}, false
+}
+
+func (s *urlSearch) likeOperator() CompareOperator {
+	if s.caseful {
+		return CompareLikeCase
+	}
+	return CompareLike
+}
+
+// likePattern turns the ~ of like params into wildcards around val e.g. ~%s~ to %val%
+func likePattern(format string, val utils.Value) utils.Value {
+	return utils.Value(strings.Replace(strings.ReplaceAll(format, "~", "%"), "%s", val.String(), 1))
+}
+
+func (s *urlSearch) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// GetConditions are the conditions of the search, with values parsed to the types of their
+// fields. values failing to parse are left out and reported by the error
+func (r *urlSearch) GetConditions() (out []Condition, err error) {
+	err = r.err
+	add := func(t urlTerm) {
+		if c, e := r.condition(t); e != nil && err == nil {
+			err = e
+		} else if e == nil {
+			out = append(out, c)
+		}
+	}
+	for _, field := range sortedKeys(r.fields) {
+		add(urlTerm{field: field, op: CompareEqual, value: r.fields[field]})
+	}
+	for _, field := range sortedKeys(r.null) {
+		add(urlTerm{field: field, op: CompareNil, value: utils.Value(strconv.FormatBool(r.null[field]))})
+	}
+	for _, field := range sortedKeys(r.comparisons) {
+		for _, op := range sortedKeys(r.comparisons[field]) {
+			add(urlTerm{field: field, op: op, value: r.comparisons[field][op]})
+		}
+	}
+	if len(r.or) > 0 {
+		var group []Condition
+		for _, t := range r.or {
+			if c, e := r.condition(t); e != nil && err == nil {
+				err = e
+			} else if e == nil {
+				group = append(group, c)
+			}
+		}
+		out = append(out, Or(group...))
+	}
+	if r.text != "" {
+		out = append(out, TextSearch(r.text, r.textColumns...))
+	}
+	return
+}
+
+func (r *urlSearch) GetFieldColumn(field string) string {
+	if column, ok := r.columns[field]; ok {
+		return column
+	}
+	return field
+}
+
+func (r *urlSearch) condition(t urlTerm) (Condition, error) {
+	field := r.GetFieldColumn(t.field)
+	switch t.op {
+	case CompareNil:
+		null, err := strconv.ParseBool(strings.TrimSpace(t.value.String()))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s_null %q is not a valid bool", ErrInvalidSearch, t.field, t.value)
+		} else if null {
+			return Null(field), nil
+		}
+		return NotNull(field), nil
+	case CompareLike, CompareLikeCase, CompareEqualFold:
+		return doLeafCompare(t.op, field, t.value.String()), nil
+	case CompareIn:
+		values := make([]interface{}, 0)
+		for _, v := range t.value.StringList(",") {
+			val, err := r.types[t.field].Parse(utils.Value(v))
+			if err != nil {
+				return nil, errors.WithMessage(err, t.field)
+			}
+			values = append(values, val)
+		}
+		return In(field, values...), nil
+	}
+	val, err := r.types[t.field].Parse(t.value)
+	if err != nil {
+		return nil, errors.WithMessage(err, t.field)
+	}
+	return doLeafCompare(t.op, field, val), nil
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	out := make([]K, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
 func (r *urlSearch) GetFieldNullables() (out map[string]bool) {
-	if len(r.fields) == 0 {
+	if len(r.null) == 0 {
 		return map[string]bool{}
 	}
 	return r.null
@@ -181,12 +461,16 @@ func (r *urlSearch) GetLimit() int64 {
 	return r.limit
 }
 
+func (r *urlSearch) GetCursor() string {
+	return r.cursor
+}
+
 func (r *urlSearch) GetOffset() int64 {
 	return r.offset
 }
 
 func (r *urlSearch) HasFieldParams() bool {
-	return len(r.fields)+len(r.sort)+len(r.comparisons) > 0
+	return len(r.fields)+len(r.sort)+len(r.comparisons)+len(r.or) > 0 || r.text != ""
 }
 
 func WithField(param URLSearchParam, field string, val utils.Value) URLSearchParam {
@@ -0,0 +1,87 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/utils"
+)
+
+// urlParams reads search params from a map as a request query would
+func urlParams(params map[string]string) query.UrlFieldReader {
+	return func(_ context.Context, name string, _default ...string) utils.Value {
+		if val, ok := params[name]; ok {
+			return utils.Value(val)
+		} else if len(_default) > 0 {
+			return utils.Value(_default[0])
+		}
+		return ""
+	}
+}
+
+var bookSearch = query.UrlFields{
+	Comparable: []string{"title", "author", "pages"},
+	Lookup:     []string{"id"},
+	Text:       []string{"title", "author"},
+	Types:      map[string]query.FieldType{"id": query.FieldInt, "pages": query.FieldInt},
+}
+
+func TestUrlSearchGrammar(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	for name, tc := range map[string]struct {
+		params map[string]string
+		ids    []int64
+	}{
+		"in":              {map[string]string{"pages_in": "200,400,600"}, []int64{2, 4, 6}},
+		"between":         {map[string]string{"pages_between": "300,500"}, []int64{3, 4, 5}},
+		"less or equal":   {map[string]string{"pages_lte": "200"}, []int64{1, 2}},
+		"equal fold":      {map[string]string{"title_ieq": "BOOK 3"}, []int64{3}},
+		"typed lookup":    {map[string]string{"id": "7"}, []int64{7}},
+		"or group":        {map[string]string{"or": "author~:ngu|pages_gt:900"}, []int64{1, 3, 5, 7, 9, 10}},
+		"or with filters": {map[string]string{"or": "id:2|id:3", "pages_gte": "300"}, []int64{3}},
+		"text search":     {map[string]string{"q": "Book 1"}, []int64{1, 10}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			search := bookSearch.SearchParams(ctx, urlParams(tc.params))
+			_, out, err := query.SQLBuilder(db, scanBook).FromUrlParams(search).Order(query.Asc("id")).Select(ctx, "books")
+			require.Nil(t, err)
+			assert.Equal(t, tc.ids, ids(out))
+		})
+	}
+}
+
+func TestUrlSearchErrors(t *testing.T) {
+	ctx := context.Background()
+	db := booksDB(t)
+	for name, params := range map[string]map[string]string{
+		"typed value":      {"pages_gt": "many"},
+		"typed list value": {"pages_in": "100,many"},
+		"between":          {"pages_between": "100"},
+		"or param":         {"or": "isbn:1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			search := bookSearch.SearchParams(ctx, urlParams(params))
+			_, _, err := query.SQLBuilder(db, scanBook).FromUrlParams(search).Select(ctx, "books")
+			assert.ErrorIs(t, err, query.ErrInvalidSearch)
+			_, _, err = query.SQLBuilder(db, scanBook).Where(query.UrlFieldParams(search)).Select(ctx, "books")
+			assert.ErrorIs(t, err, query.ErrInvalidSearch)
+			qb := query.SQLBuilder[any](nil, nil).FromUrlParams(search)
+			criteria, _ := qb.Criteria()
+			assert.Empty(t, criteria.String())
+			assert.ErrorIs(t, qb.Err(), query.ErrInvalidSearch)
+		})
+	}
+}
+
+func TestUrlSearchCase(t *testing.T) {
+	ctx := context.Background()
+	search := bookSearch.SearchParams(ctx, urlParams(map[string]string{"~title~": "Fall", "case": "sensitive", "q": "things"}))
+	criteria, args := query.SQLBuilder[any](nil, nil).Dialect(query.Postgres).FromUrlParams(search).Criteria()
+	assert.Equal(t, "(title like $1) and (to_tsvector('simple', concat_ws(' ', title, author)) @@ plainto_tsquery('simple', $2))", criteria.String())
+	assert.Equal(t, []interface{}{"%Fall%", "things"}, args)
+}
@@ -8,7 +8,8 @@ import (
 
 type UrlFields struct {
 	// Comparable are fields that are used as filter criteria compared with a like query
-	// e.g name_null=true, age_gt=24, name~=joan > joan,joanita,...
+	// e.g name_null=true, age_gt=24, age_in=24,25, age_between=18,24, name~=joan > joan,joanita,...
+	// and in or groups with lookups e.g. or=name~:jo|email~:jo
 	// fields in this case are [name, age]
 	Comparable collections.List[string]
 
@@ -21,10 +22,22 @@ type UrlFields struct {
 	// e.g. sort_name=asc
 	// fields in this case are [name]
 	Sort collections.List[string]
+
+	// Text are the columns searched for the words of q with full text search
+	// e.g. q=things fall apart
+	Text collections.List[string]
+
+	// Types are the types the values of fields are parsed to, strings when not given
+	// e.g. {"age": FieldInt} for age_gt=24
+	Types map[string]FieldType
+
+	// Columns are the columns of fields named differently in urls
+	// e.g. {"createdAt": "created_at"} for sort_createdAt=desc
+	Columns map[string]string
 }
 
 func (u *UrlFields) SearchParams(ctx context.Context, queryReader UrlFieldReader) (out URLSearchParam) {
-	search := SearchUrl(queryReader)
+	search := SearchUrl(queryReader).Types(u.Types).Columns(u.Columns)
 	if len(u.Comparable) > 0 {
 		search.LoadFieldComparisons(ctx, u.Comparable...)
 	}
@@ -34,5 +47,11 @@ func (u *UrlFields) SearchParams(ctx context.Context, queryReader UrlFieldReader
 	if len(u.Sort) > 0 {
 		search.LoadFieldSort(ctx, u.Sort...)
 	}
+	if len(u.Comparable)+len(u.Lookup) > 0 {
+		search.LoadOrGroups(ctx, append(append([]string{}, u.Comparable...), u.Lookup...)...)
+	}
+	if len(u.Text) > 0 {
+		search.LoadTextSearch(ctx, u.Text...)
+	}
 	return search.LoadBoundaries(ctx)
 }
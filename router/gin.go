@@ -0,0 +1,58 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ginContext struct {
+	*gin.Context
+}
+
+var _ Context = ginContext{}
+
+// Gin adapts a *gin.Context
+func Gin(c *gin.Context) Context {
+	return ginContext{c}
+}
+
+func (c ginContext) Request() *http.Request { return c.Context.Request }
+
+func (c ginContext) Writer() http.ResponseWriter { return c.Context.Writer }
+
+func (c ginContext) Error(err error) error { return c.Context.Error(err) }
+
+// Gin converts h into a gin handler
+func (h HandlerFunc) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) { h(Gin(c)) }
+}
+
+func ginHandlers(handlers []HandlerFunc) (out []gin.HandlerFunc) {
+	out = make([]gin.HandlerFunc, len(handlers))
+	for i := range handlers {
+		out[i] = handlers[i].Gin()
+	}
+	return
+}
+
+type ginEngine struct {
+	router gin.IRouter
+}
+
+// GinEngine registers routes on a gin engine or router group
+func GinEngine(router gin.IRouter) Engine {
+	return &ginEngine{router}
+}
+
+func (e *ginEngine) Use(handlers ...HandlerFunc) {
+	e.router.Use(ginHandlers(handlers)...)
+}
+
+func (e *ginEngine) Handle(method, path string, handlers ...HandlerFunc) {
+	e.router.Handle(method, path, ginHandlers(handlers)...)
+}
+
+func (e *ginEngine) Group(prefix string, handlers ...HandlerFunc) Engine {
+	return GinEngine(e.router.Group(prefix, ginHandlers(handlers)...))
+}
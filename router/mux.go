@@ -0,0 +1,268 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const abortIndex = 1 << 30
+
+// mux serves routes with a net/http ServeMux
+type mux struct {
+	serve    *http.ServeMux
+	prefix   string
+	handlers []HandlerFunc
+}
+
+// NewMux returns an Engine backed by a http.ServeMux, which it also serves as a http.Handler
+func NewMux() *mux {
+	return &mux{serve: http.NewServeMux(), prefix: "/"}
+}
+
+func (m *mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.serve.ServeHTTP(w, r)
+}
+
+// Use adds middleware to routes registered afterwards
+func (m *mux) Use(handlers ...HandlerFunc) {
+	m.handlers = append(m.handlers, handlers...)
+}
+
+func (m *mux) Handle(method, path string, handlers ...HandlerFunc) {
+	chain := append(append([]HandlerFunc{}, m.handlers...), handlers...)
+	pattern := muxPattern(joinPaths(m.prefix, path))
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+	m.serve.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		c := &httpContext{writer: &responseWriter{ResponseWriter: w, status: http.StatusOK}, request: r, handlers: chain, index: -1}
+		c.Next()
+		c.writer.writeHeaderNow()
+	})
+}
+
+func (m *mux) Group(prefix string, handlers ...HandlerFunc) Engine {
+	return &mux{serve: m.serve, prefix: joinPaths(m.prefix, prefix), handlers: append(append([]HandlerFunc{}, m.handlers...), handlers...)}
+}
+
+func (m *mux) GET(path string, handlers ...HandlerFunc) {
+	m.Handle(http.MethodGet, path, handlers...)
+}
+
+func (m *mux) POST(path string, handlers ...HandlerFunc) {
+	m.Handle(http.MethodPost, path, handlers...)
+}
+
+func (m *mux) PUT(path string, handlers ...HandlerFunc) {
+	m.Handle(http.MethodPut, path, handlers...)
+}
+
+func (m *mux) PATCH(path string, handlers ...HandlerFunc) {
+	m.Handle(http.MethodPatch, path, handlers...)
+}
+
+func (m *mux) DELETE(path string, handlers ...HandlerFunc) {
+	m.Handle(http.MethodDelete, path, handlers...)
+}
+
+func joinPaths(prefix, relative string) string {
+	if relative == "" {
+		return prefix
+	}
+	out := path.Join(prefix, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(out, "/") {
+		out += "/"
+	}
+	return out
+}
+
+// muxPattern converts gin path syntax into a ServeMux pattern. /books/:id becomes
+// /books/{id} and /files/*path becomes /files/{path...}. paths ending in / only
+// match themselves as they do with gin
+func muxPattern(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		} else if strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "...}"
+		}
+	}
+	out := strings.Join(segments, "/")
+	if strings.HasSuffix(out, "/") {
+		out += "{$}"
+	}
+	return out
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+// WriteHeader only records the status. it is sent with the first write to the body
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) writeHeaderNow() {
+	if !w.written {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.writeHeaderNow()
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush sends the status and buffered body to the client e.g. while streaming responses
+func (w *responseWriter) Flush() {
+	w.writeHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type requestError struct {
+	error
+}
+
+func (e requestError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"error": e.Error()})
+}
+
+// httpContext runs a chain of handlers for a request served by mux
+type httpContext struct {
+	writer   *responseWriter
+	request  *http.Request
+	query    url.Values
+	handlers []HandlerFunc
+	index    int
+
+	mx     sync.RWMutex
+	keys   map[string]any
+	errors []error
+}
+
+var _ Context = (*httpContext)(nil)
+
+func (c *httpContext) Deadline() (time.Time, bool) { return c.request.Context().Deadline() }
+
+func (c *httpContext) Done() <-chan struct{} { return c.request.Context().Done() }
+
+func (c *httpContext) Err() error { return c.request.Context().Err() }
+
+// Value returns values set on the context for string keys, falling back to the request context
+func (c *httpContext) Value(key any) any {
+	if key == ContextKey {
+		return c
+	} else if k, ok := key.(string); ok {
+		if val, exists := c.Get(k); exists {
+			return val
+		}
+	}
+	return c.request.Context().Value(key)
+}
+
+func (c *httpContext) Request() *http.Request { return c.request }
+
+func (c *httpContext) Writer() http.ResponseWriter { return c.writer }
+
+func (c *httpContext) Param(name string) string { return c.request.PathValue(name) }
+
+func (c *httpContext) Query(name string) string {
+	if c.query == nil {
+		c.query = c.request.URL.Query()
+	}
+	return c.query.Get(name)
+}
+
+func (c *httpContext) GetHeader(name string) string { return c.request.Header.Get(name) }
+
+func (c *httpContext) ShouldBindJSON(out any) error {
+	if c.request.Body == nil || c.request.Body == http.NoBody {
+		return errors.New("invalid request")
+	}
+	return json.NewDecoder(c.request.Body).Decode(out)
+}
+
+func (c *httpContext) Set(key string, value any) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]any)
+	}
+	c.keys[key] = value
+}
+
+func (c *httpContext) Get(key string) (value any, exists bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	value, exists = c.keys[key]
+	return
+}
+
+func (c *httpContext) Header(key, value string) {
+	if value == "" {
+		c.writer.Header().Del(key)
+		return
+	}
+	c.writer.Header().Set(key, value)
+}
+
+func (c *httpContext) Status(code int) { c.writer.WriteHeader(code) }
+
+func (c *httpContext) JSON(code int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to encode %T", body))
+		c.Data(http.StatusInternalServerError, "text/plain; charset=utf-8", []byte(http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+	c.Data(code, "application/json; charset=utf-8", data)
+}
+
+func (c *httpContext) Data(code int, contentType string, data []byte) {
+	c.Status(code)
+	c.writer.Header().Set("Content-Type", contentType)
+	c.writer.Write(data)
+}
+
+func (c *httpContext) Error(err error) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.errors = append(c.errors, err)
+	return requestError{err}
+}
+
+// Next runs the remaining handlers in the chain
+func (c *httpContext) Next() {
+	for c.index++; c.index < len(c.handlers); c.index++ {
+		c.handlers[c.index](c)
+	}
+}
+
+func (c *httpContext) Abort() { c.index = abortIndex }
+
+func (c *httpContext) AbortWithStatusJSON(code int, body any) {
+	c.Abort()
+	c.JSON(code, body)
+}
+
+func (c *httpContext) IsAborted() bool { return c.index >= abortIndex }
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the key that a Context created by this package returns itself for
+const ContextKey = "gostart/router/context"
+
+// Context is the request context handlers are written against. method names and
+// semantics follow gin so that a *gin.Context can be adapted without surprises
+type Context interface {
+	context.Context
+
+	Request() *http.Request
+	Writer() http.ResponseWriter
+
+	// Param returns the value of a path parameter e.g. id in /books/:id
+	Param(name string) string
+	Query(name string) string
+	GetHeader(name string) string
+	ShouldBindJSON(out any) error
+
+	Set(key string, value any)
+	Get(key string) (value any, exists bool)
+
+	// Header sets a response header. an empty value removes it
+	Header(key, value string)
+	Status(code int)
+	JSON(code int, body any)
+	Data(code int, contentType string, data []byte)
+	// Error records err against the request and returns it in a form that can be rendered as json
+	Error(err error) error
+
+	Next()
+	Abort()
+	AbortWithStatusJSON(code int, body any)
+	IsAborted() bool
+}
+
+type HandlerFunc func(Context)
+
+// Engine registers routes on an underlying router. paths use the gin syntax
+// i.e. /books/:id and /files/*path whatever the router
+type Engine interface {
+	Use(handlers ...HandlerFunc)
+	Handle(method, path string, handlers ...HandlerFunc)
+	Group(prefix string, handlers ...HandlerFunc) Engine
+}
+
+// FromContext returns the router context ctx belongs to. ctx may be a Context, a
+// *gin.Context or any context derived from either
+func FromContext(ctx context.Context) (Context, bool) {
+	switch c := ctx.(type) {
+	case nil:
+		return nil, false
+	case Context:
+		return c, true
+	case *gin.Context:
+		return Gin(c), true
+	}
+	if c, ok := ctx.Value(ContextKey).(Context); ok {
+		return c, true
+	} else if c, ok := ctx.Value(gin.ContextKey).(*gin.Context); ok {
+		return Gin(c), true
+	}
+	return nil, false
+}
+
+// Unwrap returns the native context of the router c runs on, i.e. the *gin.Context
+// on gin, so that code casting the context to its router type keeps working
+func Unwrap(c Context) context.Context {
+	if g, ok := c.(ginContext); ok {
+		return g.Context
+	}
+	return c
+}
@@ -0,0 +1,125 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kod2ulz/gostart/router"
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func setRoutes(e router.Engine) {
+	e.Use(func(c router.Context) {
+		if c.GetHeader("X-Block") != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, map[string]string{"error": "blocked"})
+			return
+		}
+		c.Set("seen", true)
+		c.Next()
+	})
+	items := e.Group("/items")
+	items.Handle(http.MethodGet, "/:id", func(c router.Context) {
+		seen, _ := c.Get("seen")
+		c.JSON(http.StatusOK, map[string]any{"id": c.Param("id"), "q": c.Query("q"), "seen": seen, "value": valueOf(c)})
+	})
+	items.Handle(http.MethodPost, "", func(c router.Context) {
+		var in item
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, c.Error(err))
+			return
+		}
+		c.JSON(http.StatusCreated, in)
+	})
+	e.Handle(http.MethodGet, "/files/*path", func(c router.Context) {
+		c.Data(http.StatusOK, "text/plain", []byte(strings.TrimPrefix(c.Param("path"), "/")))
+	})
+}
+
+// valueOf reads the middleware value through a derived context as services do
+func valueOf(c router.Context) any {
+	ctx := context.WithValue(c, struct{}{}, 1)
+	r, ok := router.FromContext(ctx)
+	Expect(ok).To(BeTrue())
+	val, _ := r.Get("seen")
+	return val
+}
+
+var _ = Describe("Router", func() {
+	engines := map[string]func() http.Handler{
+		"gin": func() http.Handler {
+			return utils.Test.GinRouter(func(e *gin.Engine) { setRoutes(router.GinEngine(e)) })
+		},
+		"mux": func() http.Handler {
+			m := router.NewMux()
+			setRoutes(m)
+			return m
+		},
+	}
+
+	for name, engine := range engines {
+		name, engine := name, engine
+
+		Context(name, func() {
+			var handler http.Handler
+			var recorder *httptest.ResponseRecorder
+
+			BeforeEach(func() {
+				handler, recorder = engine(), httptest.NewRecorder()
+			})
+
+			It("reads path and query parameters and values set by middleware", func() {
+				var out map[string]any
+				handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/items/42?q=books", nil))
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &out)).To(Succeed())
+				Expect(out).To(Equal(map[string]any{"id": "42", "q": "books", "seen": true, "value": true}))
+				Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+			})
+
+			It("binds json bodies", func() {
+				var out item
+				handler.ServeHTTP(recorder, utils.Test.Request(http.MethodPost, "/items", utils.Test.JsonDataOf("name", "pen")))
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &out)).To(Succeed())
+				Expect(out.Name).To(Equal("pen"))
+			})
+
+			It("renders binding errors", func() {
+				var out map[string]any
+				handler.ServeHTTP(recorder, utils.Test.Request(http.MethodPost, "/items", []byte("{")))
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &out)).To(Succeed())
+				Expect(out).To(HaveKey("error"))
+			})
+
+			It("stops the chain when a handler aborts", func() {
+				req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+				req.Header.Set("X-Block", "1")
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				Expect(recorder.Body.String()).To(ContainSubstring("blocked"))
+			})
+
+			It("matches catch-all parameters", func() {
+				handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/files/a/b.txt", nil))
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(Equal("a/b.txt"))
+			})
+		})
+	}
+
+	It("does not find a router outside of a request", func() {
+		_, ok := router.FromContext(context.Background())
+		Expect(ok).To(BeFalse())
+	})
+})
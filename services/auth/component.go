@@ -0,0 +1,13 @@
+package auth
+
+import (
+	"github.com/kod2ulz/gostart/app"
+)
+
+// ProvideSessionService registers a session service over store as a component of a and
+// authenticates the routes a mounts as api.Authenticated with it
+func ProvideSessionService[ID comparable, U SessionUser[ID]](a *app.App, store SessionStore[ID, U], opts ...ServiceInitFunc[ID, U]) *GenericSessionService[ID, U] {
+	out := app.Provide(a, SessionService(a.Log(), store, opts...))
+	a.Routes().WithAuthenticator(out.Authenticator())
+	return out
+}
@@ -0,0 +1,36 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kod2ulz/gostart/api"
+	"github.com/kod2ulz/gostart/app"
+	"github.com/kod2ulz/gostart/services/auth"
+)
+
+type securedRoutes []api.Route
+
+func (r securedRoutes) Routes() []api.Route { return r }
+
+var _ = Describe("Session Service component", func() {
+
+	It("is resolved from the app and authenticates its routes", func() {
+		a := app.New()
+		service := auth.ProvideSessionService(a, auth.InMemoryUserStore())
+		Expect(app.MustResolve[*auth.GenericSessionService[uuid.UUID, auth.UserData]](a)).To(BeIdenticalTo(service))
+
+		Expect(a.Mount(securedRoutes{
+			api.BasicRoute(http.MethodGet, "/me", func(context.Context) (string, api.Error) { return "me", nil }, api.Authenticated()),
+		})).To(Succeed())
+		rec := httptest.NewRecorder()
+		a.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/me", nil))
+		Expect(rec.Code).To(BeNumerically(">=", http.StatusBadRequest), "missing token")
+		Expect(rec.Body.String()).NotTo(ContainSubstring(`"me"`))
+	})
+})
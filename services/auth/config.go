@@ -11,26 +11,21 @@ import (
 )
 
 type TokenConfig struct {
-	AccessTimeout  time.Duration
-	RefreshTimeout time.Duration
-	Issuer         string
-	ClientID       string
-	ClientSecret   string
-	SigningKeySeed string
+	AccessTimeout  time.Duration `env:"ACCESS_TIMEOUT" default:"60m"`
+	RefreshTimeout time.Duration `env:"REFRESH_TIMEOUT" default:"24h"`
+	Issuer         string        `env:"ISSUER"`
+	ClientID       string        `env:"CLIENT_ID" default:"TQcXMsCGc3RaMlHiUfiF"`
+	ClientSecret   string        `env:"CLIENT_SECRET" default:"MHz7SszY1ujSFp9TFMNU"`
+	SigningKeySeed string        `env:"SIGNING_KEY"`
 	SigningKey     []byte
-	Audience       []string
+	Audience       []string `env:"AUDIENCE" default:"http://localhost,api_client"`
 }
 
 func InitTokenConfig(prefix ...string) (out *TokenConfig) {
-	env := utils.Env.Helper(prefix...).OrDefault("TOKEN")
-	out = &TokenConfig{
-		AccessTimeout:  env.Get("ACCESS_TIMEOUT", "60m").Duration(),
-		Issuer:         env.Get("ISSUER", fmt.Sprintf("http://%s", utils.Env.GetHost())).String(),
-		ClientID:       env.Get("CLIENT_ID", "TQcXMsCGc3RaMlHiUfiF").String(),
-		ClientSecret:   env.Get("CLIENT_SECRET", "MHz7SszY1ujSFp9TFMNU").String(),
-		SigningKeySeed: env.Get("SIGNING_KEY", "").String(),
-		RefreshTimeout: env.Get("REFRESH_TIMEOUT", "24h").Duration(),
-		Audience:       env.Get("AUDIENCE", "http://localhost,api_client").StringList(","),
+	out = &TokenConfig{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).OrDefault("TOKEN").Prefix(), out)
+	if out.Issuer == "" {
+		out.Issuer = fmt.Sprintf("http://%s", utils.Env.GetHost())
 	}
 	if out.SigningKeySeed == "" {
 		out.SigningKeySeed = fmt.Sprintf("%s%s%s", out.Issuer, out.ClientID, out.ClientSecret)
@@ -4,7 +4,6 @@ import (
 	"context"
 	"strings"
 
-	"github.com/gin-gonic/gin"
 	"github.com/kod2ulz/gostart/api"
 	"github.com/kod2ulz/gostart/object"
 	"github.com/pkg/errors"
@@ -51,21 +50,21 @@ type VerifyTokenRequest struct {
 
 func (r VerifyTokenRequest) RequestLoad(ctx context.Context) (param api.RequestParam, err error) {
 	var out VerifyTokenRequest 
-	authHeader := ctx.(*gin.Context).Request.Header.Get("Authorization")
+	authHeader := r.Authorization(ctx)
 	if authHeader != "" {
 		switch authType := object.String(authHeader).Split(" ").First(); authType {
 		case TokenTypeBearer:
 			out.Token = strings.TrimPrefix(authHeader, TokenTypeBearer+" ")
-			ctx.(*gin.Context).Set(out.ContextKey(), out)
+			out.SetInRequest(ctx, out)
 			return out, nil
 			// todo: process other auth token types
 		}
 	} else if out.Token = r.Query(ctx, "token").String(); out.Token != "" {
-		ctx.(*gin.Context).Set(out.ContextKey(), out)
+		out.SetInRequest(ctx, out)
 		return out, nil
 	} else if err = out.LoadFromJsonBody(ctx, &out); err == nil && out.Token != "" {
-		ctx.(*gin.Context).Set(out.ContextKey(), out)
-		return out, err
+		out.SetInRequest(ctx, out)
+		return out, nil
 	}
 	return nil, errors.New("token missing in request")
 }
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/kod2ulz/gostart/api"
 	"github.com/kod2ulz/gostart/logr"
+	"github.com/kod2ulz/gostart/router"
 	"github.com/pkg/errors"
 )
 
@@ -68,11 +70,24 @@ func (s *GenericSessionService[ID, U]) Auther() gin.HandlerFunc {
 	return api.WithUser[VerifyTokenRequest, User, TokenResponse](s)
 }
 
-func (s *GenericSessionService[ID, U]) API(router *gin.RouterGroup) {
-	router.
-		POST("/login", api.ParamHandlerWithResponse[LoginRequest](s.Login)).
-		POST("/verify", api.ParamHandlerWithResponse[VerifyTokenRequest](s.Verify)).
-		POST("/refresh", api.ParamHandlerWithResponse[RefreshRequest](s.Refresh))
+// Authenticator loads the user of routes requiring authentication from their bearer token
+func (s *GenericSessionService[ID, U]) Authenticator() router.HandlerFunc {
+	return api.WithUserFunc[VerifyTokenRequest, User, TokenResponse](s)
+}
+
+func (s *GenericSessionService[ID, U]) API(group *gin.RouterGroup) {
+	if err := api.NewRegistry().Mount(router.GinEngine(group), s.Routes()...); err != nil {
+		panic(err)
+	}
+}
+
+// Routes implements api.RouteProvider
+func (s *GenericSessionService[ID, U]) Routes() []api.Route {
+	return []api.Route{
+		api.ParamRoute[LoginRequest](http.MethodPost, "/login", s.Login, api.WithSummary("exchange credentials for tokens"), api.WithTags("session"), api.WithRateLimit("auth")),
+		api.ParamRoute[VerifyTokenRequest](http.MethodPost, "/verify", s.Verify, api.WithSummary("verify an access token"), api.WithTags("session")),
+		api.ParamRoute[RefreshRequest](http.MethodPost, "/refresh", s.Refresh, api.WithSummary("refresh an access token"), api.WithTags("session"), api.WithRateLimit("auth")),
+	}
 }
 
 func (s *GenericSessionService[ID, U]) Signup(ctx context.Context) (out U, err api.Error) {
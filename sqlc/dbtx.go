@@ -11,4 +11,12 @@ type DBTX interface {
 	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
 	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
 	QueryRow(context.Context, string, ...interface{}) pgx.Row
-}
\ No newline at end of file
+}
+
+// HealthCheck returns a probe that runs a trivial query against db
+func HealthCheck(db DBTX) func(context.Context) error {
+	return func(ctx context.Context) error {
+		var one int
+		return db.QueryRow(ctx, "select 1").Scan(&one)
+	}
+}
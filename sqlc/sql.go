@@ -0,0 +1,158 @@
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// SQLDB is implemented by the database/sql *sql.DB, *sql.Tx and *sql.Conn
+type SQLDB interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// FromSQL adapts a database/sql handle opened with driver e.g. mysql or sqlite3 to DBTX.
+// missing rows are reported as pgx.ErrNoRows like DBTX on pgx connections do
+func FromSQL(db SQLDB, driver string) *sqlDBTX {
+	return &sqlDBTX{db: db, driver: driver}
+}
+
+type sqlDBTX struct {
+	db     SQLDB
+	driver string
+}
+
+// DriverName names the database/sql driver of the connection, from which query builders
+// pick their dialect
+func (s *sqlDBTX) DriverName() string {
+	return s.driver
+}
+
+func (s *sqlDBTX) Exec(ctx context.Context, query string, args ...interface{}) (tag pgconn.CommandTag, err error) {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	verb := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	if verb == "INSERT" {
+		// postgres tags inserts with an oid before the count of rows
+		verb += " 0"
+	}
+	return pgconn.CommandTag(verb + " " + strconv.FormatInt(affected, 10)), nil
+}
+
+func (s *sqlDBTX) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRows{rows: rows}, nil
+}
+
+func (s *sqlDBTX) QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row {
+	return sqlRow{s.db.QueryRowContext(ctx, query, args...)}
+}
+
+type sqlRow struct {
+	row *sql.Row
+}
+
+func (r sqlRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	return err
+}
+
+// sqlRows is pgx.Rows over *sql.Rows
+type sqlRows struct {
+	rows    *sql.Rows
+	read    int64
+	columns []string
+	err     error
+}
+
+func (r *sqlRows) Close() {
+	r.rows.Close()
+}
+
+func (r *sqlRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rows.Err()
+}
+
+func (r *sqlRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag("SELECT " + strconv.FormatInt(r.read, 10))
+}
+
+// FieldDescriptions names the columns of the rows. their types are unknown
+func (r *sqlRows) FieldDescriptions() (out []pgproto3.FieldDescription) {
+	if r.columns == nil {
+		if r.columns, r.err = r.rows.Columns(); r.err != nil {
+			return
+		}
+	}
+	out = make([]pgproto3.FieldDescription, len(r.columns))
+	for i := range r.columns {
+		out[i] = pgproto3.FieldDescription{Name: []byte(r.columns[i])}
+	}
+	return
+}
+
+func (r *sqlRows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	r.read++
+	return true
+}
+
+func (r *sqlRows) Scan(dest ...interface{}) error {
+	return r.rows.Scan(dest...)
+}
+
+func (r *sqlRows) Values() (out []interface{}, err error) {
+	if len(r.FieldDescriptions()) == 0 && r.err != nil {
+		return nil, r.err
+	}
+	out = make([]interface{}, len(r.columns))
+	dest := make([]interface{}, len(r.columns))
+	for i := range dest {
+		dest[i] = &out[i]
+	}
+	err = r.rows.Scan(dest...)
+	return
+}
+
+func (r *sqlRows) RawValues() (out [][]byte) {
+	values := make([]sql.RawBytes, len(r.FieldDescriptions()))
+	dest := make([]interface{}, len(values))
+	for i := range dest {
+		dest[i] = &values[i]
+	}
+	if r.err = r.rows.Scan(dest...); r.err != nil {
+		return nil
+	}
+	out = make([][]byte, len(values))
+	for i := range values {
+		if values[i] != nil {
+			out[i] = append([]byte{}, values[i]...)
+		}
+	}
+	return
+}
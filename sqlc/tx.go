@@ -0,0 +1,245 @@
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// TxOption configures the transactions of WithTx
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	isolation sql.IsolationLevel
+	readOnly  bool
+	retries   int
+	backoff   time.Duration
+}
+
+// Isolation runs transactions at level instead of the default of the database
+func Isolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) { o.isolation = level }
+}
+
+// ReadOnly runs transactions in read only mode
+func ReadOnly() TxOption {
+	return func(o *txOptions) { o.readOnly = true }
+}
+
+// Retries runs a transaction up to n more times after serialization failures and deadlocks,
+// see Retryable. transactions are retried 3 times by default
+func Retries(n int) TxOption {
+	return func(o *txOptions) { o.retries = n }
+}
+
+// RetryBackoff waits a random duration up to d times the number of attempts so far before
+// retrying a transaction, 20ms by default
+func RetryBackoff(d time.Duration) TxOption {
+	return func(o *txOptions) { o.backoff = d }
+}
+
+// Retryable reports whether err is a serialization failure (SQLSTATE 40001) or deadlock
+// (40P01), after which the transaction may succeed when run again
+func Retryable(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		switch state.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	return false
+}
+
+// NewTxManager runs units of work in transactions of db, a pgx pool or connection or a
+// database/sql handle adapted by FromSQL. opts apply to every transaction of the manager
+func NewTxManager(db DBTX, opts ...TxOption) *txManager {
+	return &txManager{db: db, opts: opts}
+}
+
+type txManager struct {
+	db   DBTX
+	opts []TxOption
+}
+
+// DB is the transaction of ctx when it is in one of the manager, else its database
+func (m *txManager) DB(ctx context.Context) DBTX {
+	if current, ok := ctx.Value(txKey{}).(*txContext); ok && current.manager == m {
+		return current.tx
+	}
+	return DB(ctx, m.db)
+}
+
+// WithTx calls fn in a transaction, committed when fn succeeds and rolled back when it fails
+// or panics. repositories getting their DBTX from ctx with DB join the transaction, and
+// nested calls run in savepoints of it, ignoring opts.
+//
+//	err := tx.WithTx(ctx, func(ctx context.Context) error {
+//		_, err := query.SQLBuilder(sqlc.DB(ctx, db), scanOrder).Insert(ctx, "orders", order)
+//		return err
+//	}, sqlc.Isolation(sql.LevelSerializable))
+func (m *txManager) WithTx(ctx context.Context, fn func(context.Context) error, opts ...TxOption) (err error) {
+	if current, ok := ctx.Value(txKey{}).(*txContext); ok && (current.manager == m || sameDB(current.root, m.db)) {
+		return current.savepoint(ctx, fn)
+	}
+	o := txOptions{retries: 3, backoff: 20 * time.Millisecond}
+	for _, opt := range append(m.opts, opts...) {
+		opt(&o)
+	}
+	for attempt := 1; ; attempt++ {
+		if err = m.run(ctx, o, fn); err == nil || attempt > o.retries || !Retryable(err) {
+			return
+		}
+		wait := time.Duration(0)
+		if o.backoff > 0 {
+			wait = time.Duration(rand.Int63n(int64(o.backoff) * int64(attempt)))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (m *txManager) run(ctx context.Context, o txOptions, fn func(context.Context) error) (err error) {
+	tx, err := begin(ctx, m.db, o)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+	if err = fn(context.WithValue(ctx, txKey{}, &txContext{manager: m, root: m.db, tx: tx})); err != nil {
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
+			err = errors.Join(err, rbErr)
+		}
+		return
+	}
+	return tx.Commit(ctx)
+}
+
+// WithTx calls fn in a transaction of db, see NewTxManager
+func WithTx(ctx context.Context, db DBTX, fn func(context.Context) error, opts ...TxOption) error {
+	return NewTxManager(db).WithTx(ctx, fn, opts...)
+}
+
+// DB is the transaction of ctx when it is in one of db, else db. databases of types that
+// are not comparable e.g. structs holding slices are never matched, their transactions
+// are only found by the DB method of their manager
+func DB(ctx context.Context, db DBTX) DBTX {
+	if current, ok := ctx.Value(txKey{}).(*txContext); ok && sameDB(current.root, db) {
+		return current.tx
+	}
+	return db
+}
+
+// sameDB compares databases without panicking on types that are not comparable
+func sameDB(a, b DBTX) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.IsValid() && vb.IsValid() && va.Type() == vb.Type() && va.Comparable() && vb.Comparable() && va.Equal(vb)
+}
+
+type txKey struct{}
+
+// txContext is the transaction of a context, begun on root by manager. savepoints are
+// counted atomically although transactions cannot be used by concurrent calls anyway
+type txContext struct {
+	manager    *txManager
+	root       DBTX
+	tx         transaction
+	savepoints atomic.Int64
+}
+
+func (t *txContext) savepoint(ctx context.Context, fn func(context.Context) error) (err error) {
+	name := "sp_" + strconv.FormatInt(t.savepoints.Add(1), 10)
+	if _, err = t.tx.Exec(ctx, "savepoint "+name); err != nil {
+		return
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			t.tx.Exec(context.WithoutCancel(ctx), "rollback to savepoint "+name)
+			panic(p)
+		}
+	}()
+	if err = fn(ctx); err != nil {
+		if _, rbErr := t.tx.Exec(context.WithoutCancel(ctx), "rollback to savepoint "+name); rbErr != nil {
+			err = errors.Join(err, rbErr)
+		}
+		return
+	}
+	_, err = t.tx.Exec(ctx, "release savepoint "+name)
+	return
+}
+
+type transaction interface {
+	DBTX
+	Commit(context.Context) error
+	Rollback(context.Context) error
+}
+
+var pgxIsolation = map[sql.IsolationLevel]pgx.TxIsoLevel{
+	sql.LevelDefault:         "",
+	sql.LevelReadUncommitted: pgx.ReadUncommitted,
+	sql.LevelReadCommitted:   pgx.ReadCommitted,
+	sql.LevelRepeatableRead:  pgx.RepeatableRead,
+	sql.LevelSerializable:    pgx.Serializable,
+}
+
+// begin starts a transaction on pgx pools and connections or database/sql handles
+func begin(ctx context.Context, db DBTX, o txOptions) (transaction, error) {
+	switch db := db.(type) {
+	case interface {
+		BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error)
+	}:
+		level, ok := pgxIsolation[o.isolation]
+		if !ok {
+			return nil, fmt.Errorf("isolation level %s is not supported", o.isolation)
+		}
+		opts := pgx.TxOptions{IsoLevel: level}
+		if o.readOnly {
+			opts.AccessMode = pgx.ReadOnly
+		}
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	case *sqlDBTX:
+		if beginner, ok := db.db.(interface {
+			BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+		}); ok {
+			tx, err := beginner.BeginTx(ctx, &sql.TxOptions{Isolation: o.isolation, ReadOnly: o.readOnly})
+			if err != nil {
+				return nil, err
+			}
+			return &sqlTx{sqlDBTX: FromSQL(tx, db.driver), tx: tx}, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot begin transactions on %T", db)
+}
+
+// sqlTx is a transaction of a database/sql handle
+type sqlTx struct {
+	*sqlDBTX
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Commit(context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback(context.Context) error {
+	return t.tx.Rollback()
+}
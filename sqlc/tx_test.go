@@ -0,0 +1,181 @@
+package sqlc_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kod2ulz/gostart/query"
+	"github.com/kod2ulz/gostart/sqlc"
+)
+
+func accountsDB(t *testing.T) sqlc.DBTX {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.Nil(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	dbtx := sqlc.FromSQL(db, "sqlite3")
+	_, err = dbtx.Exec(context.Background(), "create table accounts (name text primary key, balance integer not null)")
+	require.Nil(t, err)
+	return dbtx
+}
+
+func open(ctx context.Context, db sqlc.DBTX, name string, balance int) error {
+	_, err := sqlc.DB(ctx, db).Exec(ctx, "insert into accounts (name, balance) values (?, ?)", name, balance)
+	return err
+}
+
+func scanName(rows pgx.Rows) (out string, err error) {
+	err = rows.Scan(&out)
+	return
+}
+
+func accounts(t *testing.T, db sqlc.DBTX) (out []string) {
+	rows, err := db.Query(context.Background(), "select name from accounts order by name")
+	require.Nil(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		name, err := scanName(rows)
+		require.Nil(t, err)
+		out = append(out, name)
+	}
+	return
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	db := accountsDB(t)
+	tx := sqlc.NewTxManager(db)
+
+	require.Nil(t, tx.WithTx(ctx, func(ctx context.Context) error {
+		require.Nil(t, open(ctx, db, "amina", 100))
+		// the builder joins the transaction and keeps the dialect of the database
+		_, names, err := query.SQLBuilder(tx.DB(ctx), scanName).Where(query.GreaterThan("balance", 0)).Select(ctx, "accounts", "name")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"amina"}, names)
+		return nil
+	}))
+
+	failed := errors.New("insufficient funds")
+	err := tx.WithTx(ctx, func(ctx context.Context) error {
+		require.Nil(t, open(ctx, db, "baraka", 50))
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	assert.Panics(t, func() {
+		tx.WithTx(ctx, func(ctx context.Context) error {
+			require.Nil(t, open(ctx, db, "chausiku", 50))
+			panic("oops")
+		})
+	})
+	assert.Equal(t, []string{"amina"}, accounts(t, db))
+	assert.Equal(t, db, sqlc.DB(ctx, db), "outside transactions")
+}
+
+func TestWithTxSavepoints(t *testing.T) {
+	ctx := context.Background()
+	db := accountsDB(t)
+	tx := sqlc.NewTxManager(db)
+
+	require.Nil(t, tx.WithTx(ctx, func(ctx context.Context) error {
+		require.Nil(t, open(ctx, db, "amina", 100))
+		err := tx.WithTx(ctx, func(ctx context.Context) error {
+			require.Nil(t, open(ctx, db, "baraka", 50))
+			return open(ctx, db, "amina", 10)
+		})
+		assert.NotNil(t, err, "duplicate account")
+		return tx.WithTx(ctx, func(ctx context.Context) error {
+			return open(ctx, db, "chausiku", 50)
+		})
+	}))
+	assert.Equal(t, []string{"amina", "chausiku"}, accounts(t, db))
+}
+
+// replicaDB begins fake transactions logging their statements. it holds a slice so that
+// it is not comparable
+type replicaDB struct {
+	sqlc.DBTX
+	hosts []string
+	log   *[]string
+}
+
+func (db replicaDB) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	*db.log = append(*db.log, "begin")
+	return replicaTx{log: db.log}, nil
+}
+
+type replicaTx struct {
+	pgx.Tx
+	log *[]string
+}
+
+func (tx replicaTx) Exec(_ context.Context, sql string, _ ...interface{}) (pgconn.CommandTag, error) {
+	*tx.log = append(*tx.log, sql)
+	return nil, nil
+}
+
+func (tx replicaTx) Commit(context.Context) error {
+	*tx.log = append(*tx.log, "commit")
+	return nil
+}
+
+func (tx replicaTx) Rollback(context.Context) error {
+	*tx.log = append(*tx.log, "rollback")
+	return nil
+}
+
+func TestWithTxIncomparableDB(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	db := replicaDB{hosts: []string{"replica-1"}, log: &log}
+	tx := sqlc.NewTxManager(db)
+
+	require.Nil(t, tx.WithTx(ctx, func(ctx context.Context) error {
+		_, err := tx.DB(ctx).Exec(ctx, "update accounts set balance = 0")
+		require.Nil(t, err)
+		assert.Equal(t, sqlc.DBTX(db), sqlc.DB(ctx, db), "only the manager finds the transaction")
+		return tx.WithTx(ctx, func(ctx context.Context) error { return nil })
+	}))
+	assert.Equal(t, []string{"begin", "update accounts set balance = 0", "savepoint sp_1", "release savepoint sp_1", "commit"}, log)
+}
+
+func TestWithTxRetries(t *testing.T) {
+	ctx := context.Background()
+	db := accountsDB(t)
+	for name, tc := range map[string]struct {
+		err      error
+		opts     []sqlc.TxOption
+		attempts int
+	}{
+		"serialization failure": {&pgconn.PgError{Code: "40001"}, nil, 4},
+		"wrapped deadlock":      {errors.Join(errors.New("transfer"), &pgconn.PgError{Code: "40P01"}), []sqlc.TxOption{sqlc.Retries(1)}, 2},
+		"unique violation":      {&pgconn.PgError{Code: "23505"}, nil, 1},
+		"no rows":               {pgx.ErrNoRows, nil, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			err := sqlc.WithTx(ctx, db, func(ctx context.Context) error {
+				attempts++
+				return tc.err
+			}, append(tc.opts, sqlc.RetryBackoff(0))...)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+	assert.True(t, sqlc.Retryable(&pgconn.PgError{Code: "40001"}))
+}
+
+func TestWithTxUnsupported(t *testing.T) {
+	ctx := context.Background()
+	err := sqlc.WithTx(ctx, sqlc.FromSQL(nil, "sqlite3"), func(ctx context.Context) error { return nil })
+	assert.ErrorContains(t, err, "cannot begin transactions")
+}
@@ -8,15 +8,15 @@ import (
 )
 
 type Conf struct {
-	Driver           string
-	Host             string
-	Port             string
-	Heartbeat        time.Duration
-	HeartbeatTimeout time.Duration
-	Username         string
-	Password         string
-	Database         string
-	SSLMode          string
+	Driver           string        `env:"DRIVER" required:"true"`
+	Host             string        `env:"HOST"`
+	Port             string        `env:"PORT"`
+	Heartbeat        time.Duration `env:"HEARTBEAT_MILLISECONDS" default:"5000" unit:"ms"`
+	HeartbeatTimeout time.Duration `env:"HEARTBEAT_TIMEOUT_MILLISECONDS" default:"20000" unit:"ms"`
+	Username         string        `env:"USERNAME"`
+	Password         string        `env:"PASSWORD"`
+	Database         string        `env:"DATABASE"`
+	SSLMode          string        `env:"SSL_MODE"`
 }
 
 const (
@@ -38,22 +38,19 @@ var defaults = map[string]map[string]string{
 }
 
 func Config(prefix ...string) (conf *Conf) {
-	env := utils.Env.Helper(prefix...)
-	heartbeat := env.Get("HEARTBEAT_MILLISECONDS", DefaultHeartbeat).Int()
-	heartbeatTimeout := env.Get("HEARTBEAT_TIMEOUT_MILLISECONDS", DefaultHeartbeatTimeout).Int()
-
-	conf = &Conf{
-		Heartbeat:        time.Duration(heartbeat) * time.Millisecond,
-		HeartbeatTimeout: time.Duration(heartbeatTimeout) * time.Millisecond,
-		Driver:           env.MustGet("DRIVER").String(),
+	conf = &Conf{}
+	utils.Env.MustLoad(utils.Env.Helper(prefix...).Prefix(), conf)
+	for prop, val := range map[string]*string{
+		"HOST":     &conf.Host,
+		"PORT":     &conf.Port,
+		"USERNAME": &conf.Username,
+		"PASSWORD": &conf.Password,
+		"DATABASE": &conf.Database,
+	} {
+		if *val == "" {
+			*val = conf._default(prop)
+		}
 	}
-
-	conf.Host = env.GetString("HOST", conf._default("HOST"))
-	conf.Port = env.GetString("PORT", conf._default("PORT"))
-	conf.Username = env.GetString("USERNAME", conf._default("USERNAME"))
-	conf.Password = env.GetString("PASSWORD", conf._default("PASSWORD"))
-	conf.Database = env.GetString("DATABASE", conf._default("DATABASE"))
-	conf.SSLMode = env.GetString("SSL_MODE", conf._default("disable"))
 	return
 }
 
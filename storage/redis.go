@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"strconv"
 
 	"github.com/go-redis/redis/v8"
@@ -13,4 +14,11 @@ func Redis(conf *Conf) *redis.Client {
 		Password: conf.Password, 
 		DB:       database,      
 	})
-}
\ No newline at end of file
+}
+
+// RedisHealthCheck returns a probe that pings the redis server
+func RedisHealthCheck(client *redis.Client) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
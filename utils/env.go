@@ -19,8 +19,8 @@ var Env envUtils
 type envUtils struct {
 }
 
-func (envUtils) GetOrDefault(env, _default string) Value {
-	if val := os.Getenv(env); val != "" {
+func (e envUtils) GetOrDefault(env, _default string) Value {
+	if val, ok := e.lookup(env); ok {
 		return Value(val)
 	}
 	return Value(_default)
@@ -31,12 +31,12 @@ func (envUtils) Helper(prefix ...string) EnvUtil {
 	return env.setPrx(prefix...)
 }
 
-func (envUtils) GetHost() string {
+func (e envUtils) GetHost() string {
 	host, err := os.Hostname()
 	if err == nil {
 		return host
 	}
-	return os.Getenv("HOST")
+	return e.GetOrDefault("HOST", "").String()
 }
 
 type _env struct {
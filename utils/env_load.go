@@ -0,0 +1,329 @@
+package utils
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvDecoder is implemented by config field types that parse their own env values
+type EnvDecoder interface {
+	DecodeEnv(value string) error
+}
+
+var ErrEnvMissing = errors.New("required but not set")
+
+// EnvVarError describes a single env variable that is missing or could not be parsed
+type EnvVarError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *EnvVarError) Error() string {
+	if e.Err == ErrEnvMissing {
+		return fmt.Sprintf("%s is %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s=%q is invalid: %v", e.Name, e.Value, e.Err)
+}
+
+func (e *EnvVarError) Unwrap() error {
+	return e.Err
+}
+
+// EnvErrors aggregates every problem found by Env.Load
+type EnvErrors []*EnvVarError
+
+func (e EnvErrors) Error() string {
+	list := make([]string, len(e))
+	for i := range e {
+		list[i] = e[i].Error()
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(list, "; "))
+}
+
+// Missing returns the names of required variables that were not set
+func (e EnvErrors) Missing() (out []string) {
+	for i := range e {
+		if e[i].Err == ErrEnvMissing {
+			out = append(out, e[i].Name)
+		}
+	}
+	return
+}
+
+var (
+	envDecoders  sync.Map
+	envStrict    atomic.Bool
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+func init() {
+	RegisterEnvDecoder(time.LoadLocation)
+}
+
+// RegisterEnvDecoder registers a parser for fields of type T, typically for types
+// declared in other packages that cannot implement EnvDecoder
+func RegisterEnvDecoder[T any](fn func(string) (T, error)) {
+	envDecoders.Store(reflect.TypeOf((*T)(nil)).Elem(), func(value string) (out reflect.Value, err error) {
+		var val T
+		if val, err = fn(value); err != nil {
+			return
+		}
+		return reflect.ValueOf(&val).Elem(), nil
+	})
+}
+
+// Load populates the struct pointed to by out from env variables named by its field tags.
+//
+//	type conf struct {
+//		Port    int           `env:"HTTP_PORT" default:"49080"`
+//		Secret  string        `env:"SECRET" required:"true"`
+//		Origins []string      `env:"ALLOW_ORIGINS" default:"*" sep:","`
+//		Timeout time.Duration `env:"TIMEOUT_MILLISECONDS" default:"5000" unit:"ms"`
+//		Redis   *redisConf    `envPrefix:"REDIS"`
+//	}
+//
+// variables are looked up as PREFIX_NAME. nested structs are loaded with their envPrefix
+// appended to the prefix, untagged fields are left untouched and all missing or invalid
+// variables are reported together as EnvErrors
+func (envUtils) Load(prefix string, out any) error {
+	return loadEnv(prefix, out, false)
+}
+
+// LoadOrDefault is like Load but logs invalid values and uses their default, or zero, in
+// their place as Value.Int, Value.Duration and co. do. only missing required variables
+// fail, unless Strict
+func (envUtils) LoadOrDefault(prefix string, out any) error {
+	return loadEnv(prefix, out, !envStrict.Load())
+}
+
+// MustLoad is like LoadOrDefault but panics when the configuration is invalid
+func (e envUtils) MustLoad(prefix string, out any) {
+	if err := e.LoadOrDefault(prefix, out); err != nil {
+		panic(err)
+	}
+}
+
+// Strict makes LoadOrDefault and MustLoad fail on invalid values as Load does. it is
+// turned on by app.Init(true)
+func (envUtils) Strict(strict bool) {
+	envStrict.Store(strict)
+}
+
+func loadEnv(prefix string, out any, lenient bool) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("env: Load expects a non-nil pointer to a struct, got %T", out)
+	}
+	var errs EnvErrors
+	loadEnvStruct(prefix, rv.Elem(), &errs, lenient)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (envUtils) lookup(name string) (string, bool) {
+	val, ok := envSources.trace(name)
+	return val.Value, ok
+}
+
+func envName(prefix, name string) string {
+	if prefix = strings.Trim(prefix, "_"); prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+func loadEnvStruct(prefix string, v reflect.Value, errs *EnvErrors, lenient bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, tagged := field.Tag.Lookup("env")
+		if name == "-" {
+			continue
+		} else if !tagged {
+			if isEnvStruct(field.Type) {
+				loadEnvNested(envName(prefix, field.Tag.Get("envPrefix")), fv, errs, lenient)
+			}
+			continue
+		}
+		key := envName(prefix, name)
+		val, ok := Env.lookup(key)
+		if !ok {
+			if val, ok = field.Tag.Lookup("default"); !ok {
+				if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+					*errs = append(*errs, &EnvVarError{Name: key, Err: ErrEnvMissing})
+				}
+				continue
+			}
+		}
+		err := decodeEnvValue(fv, val, field.Tag)
+		if err == nil {
+			continue
+		} else if !lenient {
+			*errs = append(*errs, &EnvVarError{Name: key, Value: val, Err: err})
+			continue
+		}
+		log.Errorf("%v. using the default", &EnvVarError{Name: key, Value: val, Err: err})
+		fv.Set(reflect.Zero(fv.Type()))
+		if def, ok := field.Tag.Lookup("default"); ok && def != val && decodeEnvValue(fv, def, field.Tag) != nil {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+}
+
+func loadEnvNested(prefix string, v reflect.Value, errs *EnvErrors, lenient bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	loadEnvStruct(prefix, v, errs, lenient)
+}
+
+func isEnvStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	} else if _, ok := envDecoders.Load(t); ok {
+		return false
+	}
+	ptr := reflect.PointerTo(t)
+	return !ptr.Implements(reflect.TypeOf((*EnvDecoder)(nil)).Elem()) &&
+		!ptr.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+func decodeEnvValue(v reflect.Value, raw string, tag reflect.StructTag) (err error) {
+	if dec, ok := envDecoders.Load(v.Type()); ok {
+		var out reflect.Value
+		if out, err = dec.(func(string) (reflect.Value, error))(raw); err == nil {
+			v.Set(out)
+		}
+		return
+	} else if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err = decodeEnvValue(ptr.Elem(), raw, tag); err == nil {
+			v.Set(ptr)
+		}
+		return
+	} else if d, ok := v.Addr().Interface().(EnvDecoder); ok {
+		return d.DecodeEnv(raw)
+	} else if d, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return d.UnmarshalText([]byte(raw))
+	}
+	switch v.Type() {
+	case durationType:
+		var d time.Duration
+		if d, err = parseEnvDuration(raw, tag.Get("unit")); err == nil {
+			v.SetInt(int64(d))
+		}
+		return
+	case timeType:
+		layout := tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		var t time.Time
+		if t, err = time.Parse(layout, strings.TrimSpace(raw)); err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(strings.TrimSpace(raw)); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(strings.TrimSpace(raw), 0, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(strings.TrimSpace(raw), 0, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(strings.TrimSpace(raw), v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	case reflect.Slice:
+		return decodeEnvSlice(v, raw, tag)
+	default:
+		return errors.Errorf("unsupported config type %s", v.Type())
+	}
+	return
+}
+
+func decodeEnvSlice(v reflect.Value, raw string, tag reflect.StructTag) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes([]byte(raw))
+		return nil
+	}
+	sep := tag.Get("sep")
+	if sep == "" {
+		sep = ","
+	}
+	parts := strings.Split(raw, sep)
+	out := reflect.MakeSlice(v.Type(), 0, len(parts))
+	for i := range parts {
+		part := strings.TrimSpace(parts[i])
+		if part == "" {
+			continue
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeEnvValue(elem, part, tag); err != nil {
+			return errors.Wrapf(err, "item %d", i)
+		}
+		out = reflect.Append(out, elem)
+	}
+	v.Set(out)
+	return nil
+}
+
+// parseEnvDuration accepts go duration strings as well as plain numbers, which are
+// interpreted in the field's unit (ms, s, m, h) for compatibility with *_MILLISECONDS vars
+func parseEnvDuration(raw, unit string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || unit == "" {
+		return time.ParseDuration(raw)
+	}
+	switch unit {
+	case "ns":
+		return time.Duration(n), nil
+	case "us":
+		return time.Duration(n) * time.Microsecond, nil
+	case "ms":
+		return time.Duration(n) * time.Millisecond, nil
+	case "s":
+		return time.Duration(n) * time.Second, nil
+	case "m":
+		return time.Duration(n) * time.Minute, nil
+	case "h":
+		return time.Duration(n) * time.Hour, nil
+	}
+	return 0, errors.Errorf("unknown duration unit %q", unit)
+}
@@ -0,0 +1,127 @@
+package utils_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type upperString string
+
+func (s *upperString) DecodeEnv(value string) error {
+	*s = upperString(strings.ToUpper(value))
+	return nil
+}
+
+type testDbConf struct {
+	Host    string        `env:"HOST" default:"localhost"`
+	Port    int           `env:"PORT" required:"true"`
+	Timeout time.Duration `env:"TIMEOUT_MILLISECONDS" default:"1500" unit:"ms"`
+}
+
+type testConf struct {
+	Name      string         `env:"NAME" required:"true"`
+	Debug     bool           `env:"DEBUG" default:"false"`
+	Ratio     float64        `env:"RATIO" default:"0.5"`
+	Origins   []string       `env:"ORIGINS" default:"*"`
+	Ports     []int          `env:"PORTS" sep:";"`
+	Interval  time.Duration  `env:"INTERVAL" default:"10s"`
+	Location  *time.Location `env:"TIME_LOCATION" default:"UTC"`
+	Mode      upperString    `env:"MODE" default:"dev"`
+	Raw       utils.Value    `env:"RAW"`
+	Computed  string
+	Db        *testDbConf `envPrefix:"DB"`
+	Secondary testDbConf  `envPrefix:"DB_SECONDARY"`
+}
+
+var _ = Describe("Env.Load", func() {
+
+	It("applies defaults and nested prefixes", func() {
+		setEnv(map[string]string{"TST_NAME": "svc", "TST_DB_PORT": "5432", "TST_DB_SECONDARY_PORT": "5433"})
+		var conf = testConf{Computed: "kept"}
+		Expect(utils.Env.Load("TST", &conf)).To(Succeed())
+		Expect(conf.Name).To(Equal("svc"))
+		Expect(conf.Debug).To(BeFalse())
+		Expect(conf.Ratio).To(Equal(0.5))
+		Expect(conf.Origins).To(Equal([]string{"*"}))
+		Expect(conf.Ports).To(BeEmpty())
+		Expect(conf.Interval).To(Equal(10 * time.Second))
+		Expect(conf.Location).To(Equal(time.UTC))
+		Expect(conf.Mode).To(Equal(upperString("DEV")))
+		Expect(conf.Computed).To(Equal("kept"))
+		Expect(conf.Db).NotTo(BeNil())
+		Expect(conf.Db.Host).To(Equal("localhost"))
+		Expect(conf.Db.Port).To(Equal(5432))
+		Expect(conf.Db.Timeout).To(Equal(1500 * time.Millisecond))
+		Expect(conf.Secondary.Port).To(Equal(5433))
+	})
+
+	It("parses values from the environment", func() {
+		setEnv(map[string]string{
+			"TST_NAME": "svc", "TST_DEBUG": "true", "TST_ORIGINS": "a.io, b.io,", "TST_PORTS": "80;443",
+			"TST_INTERVAL": "1m", "TST_RAW": "15m", "TST_DB_PORT": "1", "TST_DB_TIMEOUT_MILLISECONDS": "2s",
+			"TST_DB_SECONDARY_PORT": "2",
+		})
+		var conf testConf
+		Expect(utils.Env.Load("TST_", &conf)).To(Succeed())
+		Expect(conf.Debug).To(BeTrue())
+		Expect(conf.Origins).To(Equal([]string{"a.io", "b.io"}))
+		Expect(conf.Ports).To(Equal([]int{80, 443}))
+		Expect(conf.Interval).To(Equal(time.Minute))
+		Expect(conf.Raw.Duration()).To(Equal(15 * time.Minute))
+		Expect(conf.Db.Timeout).To(Equal(2 * time.Second))
+	})
+
+	It("reports every missing and invalid variable together", func() {
+		setEnv(map[string]string{"TST_DEBUG": "maybe", "TST_PORTS": "80;http", "TST_TIME_LOCATION": "Nowhere/City"})
+		var conf testConf
+		err := utils.Env.Load("TST", &conf)
+		Expect(err).To(HaveOccurred())
+
+		var envErrors utils.EnvErrors
+		Expect(errors.As(err, &envErrors)).To(BeTrue())
+		Expect(envErrors.Missing()).To(ConsistOf("TST_NAME", "TST_DB_PORT", "TST_DB_SECONDARY_PORT"))
+		Expect(envErrors).To(HaveLen(6))
+		Expect(err.Error()).To(ContainSubstring("TST_DEBUG"))
+		Expect(err.Error()).To(ContainSubstring("TST_PORTS"))
+		Expect(err.Error()).To(ContainSubstring("TST_TIME_LOCATION"))
+	})
+
+	It("uses registered decoders", func() {
+		type level int
+		utils.RegisterEnvDecoder(func(s string) (level, error) { return level(len(s)), nil })
+		var conf struct {
+			Level level `env:"LEVEL" default:"four"`
+		}
+		Expect(utils.Env.Load("TST", &conf)).To(Succeed())
+		Expect(conf.Level).To(Equal(level(4)))
+	})
+
+	It("rejects non struct pointers", func() {
+		var conf testConf
+		Expect(utils.Env.Load("TST", conf)).NotTo(Succeed())
+	})
+
+	It("panics on MustLoad with invalid config", func() {
+		Expect(func() { utils.Env.MustLoad("TST", &testConf{}) }).To(Panic())
+	})
+
+	It("uses defaults in place of invalid values unless strict", func() {
+		setEnv(map[string]string{"TST_NAME": "svc", "TST_DEBUG": "maybe", "TST_INTERVAL": "soon", "TST_PORTS": "80;http", "TST_DB_PORT": "x", "TST_DB_SECONDARY_PORT": "2"})
+		var conf testConf
+		Expect(utils.Env.LoadOrDefault("TST", &conf)).To(Succeed())
+		Expect(conf.Debug).To(BeFalse())
+		Expect(conf.Interval).To(Equal(10 * time.Second))
+		Expect(conf.Ports).To(BeEmpty())
+		Expect(conf.Db.Port).To(BeZero())
+
+		utils.Env.Strict(true)
+		defer utils.Env.Strict(false)
+		Expect(utils.Env.LoadOrDefault("TST", &conf)).To(HaveOccurred())
+		Expect(func() { utils.Env.MustLoad("TST", &conf) }).To(Panic())
+	})
+})
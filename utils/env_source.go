@@ -0,0 +1,364 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// precedence of the standard config sources. sources with a higher priority
+// override values supplied by those with a lower one
+const (
+	EnvPriorityDefaults = 0
+	EnvPriorityFile     = 10
+	EnvPriorityDotEnv   = 20
+	EnvPriorityOS       = 30
+	EnvPriorityRemote   = 40
+)
+
+// EnvSource supplies configuration values keyed by their full env name e.g. APP_HTTP_PORT
+type EnvSource interface {
+	Name() string
+	Lookup(key string) (string, bool)
+}
+
+// EnvValue is a resolved configuration value along with the source that supplied it
+type EnvValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Source   string `json:"source"`
+	Priority int    `json:"priority"`
+}
+
+type envSourceEntry struct {
+	source   EnvSource
+	priority int
+	seq      int
+}
+
+type envSourceChain struct {
+	mx      sync.RWMutex
+	seq     int
+	entries []envSourceEntry
+}
+
+var envSources = &envSourceChain{entries: []envSourceEntry{{source: OSEnvSource(), priority: EnvPriorityOS}}}
+
+func (c *envSourceChain) add(priority int, source EnvSource) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.seq++
+	c.entries = append(c.entries, envSourceEntry{source: source, priority: priority, seq: c.seq})
+	sort.SliceStable(c.entries, func(i, j int) bool {
+		if c.entries[i].priority == c.entries[j].priority {
+			return c.entries[i].seq > c.entries[j].seq
+		}
+		return c.entries[i].priority > c.entries[j].priority
+	})
+}
+
+func (c *envSourceChain) remove(name string) (removed bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for i := 0; i < len(c.entries); i++ {
+		if c.entries[i].source.Name() == name {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			i, removed = i-1, true
+		}
+	}
+	return
+}
+
+func (c *envSourceChain) trace(key string) (EnvValue, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	for _, e := range c.entries {
+		if val, ok := e.source.Lookup(key); ok && val != "" {
+			return EnvValue{Key: key, Value: val, Source: e.source.Name(), Priority: e.priority}, true
+		}
+	}
+	return EnvValue{Key: key}, false
+}
+
+// AddSource adds a config source to the lookup chain used by Env and every EnvUtil.
+// sources added later win over earlier ones of the same priority
+func (e envUtils) AddSource(priority int, source EnvSource) {
+	envSources.add(priority, source)
+	e.Refresh()
+}
+
+// RemoveSource removes all sources with the given name from the lookup chain
+func (e envUtils) RemoveSource(name string) (removed bool) {
+	if removed = envSources.remove(name); removed {
+		e.Refresh()
+	}
+	return
+}
+
+// Sources lists the names of the configured sources from highest to lowest precedence
+func (envUtils) Sources() (out []string) {
+	envSources.mx.RLock()
+	defer envSources.mx.RUnlock()
+	for _, e := range envSources.entries {
+		out = append(out, e.source.Name())
+	}
+	return
+}
+
+// Trace resolves key and reports which source supplied its value
+func (envUtils) Trace(key string) (EnvValue, bool) {
+	return envSources.trace(key)
+}
+
+type osEnvSource struct{}
+
+func (osEnvSource) Name() string { return "os" }
+
+func (osEnvSource) Lookup(key string) (string, bool) { return os.LookupEnv(key) }
+
+// OSEnvSource reads values from the process environment. it is part of the default chain
+func OSEnvSource() EnvSource {
+	return osEnvSource{}
+}
+
+type mapSource struct {
+	mx     sync.RWMutex
+	name   string
+	values map[string]string
+}
+
+func (s *mapSource) Name() string { return s.name }
+
+func (s *mapSource) Lookup(key string) (val string, ok bool) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	val, ok = s.values[key]
+	return
+}
+
+func (s *mapSource) set(values map[string]string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.values = values
+}
+
+// MapSource serves values from memory. useful for defaults and tests
+func MapSource(name string, values map[string]string) EnvSource {
+	out := &mapSource{name: name, values: make(map[string]string, len(values))}
+	for k, v := range values {
+		out.values[k] = v
+	}
+	return out
+}
+
+// fileSource is a mapSource backed by files on disk. it can be reloaded on demand
+// and watched for modifications
+type fileSource struct {
+	*mapSource
+	paths []string
+	read  func() (map[string]string, error)
+	stats []os.FileInfo
+}
+
+func newFileSource(name string, paths []string, read func() (map[string]string, error)) (*fileSource, error) {
+	out := &fileSource{mapSource: &mapSource{name: name}, paths: paths, read: read}
+	if err := out.Reload(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *fileSource) Reload() (err error) {
+	stats := s.stat()
+	var values map[string]string
+	if values, err = s.read(); err != nil {
+		return
+	}
+	s.set(values)
+	s.mx.Lock()
+	s.stats = stats
+	s.mx.Unlock()
+	return
+}
+
+func (s *fileSource) stat() (out []os.FileInfo) {
+	out = make([]os.FileInfo, len(s.paths))
+	for i := range s.paths {
+		out[i], _ = os.Stat(s.paths[i])
+	}
+	return
+}
+
+func (s *fileSource) modified() bool {
+	stats := s.stat()
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	for i := range stats {
+		if prev := s.stats[i]; (prev == nil) != (stats[i] == nil) {
+			return true
+		} else if prev != nil && (!prev.ModTime().Equal(stats[i].ModTime()) || prev.Size() != stats[i].Size()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch polls the files every EnvWatchInterval and reloads them when they are modified
+func (s *fileSource) Watch(ctx context.Context, changed func()) error {
+	ticker := time.NewTicker(EnvWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if !s.modified() {
+				continue
+			} else if err := s.Reload(); err != nil {
+				log.WithError(err).Errorf("failed to reload %s", s.name)
+				continue
+			}
+			changed()
+		}
+	}
+}
+
+// DotEnvSource reads the given .env files without modifying the process environment.
+// values in earlier files take precedence as with godotenv.Load
+func DotEnvSource(files ...string) (EnvSource, error) {
+	return dotEnvSource(files, nil)
+}
+
+// ExportDotEnv is DotEnvSource that also sets the values of keys missing from the process
+// environment, as godotenv.Load does, for clients that only read os.Getenv e.g. the aws and
+// consul sdks. exported keys follow reloads of the files
+func ExportDotEnv(files ...string) (EnvSource, error) {
+	var mx sync.Mutex
+	exported := make(map[string]bool)
+	return dotEnvSource(files, func(values map[string]string) {
+		mx.Lock()
+		defer mx.Unlock()
+		for k, v := range values {
+			if _, set := os.LookupEnv(k); exported[k] || !set {
+				os.Setenv(k, v)
+				exported[k] = true
+			}
+		}
+		for k := range exported {
+			if _, ok := values[k]; !ok {
+				os.Unsetenv(k)
+				delete(exported, k)
+			}
+		}
+	})
+}
+
+func dotEnvSource(files []string, export func(map[string]string)) (EnvSource, error) {
+	if len(files) == 0 {
+		files = []string{".env"}
+	}
+	return newFileSource(fmt.Sprintf("dotenv:%s", strings.Join(files, ",")), files, func() (map[string]string, error) {
+		values := make(map[string]string)
+		for i := len(files) - 1; i >= 0; i-- {
+			vars, err := godotenv.Read(files[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read %s", files[i])
+			}
+			for k, v := range vars {
+				values[k] = v
+			}
+		}
+		if export != nil {
+			export(values)
+		}
+		return values, nil
+	})
+}
+
+// FileSource reads a yaml, json or toml config file. nested keys are flattened into
+// env style names so that
+//
+//	app:
+//	  http_port: 8080
+//	  http_server:
+//	    allow_origins: [a.io, b.io]
+//
+// supplies APP_HTTP_PORT=8080 and APP_HTTP_SERVER_ALLOW_ORIGINS=a.io,b.io
+func FileSource(path string) (EnvSource, error) {
+	var unmarshal func([]byte, any) error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".json":
+		unmarshal = json.Unmarshal
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	default:
+		return nil, errors.Errorf("unsupported config file format %q", ext)
+	}
+	return newFileSource(fmt.Sprintf("file:%s", path), []string{path}, func() (map[string]string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read config file %s", path)
+		}
+		var tree map[string]any
+		if err = unmarshal(data, &tree); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse config file %s", path)
+		}
+		values := make(map[string]string)
+		flattenEnvTree("", tree, values)
+		return values, nil
+	})
+}
+
+// EnvKey converts a config path segment e.g. http-server.allowOrigins into its env form
+// e.g. HTTP_SERVER_ALLOW_ORIGINS. words of camelCase segments are separated where a lower
+// case letter or digit is followed by an upper case one
+func EnvKey(parts ...string) string {
+	var key strings.Builder
+	var prev rune
+	for _, r := range strings.Join(parts, "_") {
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+			key.WriteByte('_')
+		}
+		key.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return strings.Trim(strings.NewReplacer("-", "_", ".", "_", "/", "_", " ", "_").Replace(key.String()), "_")
+}
+
+func flattenEnvTree(prefix string, node any, out map[string]string) {
+	switch val := node.(type) {
+	case map[string]any:
+		for k, v := range val {
+			flattenEnvTree(EnvKey(prefix, k), v, out)
+		}
+	case map[any]any:
+		for k, v := range val {
+			flattenEnvTree(EnvKey(prefix, fmt.Sprint(k)), v, out)
+		}
+	case []any:
+		list := make([]string, 0, len(val))
+		for i := range val {
+			list = append(list, fmt.Sprint(val[i]))
+		}
+		out[prefix] = strings.Join(list, ",")
+	case nil:
+		out[prefix] = ""
+	default:
+		out[prefix] = fmt.Sprint(val)
+	}
+}
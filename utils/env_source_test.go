@@ -0,0 +1,101 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func addSource(priority int, source utils.EnvSource) {
+	utils.Env.AddSource(priority, source)
+	DeferCleanup(utils.Env.RemoveSource, source.Name())
+}
+
+func writeFile(name, content string) string {
+	path := filepath.Join(GinkgoT().TempDir(), name)
+	Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	return path
+}
+
+var _ = Describe("Env sources", func() {
+
+	It("resolves values by source precedence", func() {
+		addSource(utils.EnvPriorityRemote, utils.MapSource("remote", map[string]string{"SRC_A": "remote"}))
+		addSource(utils.EnvPriorityDefaults, utils.MapSource("defaults", map[string]string{"SRC_A": "default", "SRC_B": "default", "SRC_C": "default"}))
+		addSource(utils.EnvPriorityFile, utils.MapSource("file", map[string]string{"SRC_B": "file"}))
+		setEnv(map[string]string{"SRC_A": "os", "SRC_B": "os"})
+
+		Expect(utils.Env.Sources()).To(Equal([]string{"remote", "os", "file", "defaults"}))
+		Expect(utils.Env.Helper("SRC").GetString("A")).To(Equal("remote"))
+		Expect(utils.Env.Helper("SRC").GetString("B")).To(Equal("os"))
+		Expect(utils.Env.Helper("SRC").GetString("C")).To(Equal("default"))
+		Expect(utils.Env.Helper("SRC").GetString("D", "fallback")).To(Equal("fallback"))
+
+		val, ok := utils.Env.Trace("SRC_C")
+		Expect(ok).To(BeTrue())
+		Expect(val).To(Equal(utils.EnvValue{Key: "SRC_C", Value: "default", Source: "defaults", Priority: utils.EnvPriorityDefaults}))
+		_, ok = utils.Env.Trace("SRC_D")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("feeds Env.Load", func() {
+		addSource(utils.EnvPriorityDefaults, utils.MapSource("defaults", map[string]string{"TST_NAME": "svc", "TST_DB_PORT": "1", "TST_DB_SECONDARY_PORT": "2"}))
+		var conf testConf
+		Expect(utils.Env.Load("TST", &conf)).To(Succeed())
+		Expect(conf.Name).To(Equal("svc"))
+		Expect(conf.Db.Port).To(Equal(1))
+	})
+
+	DescribeTable("flattens config files",
+		func(name, content string) {
+			source, err := utils.FileSource(writeFile(name, content))
+			Expect(err).NotTo(HaveOccurred())
+			val, _ := source.Lookup("APP_HTTP_PORT")
+			Expect(val).To(Equal("8080"))
+			val, _ = source.Lookup("APP_HTTP_SERVER_ALLOW_ORIGINS")
+			Expect(val).To(Equal("a.io,b.io"))
+		},
+		Entry("yaml", "config.yaml", "app:\n  http_port: 8080\n  http-server:\n    allow_origins: [a.io, b.io]\n"),
+		Entry("json", "config.json", `{"app": {"http_port": 8080, "http-server": {"allow_origins": ["a.io", "b.io"]}}}`),
+		Entry("toml", "config.toml", "[app]\nhttp_port = 8080\n[app.http-server]\nallow_origins = ['a.io', 'b.io']\n"),
+		Entry("camelCase yaml", "camel.yaml", "app:\n  httpPort: 8080\n  http-server:\n    allowOrigins: [a.io, b.io]\n"),
+	)
+
+	It("converts config paths to env keys", func() {
+		Expect(utils.EnvKey("http-server.allowOrigins")).To(Equal("HTTP_SERVER_ALLOW_ORIGINS"))
+		Expect(utils.EnvKey("APP", "HTTP_SERVER_RATE_LIMIT", "bulkExport")).To(Equal("APP_HTTP_SERVER_RATE_LIMIT_BULK_EXPORT"))
+		Expect(utils.EnvKey("oauth2Client")).To(Equal("OAUTH2_CLIENT"))
+	})
+
+	It("rejects unknown file formats", func() {
+		_, err := utils.FileSource(writeFile("config.ini", "a=b"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads .env files without touching the process env", func() {
+		source, err := utils.DotEnvSource(writeFile(".env", "DOTENV_ONLY=yes\n"))
+		Expect(err).NotTo(HaveOccurred())
+		addSource(utils.EnvPriorityDotEnv, source)
+		Expect(utils.Env.GetOrDefault("DOTENV_ONLY", "").String()).To(Equal("yes"))
+		Expect(os.Getenv("DOTENV_ONLY")).To(BeEmpty())
+	})
+
+	It("exports .env values missing from the process env", func() {
+		setEnv(map[string]string{"DOTENV_SET": "os"})
+		path := writeFile(".env", "DOTENV_SET=file\nDOTENV_EXPORTED=yes\n")
+		source, err := utils.ExportDotEnv(path)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.Unsetenv, "DOTENV_EXPORTED")
+		Expect(os.Getenv("DOTENV_SET")).To(Equal("os"))
+		Expect(os.Getenv("DOTENV_EXPORTED")).To(Equal("yes"))
+
+		Expect(os.WriteFile(path, []byte("DOTENV_SET=file\n"), 0o600)).To(Succeed())
+		Expect(source.(interface{ Reload() error }).Reload()).To(Succeed())
+		_, exported := os.LookupEnv("DOTENV_EXPORTED")
+		Expect(exported).To(BeFalse())
+		Expect(os.Getenv("DOTENV_SET")).To(Equal("os"))
+	})
+})
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvWatchInterval is how often file based sources are polled for modifications
+var EnvWatchInterval = 2 * time.Second
+
+// ReloadableSource is a config source that can re-read its values on demand e.g. on SIGHUP
+type ReloadableSource interface {
+	EnvSource
+	Reload() error
+}
+
+// WatchableSource is a config source that detects changes to its values by itself.
+// Watch blocks until ctx is done and calls changed after every update
+type WatchableSource interface {
+	EnvSource
+	Watch(ctx context.Context, changed func()) error
+}
+
+type envSubscription struct {
+	key  string
+	last EnvValue
+	fn   func(old, new EnvValue)
+}
+
+type envSubscriptions struct {
+	mx   sync.Mutex
+	seq  int
+	subs map[int]*envSubscription
+}
+
+var envSubs = &envSubscriptions{subs: make(map[int]*envSubscription)}
+
+func (s *envSubscriptions) add(key string, fn func(old, new EnvValue)) (unsubscribe func()) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.seq++
+	id, last := s.seq, EnvValue{Key: key}
+	if val, ok := envSources.trace(key); ok {
+		last = val
+	}
+	s.subs[id] = &envSubscription{key: key, last: last, fn: fn}
+	return func() {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		delete(s.subs, id)
+	}
+}
+
+func (s *envSubscriptions) refresh() {
+	type change struct {
+		fn       func(old, new EnvValue)
+		old, new EnvValue
+	}
+	var changes []change
+	s.mx.Lock()
+	for _, sub := range s.subs {
+		val, _ := envSources.trace(sub.key)
+		if val.Value == sub.last.Value {
+			continue
+		}
+		changes = append(changes, change{fn: sub.fn, old: sub.last, new: val})
+		sub.last = val
+	}
+	s.mx.Unlock()
+	for _, c := range changes {
+		c.fn(c.old, c.new)
+	}
+}
+
+// Subscribe calls fn whenever the resolved value of key changes. fn is not called
+// for the current value. the returned func removes the subscription
+func (envUtils) Subscribe(key string, fn func(old, new EnvValue)) (unsubscribe func()) {
+	return envSubs.add(key, fn)
+}
+
+// SubscribeEnv is a typed Subscribe. the new value is parsed into T the same way
+// Env.Load parses fields, with _default used when the key is no longer set.
+// values that fail to parse are logged and skipped
+func SubscribeEnv[T any](key string, _default string, fn func(T)) (unsubscribe func()) {
+	return Env.Subscribe(key, func(_, val EnvValue) {
+		raw := val.Value
+		if raw == "" {
+			raw = _default
+		}
+		var out T
+		if err := decodeEnvValue(reflect.ValueOf(&out).Elem(), raw, ""); err != nil {
+			log.WithError(err).WithField("source", val.Source).Errorf("ignoring invalid value %s=%q", key, raw)
+			return
+		}
+		fn(out)
+	})
+}
+
+// Refresh notifies subscribers of values that changed since they were last seen.
+// it should be called by sources that update their values outside of Reload and Watch
+func (envUtils) Refresh() {
+	envSubs.refresh()
+}
+
+// Reload re-reads every ReloadableSource in the chain then notifies subscribers
+func (e envUtils) Reload() (err error) {
+	for _, source := range envSources.list() {
+		if r, ok := source.(ReloadableSource); !ok {
+			continue
+		} else if rerr := r.Reload(); rerr != nil {
+			err = errors.Wrapf(rerr, "failed to reload %s", source.Name())
+			log.WithError(rerr).Errorf("failed to reload %s", source.Name())
+		}
+	}
+	e.Refresh()
+	return
+}
+
+// Watch starts watching every WatchableSource in the chain until ctx is done
+func (e envUtils) Watch(ctx context.Context) {
+	for _, source := range envSources.list() {
+		if w, ok := source.(WatchableSource); ok {
+			go func(w WatchableSource) {
+				if err := w.Watch(ctx, e.Refresh); err != nil {
+					log.WithError(err).Errorf("stopped watching %s", w.Name())
+				}
+			}(w)
+		}
+	}
+}
+
+func (c *envSourceChain) list() (out []EnvSource) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	out = make([]EnvSource, len(c.entries))
+	for i := range c.entries {
+		out[i] = c.entries[i].source
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package utils_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type reloadableSource struct {
+	mx      sync.Mutex
+	pending map[string]string
+	values  map[string]string
+}
+
+func (s *reloadableSource) Name() string { return "reloadable" }
+
+func (s *reloadableSource) Lookup(key string) (val string, ok bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	val, ok = s.values[key]
+	return
+}
+
+func (s *reloadableSource) Reload() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.values = s.pending
+	return nil
+}
+
+var _ = Describe("Env watch", func() {
+
+	It("notifies subscribers of reloaded values", func() {
+		source := &reloadableSource{values: map[string]string{"WATCH_LEVEL": "info"}}
+		source.pending = source.values
+		addSource(utils.EnvPriorityRemote, source)
+
+		var changes []utils.EnvValue
+		var levels []string
+		DeferCleanup(utils.Env.Subscribe("WATCH_LEVEL", func(old, new utils.EnvValue) {
+			changes = append(changes, old, new)
+		}))
+		DeferCleanup(utils.SubscribeEnv("WATCH_LEVEL", "trace", func(level string) {
+			levels = append(levels, level)
+		}))
+
+		Expect(utils.Env.Reload()).To(Succeed())
+		Expect(changes).To(BeEmpty())
+
+		source.pending = map[string]string{"WATCH_LEVEL": "debug"}
+		Expect(utils.Env.Reload()).To(Succeed())
+		Expect(changes).To(Equal([]utils.EnvValue{
+			{Key: "WATCH_LEVEL", Value: "info", Source: "reloadable", Priority: utils.EnvPriorityRemote},
+			{Key: "WATCH_LEVEL", Value: "debug", Source: "reloadable", Priority: utils.EnvPriorityRemote},
+		}))
+
+		source.pending = map[string]string{}
+		Expect(utils.Env.Reload()).To(Succeed())
+		Expect(levels).To(Equal([]string{"debug", "trace"}))
+	})
+
+	It("skips values that do not parse", func() {
+		source := &reloadableSource{values: map[string]string{"WATCH_TIMEOUT": "1s"}}
+		addSource(utils.EnvPriorityRemote, source)
+		var timeouts []time.Duration
+		DeferCleanup(utils.SubscribeEnv("WATCH_TIMEOUT", "5s", func(d time.Duration) {
+			timeouts = append(timeouts, d)
+		}))
+		source.pending = map[string]string{"WATCH_TIMEOUT": "soon"}
+		Expect(utils.Env.Reload()).To(Succeed())
+		source.pending = map[string]string{"WATCH_TIMEOUT": "2s"}
+		Expect(utils.Env.Reload()).To(Succeed())
+		Expect(timeouts).To(Equal([]time.Duration{2 * time.Second}))
+	})
+
+	It("follows modified config files", func() {
+		interval := utils.EnvWatchInterval
+		utils.EnvWatchInterval = 10 * time.Millisecond
+		DeferCleanup(func() { utils.EnvWatchInterval = interval })
+
+		path := writeFile("config.yaml", "watch:\n  origins: [a.io]\n")
+		source, err := utils.FileSource(path)
+		Expect(err).NotTo(HaveOccurred())
+		addSource(utils.EnvPriorityFile, source)
+
+		origins := make(chan []string, 1)
+		DeferCleanup(utils.SubscribeEnv("WATCH_ORIGINS", "", func(list []string) { origins <- list }))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		utils.Env.Watch(ctx)
+
+		Expect(os.WriteFile(path, []byte("watch:\n  origins: [a.io, b.io]\n"), 0o600)).To(Succeed())
+		Eventually(origins).Should(Receive(Equal([]string{"a.io", "b.io"})))
+	})
+})
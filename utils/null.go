@@ -2,6 +2,8 @@ package utils
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
@@ -74,3 +76,33 @@ func (nullUtils) OptionalBool(str optional.Bool) (out sql.NullBool) {
 	}
 	return 
 }
+
+// Value unwraps optional values, reporting whether optional.*, sql.Null* and pointer
+// values are set. other values are always set
+func (nullUtils) Value(val interface{}) (out interface{}, set bool) {
+	if opt, ok := val.(interface{ Present() bool }); ok {
+		if !opt.Present() {
+			return nil, false
+		}
+		if get := reflect.ValueOf(val).MethodByName("Get"); get.IsValid() && get.Type().NumIn() == 0 {
+			return get.Call(nil)[0].Interface(), true
+		}
+		return val, true
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, false
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, false
+		}
+	case reflect.Struct:
+		if _, ok := val.(driver.Valuer); ok {
+			if valid := rv.FieldByName("Valid"); valid.IsValid() && valid.Kind() == reflect.Bool {
+				return val, valid.Bool()
+			}
+		}
+	}
+	return val, true
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/kod2ulz/gostart/logr"
 	"github.com/pkg/errors"
@@ -49,17 +50,68 @@ func StopFuncWithCtx(a App, fn func(context.Context) error, message string, args
 type ShFunc1 func()
 type ShFunc2 func() error
 type ShFunc3 func(context.Context) error
-type shutdownFuncs interface {
-	ShFunc1 | ShFunc2 | ShFunc3
+
+// ShutdownFuncs lists the function signatures accepted as cleanup/shutdown routines
+type ShutdownFuncs interface {
+	~func() | ~func() error | ~func(context.Context) error
 }
 
-func ErrorFunc[F shutdownFuncs](a App, fn F, message string, args ...interface{}) {
-	switch any(fn).(type) {
+func ErrorFunc[F ShutdownFuncs](a App, fn F, message string, args ...interface{}) {
+	switch f := any(fn).(type) {
 	case ShFunc1:
-		StopFuncN(a, any(fn).(ShFunc1), message, args...)
+		StopFuncN(a, f, message, args...)
+	case func():
+		StopFuncN(a, f, message, args...)
 	case ShFunc2:
-		StopFunc(a, any(fn).(ShFunc2), message, args...)
-	case ShFunc3:
-		StopFuncWithCtx(a, any(fn).(ShFunc3), message, args...)
+		StopFunc(a, f, message, args...)
+	case func() error:
+		StopFunc(a, f, message, args...)
+	default:
+		StopFuncWithCtx(a, ContextFunc(fn), message, args...)
+	}
+}
+
+// ContextFunc normalises any of the ShutdownFuncs signatures into a ShFunc3.
+// panics raised by the wrapped function are recovered and returned as errors
+func ContextFunc[F ShutdownFuncs](fn F) ShFunc3 {
+	return func(ctx context.Context) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.Errorf("recover: %v", r)
+			}
+		}()
+		switch f := any(fn).(type) {
+		case ShFunc1:
+			f()
+		case func():
+			f()
+		case ShFunc2:
+			return f()
+		case func() error:
+			return f()
+		case ShFunc3:
+			return f(ctx)
+		case func(context.Context) error:
+			return f(ctx)
+		default:
+			return callShutdownFunc(ctx, fn)
+		}
+		return
+	}
+}
+
+// callShutdownFunc handles named types other than ShFunc1, ShFunc2 and ShFunc3
+// whose underlying type satisfies ShutdownFuncs
+func callShutdownFunc(ctx context.Context, fn any) error {
+	switch v := reflect.ValueOf(fn); v.Type().NumIn() {
+	case 0:
+		if out := v.Call(nil); len(out) > 0 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+	default:
+		if out := v.Call([]reflect.Value{reflect.ValueOf(ctx)}); !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
 	}
+	return nil
 }
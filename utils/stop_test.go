@@ -0,0 +1,38 @@
+package utils_test
+
+import (
+	"context"
+
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+type closeFunc func() error
+
+type stopFunc func(context.Context) error
+
+var _ = Describe("ContextFunc", func() {
+
+	It("calls each of the shutdown signatures", func() {
+		calls := 0
+		Expect(utils.ContextFunc(func() { calls++ })(context.Background())).To(Succeed())
+		Expect(utils.ContextFunc(utils.ShFunc1(func() { calls++ }))(context.Background())).To(Succeed())
+		Expect(utils.ContextFunc(func() error { calls++; return errors.New("closed") })(context.Background())).To(MatchError("closed"))
+		Expect(calls).To(Equal(3))
+	})
+
+	It("calls named function types through reflection", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(utils.ContextFunc(closeFunc(func() error { return nil }))(ctx)).To(Succeed())
+		Expect(utils.ContextFunc(closeFunc(func() error { return errors.New("busy") }))(ctx)).To(MatchError("busy"))
+		Expect(utils.ContextFunc(stopFunc(func(ctx context.Context) error { return ctx.Err() }))(ctx)).To(MatchError(context.Canceled))
+	})
+
+	It("recovers panics as errors", func() {
+		err := utils.ContextFunc(stopFunc(func(context.Context) error { panic("boom") }))(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("boom")))
+	})
+})
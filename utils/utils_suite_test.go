@@ -0,0 +1,21 @@
+package utils_test
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestUtils(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Utils Suite")
+}
+
+func setEnv(vars map[string]string) {
+	for k, v := range vars {
+		Expect(os.Setenv(k, v)).To(Succeed())
+		DeferCleanup(os.Unsetenv, k)
+	}
+}
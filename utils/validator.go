@@ -17,6 +17,7 @@ func init() {
 		pass, ok := fl.Field().Interface().(string)
 		return ok && Validator.PasswordValid(pass)
 	})
+	Validator.initTranslations()
 	Validator.SetPhoneRegex("ug", `^\+?(0|256)(20|31|32|39|70|71|72|73|74|75|76|77|78)[0-9]{7}$`)
 }
 
@@ -47,9 +48,11 @@ func (u *validatorUtil) SetPhoneRegex(countryCode, regexStr string) {
 		phone, ok := fl.Field().Interface().(string)
 		return ok && u.Phone[countryCode].MatchString(phone)
 	}
-	for _, rule := range object.String(countryCode).Variations("phone-%s", "phone_%s") {
+	rules := object.String(countryCode).Variations("phone-%s", "phone_%s")
+	for _, rule := range rules {
 		Validate.RegisterValidation(rule, validateFn)
 	}
+	u.registerPhoneTranslations(rules...)
 }
 
 var Validator validatorUtil
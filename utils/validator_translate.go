@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/pt"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	pt_translations "github.com/go-playground/validator/v10/translations/pt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var validationLocales = []struct {
+	locale   locales.Translator
+	defaults func(*validator.Validate, ut.Translator) error
+	password string
+	phone    string
+}{
+	{en.New(), en_translations.RegisterDefaultTranslations,
+		"{0} must have at least 8 characters with upper and lower case letters, a number and a symbol",
+		"{0} must be a valid phone number"},
+	{fr.New(), fr_translations.RegisterDefaultTranslations,
+		"{0} doit contenir au moins 8 caractères dont une majuscule, une minuscule, un chiffre et un symbole",
+		"{0} doit être un numéro de téléphone valide"},
+	{es.New(), es_translations.RegisterDefaultTranslations,
+		"{0} debe tener al menos 8 caracteres con mayúsculas, minúsculas, un número y un símbolo",
+		"{0} debe ser un número de teléfono válido"},
+	{pt.New(), pt_translations.RegisterDefaultTranslations,
+		"{0} deve ter pelo menos 8 caracteres com letras maiúsculas e minúsculas, um número e um símbolo",
+		"{0} deve ser um número de telefone válido"},
+}
+
+var validationTranslator *ut.UniversalTranslator
+
+// initTranslations registers the messages of the built in and custom rules in every
+// supported locale
+func (u *validatorUtil) initTranslations() {
+	validationTranslator = ut.New(validationLocales[0].locale)
+	for _, l := range validationLocales {
+		validationTranslator.AddTranslator(l.locale, true)
+		trans, _ := validationTranslator.GetTranslator(l.locale.Locale())
+		if err := l.defaults(Validate, trans); err != nil {
+			log.WithError(err).Errorf("failed to register %s validation messages", l.locale.Locale())
+		}
+		u.RegisterTranslation(l.locale.Locale(), "password", l.password)
+	}
+}
+
+// registerPhoneTranslations sets the messages of the phone rules of a country
+func (u *validatorUtil) registerPhoneTranslations(rules ...string) {
+	if validationTranslator == nil {
+		return
+	}
+	for _, l := range validationLocales {
+		for _, rule := range rules {
+			if rule == "" {
+				continue
+			}
+			u.RegisterTranslation(l.locale.Locale(), rule, l.phone)
+		}
+	}
+}
+
+// FieldPath is the path of the field of fe in a value of type root after the json names of
+// its fields e.g. items[0].name. fields without a json name keep their go name
+func (validatorUtil) FieldPath(root reflect.Type, fe validator.FieldError) string {
+	typ := derefType(root)
+	ns, ok := strings.CutPrefix(fe.StructNamespace(), typ.Name()+".")
+	if !ok {
+		_, ns, _ = strings.Cut(fe.StructNamespace(), ".")
+	}
+	var path []string
+	for _, segment := range namespaceSegments(ns) {
+		name, index, _ := strings.Cut(segment, "[")
+		if index != "" {
+			index = "[" + index
+		}
+		var field reflect.StructField
+		found := false
+		if typ != nil && typ.Kind() == reflect.Struct {
+			field, found = typ.FieldByName(name)
+		}
+		if !found {
+			typ, path = nil, append(path, segment)
+			continue
+		}
+		typ = derefType(field.Type)
+		for i := strings.Count(index, "["); i > 0 && typ != nil; i-- {
+			switch typ.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				typ = derefType(typ.Elem())
+			default:
+				typ = nil
+			}
+		}
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && jsonName == "" && index == "" {
+			continue
+		} else if jsonName != "" && jsonName != "-" {
+			name = jsonName
+		}
+		path = append(path, name+index)
+	}
+	return strings.Join(path, ".")
+}
+
+// namespaceSegments splits a validator namespace on the dots outside map keys
+func namespaceSegments(ns string) (out []string) {
+	depth, start := 0, 0
+	for i, c := range ns {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				out, start = append(out, ns[start:i]), i+1
+			}
+		}
+	}
+	return append(out, ns[start:])
+}
+
+func derefType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// Translator returns the translator of the first supported locale e.g. from an
+// Accept-Language header, falling back to english
+func (validatorUtil) Translator(locales ...string) ut.Translator {
+	trans, _ := validationTranslator.FindTranslator(locales...)
+	return trans
+}
+
+// RegisterTranslation sets the message of a validation rule in locale. {0} is replaced
+// with the name of the field and {1} with the rule param
+func (validatorUtil) RegisterTranslation(locale, rule, message string) (err error) {
+	trans, found := validationTranslator.GetTranslator(locale)
+	if !found {
+		return errors.Errorf("cannot register %s message in unsupported locale %s", rule, locale)
+	}
+	return Validate.RegisterTranslation(rule, trans, func(t ut.Translator) error {
+		return t.Add(rule, message, true)
+	}, func(t ut.Translator, fe validator.FieldError) string {
+		msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return msg
+	})
+}
+
+// TranslateValidation renders the message of a failed rule with trans. rules without a
+// message in the locale fall back to a generic english one. the field is named after
+// name when given, e.g. the last part of FieldPath
+func (validatorUtil) TranslateValidation(fe validator.FieldError, trans ut.Translator, name ...string) string {
+	if trans == nil {
+		trans = validationTranslator.GetFallback()
+	}
+	field := fe.Field()
+	if len(name) > 0 && name[0] != "" {
+		field = name[0]
+	}
+	if msg := fe.Translate(trans); msg != fe.Error() {
+		return strings.Replace(msg, fe.Field(), field, 1)
+	}
+	if fe.Param() != "" {
+		return field + " failed the " + fe.Tag() + "=" + fe.Param() + " rule"
+	}
+	return field + " failed the " + fe.Tag() + " rule"
+}
@@ -0,0 +1,90 @@
+package utils_test
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/kod2ulz/gostart/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type signupForm struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"password"`
+	Phone    string `json:"phone" validate:"phone-ug"`
+	Nick     string `json:"-" validate:"max=3"`
+}
+
+type orderLine struct {
+	Sku string `json:"sku" validate:"required"`
+}
+
+type orderMeta struct {
+	Channel string `json:"channel" validate:"required"`
+}
+
+type orderForm struct {
+	orderMeta
+	Lines []*orderLine         `json:"lines" validate:"dive"`
+	Tags  map[string]orderLine `json:"tags" validate:"dive"`
+}
+
+var _ = Describe("Validator translations", func() {
+
+	failures := func(form signupForm) (out map[string]validator.FieldError) {
+		var errs validator.ValidationErrors
+		Expect(errors.As(utils.Validate.Struct(form), &errs)).To(BeTrue())
+		out = make(map[string]validator.FieldError)
+		for _, fe := range errs {
+			out[utils.Validator.FieldPath(reflect.TypeOf(form), fe)] = fe
+		}
+		return
+	}
+
+	It("reports fields by their json names", func() {
+		errs := failures(signupForm{Password: "weak", Phone: "123", Nick: "toolong"})
+		Expect(errs).To(HaveKey("email"))
+		Expect(errs).To(HaveKey("password"))
+		Expect(errs).To(HaveKey("phone"))
+		Expect(errs).To(HaveKey("Nick"))
+		Expect(errs["email"].Field()).To(Equal("Email"), "the shared validator keeps go names")
+	})
+
+	It("follows json names into nested fields", func() {
+		var errs validator.ValidationErrors
+		form := &orderForm{Lines: []*orderLine{{Sku: "a"}, {}}, Tags: map[string]orderLine{"x.y": {}}}
+		Expect(errors.As(utils.Validate.Struct(form), &errs)).To(BeTrue())
+		var paths []string
+		for _, fe := range errs {
+			paths = append(paths, utils.Validator.FieldPath(reflect.TypeOf(form), fe))
+		}
+		Expect(paths).To(ConsistOf("channel", "lines[1].sku", "tags[x.y].sku"))
+	})
+
+	It("translates built in and custom rules", func() {
+		errs := failures(signupForm{Password: "weak", Phone: "123"})
+		en := utils.Validator.Translator("en")
+		Expect(utils.Validator.TranslateValidation(errs["email"], en)).To(Equal("Email is a required field"))
+		Expect(utils.Validator.TranslateValidation(errs["email"], en, "email")).To(Equal("email is a required field"))
+		Expect(utils.Validator.TranslateValidation(errs["phone"], en, "phone")).To(Equal("phone must be a valid phone number"))
+		fr := utils.Validator.Translator("fr_CH", "fr")
+		Expect(utils.Validator.TranslateValidation(errs["phone"], fr, "phone")).To(Equal("phone doit être un numéro de téléphone valide"))
+		Expect(utils.Validator.TranslateValidation(errs["password"], fr, "password")).To(HavePrefix("password doit contenir"))
+	})
+
+	It("falls back to english for unsupported locales", func() {
+		errs := failures(signupForm{Password: "weak", Phone: "123"})
+		trans := utils.Validator.Translator("xx")
+		Expect(utils.Validator.TranslateValidation(errs["phone"], trans, "phone")).To(Equal("phone must be a valid phone number"))
+	})
+
+	It("accepts messages of new rules", func() {
+		Expect(utils.Validator.RegisterTranslation("xx", "max", "{0}")).ToNot(Succeed())
+		Expect(utils.Validator.RegisterTranslation("es", "phone-ug", "{0} no es un teléfono")).To(Succeed())
+		errs := failures(signupForm{Password: "weak", Phone: "123"})
+		Expect(utils.Validator.TranslateValidation(errs["phone"], utils.Validator.Translator("es"), "phone")).To(Equal("phone no es un teléfono"))
+		Expect(utils.Validator.RegisterTranslation("es", "phone-ug", "{0} debe ser un número de teléfono válido")).To(Succeed())
+	})
+})